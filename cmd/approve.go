@@ -0,0 +1,98 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/ipc"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// newApproveCmd answers requests left by the queue approver
+// (workspace "approver: queue").
+func newApproveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "approve [id]",
+		Short: "List or approve queued sandbox requests",
+		Long: "Without an id, lists requests waiting for approval from sessions using\n" +
+			"the queue approver. With an id, approves that request (prompting for the\n" +
+			"vault password if the request needs one).",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := ipc.ApprovalDir()
+			if len(args) == 0 {
+				listApprovals(dir)
+				return
+			}
+
+			p, err := ipc.FindApproval(dir, args[0])
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			password := ""
+			if p.Secret {
+				password = promptPassword(p.Title + " ")
+			}
+			if err := ipc.AnswerApproval(dir, p.ID, true, password); err != nil {
+				ui.Errorf("Failed to approve %s: %v", p.ID, err)
+			}
+			ui.Successf("Approved %s", p.ID)
+		},
+	}
+}
+
+func newDenyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "deny <id>",
+		Short: "Deny a queued sandbox request",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := ipc.AnswerApproval(ipc.ApprovalDir(), args[0], false, ""); err != nil {
+				ui.Errorf("%v", err)
+			}
+			ui.Successf("Denied %s", args[0])
+		},
+	}
+}
+
+func listApprovals(dir string) {
+	pending, err := ipc.ListApprovals(dir)
+	if err != nil {
+		ui.Errorf("Failed to list approvals: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("No pending approvals.")
+		return
+	}
+	for _, p := range pending {
+		age := time.Since(p.Created).Truncate(time.Second)
+		fmt.Printf("%s  %s  %s (%s ago)\n", p.ID, p.Session, p.Title, age)
+		for _, f := range p.Fields {
+			fmt.Printf("          %s: %s\n", f.Label, f.Value)
+		}
+	}
+	fmt.Println()
+	fmt.Println("Run 'exitbox approve <id>' or 'exitbox deny <id>'.")
+}
+
+func init() {
+	rootCmd.AddCommand(newApproveCmd())
+	rootCmd.AddCommand(newDenyCmd())
+}
@@ -0,0 +1,137 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the security audit log",
+		Long: "ExitBox records approvals, vault and KV writes, firewall reloads, mounts\n" +
+			"and sessions in a hash-chained log at " + audit.File() + ".",
+	}
+	cmd.AddCommand(newAuditListCmd())
+	cmd.AddCommand(newAuditVerifyCmd())
+	return cmd
+}
+
+func newAuditListCmd() *cobra.Command {
+	var (
+		session string
+		evType  string
+		since   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit events",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			from, err := parseSince(since)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			events, err := audit.Read(audit.File())
+			if err != nil {
+				ui.Errorf("Failed to read audit log: %v", err)
+			}
+
+			shown := 0
+			for _, ev := range events {
+				if session != "" && !strings.Contains(ev.Session, session) {
+					continue
+				}
+				if evType != "" && ev.Type != evType {
+					continue
+				}
+				if ev.Time.Before(from) {
+					continue
+				}
+				if shown == 0 {
+					fmt.Printf("%-19s  %-8s  %-15s  %-8s  %s\n", "TIME", "SESSION", "TYPE", "OUTCOME", "TARGET")
+				}
+				shown++
+				line := fmt.Sprintf("%-19s  %-8s  %-15s  %-8s  %s",
+					ev.Time.Local().Format("2006-01-02 15:04:05"), sessionID(ev.Session), ev.Type, ev.Outcome, ev.Target)
+				if ev.Detail != "" {
+					line += "  (" + ev.Detail + ")"
+				}
+				fmt.Println(line)
+			}
+			if shown == 0 {
+				fmt.Println("No audit events found.")
+			}
+		},
+	}
+	cmd.Flags().StringVar(&session, "session", "", "Only events from sessions whose container name contains this")
+	cmd.Flags().StringVar(&evType, "type", "", "Only events of this type, e.g. allow_domain, vault_get, mount")
+	cmd.Flags().StringVar(&since, "since", "", "Only events after a duration ago (24h) or a date (2006-01-02)")
+	return cmd
+}
+
+func newAuditVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Check the audit log's hash chain",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			n, err := audit.Verify(audit.File())
+			if err != nil {
+				ui.Errorf("Audit log verification failed after %d good events: %v", n, err)
+			}
+			ui.Successf("Audit log intact: %d events", n)
+		},
+	}
+}
+
+// sessionID shortens a container name to its random suffix.
+func sessionID(name string) string {
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// parseSince parses the --since flag. An empty value means the beginning.
+func parseSince(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: want a duration (24h) or a date (2006-01-02)", s)
+}
+
+func init() {
+	rootCmd.AddCommand(newAuditCmd())
+}
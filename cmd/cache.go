@@ -0,0 +1,84 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or clear the package registry cache",
+		Long: "Manage the package cache kept by the registry mirror (npm, PyPI, Go modules).\n" +
+			"Enable it with settings.proxy_cache.enabled in config.yaml.",
+	}
+
+	cmd.AddCommand(newCacheStatsCmd())
+	cmd.AddCommand(newCacheClearCmd())
+	return cmd
+}
+
+func newCacheStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show package cache hit rates and size",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !config.LoadOrDefault().Settings.ProxyCache.Enabled {
+				ui.Warnf("Proxy cache is disabled (settings.proxy_cache.enabled in %s)", config.ConfigFile())
+			}
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error("No container runtime found.")
+			}
+			stats, err := network.CacheStats(rt)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			fmt.Println(stats)
+		},
+	}
+}
+
+func newCacheClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Delete all cached packages",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error("No container runtime found.")
+			}
+			if err := network.ClearCache(rt); err != nil {
+				ui.Errorf("%v", err)
+			}
+			ui.Success("Package cache cleared")
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newCacheCmd())
+}
@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"os/exec"
 
+	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -50,19 +52,39 @@ var cleanCmd = &cobra.Command{
 			ui.Success("Cleanup complete")
 
 		case "all":
-			ui.Info("Removing all exitbox images...")
-			// List and remove all exitbox images
-			out, _ := exec.Command(rtCmd, "images", "--filter", "reference=exitbox-*", "--format", "{{.Repository}}:{{.Tag}}").Output()
-			for _, img := range splitLines(string(out)) {
-				_ = exec.Command(rtCmd, "rmi", "-f", img).Run()
+			ui.Info("Removing all of your exitbox images...")
+			// Only this user's namespaced images: the shared base and
+			// proxy images are left for other users of the runtime.
+			out, _ := exec.Command(rtCmd, "images", "--filter", "reference="+config.Namespace()+"-*", "--format", "{{.Repository}}:{{.Tag}}").Output()
+			// Without -f, images running sessions still use are kept.
+			// Parents can only go once their child images are gone,
+			// hence the passes.
+			remaining := splitLines(string(out))
+			for removed := true; removed && len(remaining) > 0; {
+				removed = false
+				var kept []string
+				for _, img := range remaining {
+					if exec.Command(rtCmd, "rmi", img).Run() == nil {
+						removed = true
+					} else {
+						kept = append(kept, img)
+					}
+				}
+				remaining = kept
+			}
+			if len(remaining) > 0 {
+				ui.Infof("Kept %d image(s) still in use", len(remaining))
 			}
 			ui.Success("Cleanup complete")
 
 		case "containers":
 			ui.Info("Stopping all exitbox containers...")
-			out, _ := exec.Command(rtCmd, "ps", "--filter", "name=exitbox-", "--format", "{{.ID}}").Output()
-			for _, id := range splitLines(string(out)) {
-				_ = exec.Command(rtCmd, "stop", id).Run()
+			// Only this user's containers: other users may share the runtime.
+			names, _ := rt.PS("name="+config.Namespace()+"-", "{{.Names}}")
+			for _, name := range names {
+				if project.IsOwnContainer(name) {
+					_ = exec.Command(rtCmd, "stop", name).Run()
+				}
 			}
 			network.CleanupSquidIfUnused(rt)
 			ui.Success("Cleanup complete")
@@ -73,7 +95,7 @@ var cleanCmd = &cobra.Command{
 			fmt.Println("Modes:")
 			fmt.Println("  unused      Remove unused images (default)")
 			fmt.Println("  all         Remove all exitbox images")
-			fmt.Println("  containers  Stop all exitbox containers")
+			fmt.Println("  containers  Stop all of your exitbox containers")
 			fmt.Println()
 		}
 	},
@@ -0,0 +1,62 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/daemon"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// newDaemonCmd runs exitboxd. exitbox run starts it on demand; it exits on
+// its own once no sessions have been active for a while.
+func newDaemonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "daemon",
+		Short:  "Run the exitboxd firewall coordinator",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error("No container runtime found.")
+			}
+			l, closeListener, err := daemon.Listen()
+			if errors.Is(err, daemon.ErrAlreadyRunning) {
+				return
+			}
+			if err != nil {
+				ui.Errorf("Failed to start exitboxd: %v", err)
+			}
+			defer closeListener()
+
+			ui.Infof("exitboxd listening on %s", daemon.SocketPath())
+			if err := daemon.NewServer(network.NewCoordinator(rt)).Serve(l); err != nil {
+				ui.Errorf("exitboxd: %v", err)
+			}
+			ui.Info("exitboxd idle, exiting")
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newDaemonCmd())
+}
@@ -22,30 +22,17 @@
 package main
 
 import (
-	"bufio"
-	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"os"
-)
 
-type request struct {
-	Type    string      `json:"type"`
-	ID      string      `json:"id"`
-	Payload interface{} `json:"payload"`
-}
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
 
 type allowDomainPayload struct {
 	Domain string `json:"domain"`
 }
 
-type response struct {
-	Type    string          `json:"type"`
-	ID      string          `json:"id"`
-	Payload json.RawMessage `json:"payload"`
-}
-
 type allowDomainResponse struct {
 	Approved bool   `json:"approved"`
 	Error    string `json:"error,omitempty"`
@@ -57,14 +44,18 @@ func main() {
 		os.Exit(1)
 	}
 
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
+	c, err := client.Dial("exitbox-allow")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Domain allow requests require firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	hasFailure := false
 	for _, domain := range os.Args[1:] {
-		approved, err := requestAllow(socketPath, domain)
+		approved, err := requestAllow(c, domain)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", domain, err)
 			hasFailure = true
@@ -77,65 +68,20 @@ func main() {
 			hasFailure = true
 		}
 	}
+	_ = c.Close()
 
 	if hasFailure {
 		os.Exit(1)
 	}
 }
 
-func requestAllow(socketPath, domain string) (bool, error) {
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return false, fmt.Errorf("IPC socket not available. Domain allow requests require firewall mode")
-	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type: "allow_domain",
-		ID:   id,
-		Payload: allowDomainPayload{
-			Domain: domain,
-		},
-	}
-
-	data, err := json.Marshal(req)
-	if err != nil {
-		return false, err
-	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
-		return false, err
-	}
-
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return false, err
-		}
-		return false, fmt.Errorf("no response from host")
-	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return false, err
-	}
-
+func requestAllow(c *client.Client, domain string) (bool, error) {
 	var payload allowDomainResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := c.Call("allow_domain", allowDomainPayload{Domain: domain}, &payload); err != nil {
 		return false, err
 	}
-
 	if payload.Error != "" {
 		return false, fmt.Errorf("%s", payload.Error)
 	}
-
 	return payload.Approved, nil
 }
-
-func randomID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return fmt.Sprintf("%x", b)
-}
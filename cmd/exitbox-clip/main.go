@@ -0,0 +1,115 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-clip is a standalone binary for exchanging text with the host
+// clipboard from inside an ExitBox container. Every copy and paste is
+// approved by the user on the host. It communicates with the host via a
+// Unix domain socket using JSON-lines protocol.
+//
+// Usage:
+//
+//	exitbox-clip copy [TEXT]   (reads stdin when TEXT is omitted)
+//	exitbox-clip paste
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type copyPayload struct {
+	Text string `json:"text"`
+}
+
+type clipResponse struct {
+	Text     string `json:"text,omitempty"`
+	Approved bool   `json:"approved"`
+	Redacted bool   `json:"redacted,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var (
+		msgType string
+		payload interface{} = struct{}{}
+	)
+	switch os.Args[1] {
+	case "copy":
+		text := strings.Join(os.Args[2:], " ")
+		if len(os.Args) == 2 {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: reading stdin: %v\n", err)
+				os.Exit(1)
+			}
+			text = string(data)
+		}
+		msgType, payload = "clip_copy", copyPayload{Text: text}
+	case "paste":
+		if len(os.Args) != 2 {
+			usage()
+		}
+		msgType = "clip_paste"
+	default:
+		usage()
+	}
+
+	c, err := client.Dial("exitbox-clip")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Clipboard access requires firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp clipResponse
+	err = c.Call(msgType, payload, &resp)
+	_ = c.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		os.Exit(1)
+	}
+	if !resp.Approved {
+		fmt.Fprintln(os.Stderr, "Denied by user.")
+		os.Exit(1)
+	}
+	if resp.Redacted {
+		fmt.Fprintln(os.Stderr, "Note: vault secrets were redacted.")
+	}
+	if msgType == "clip_paste" {
+		fmt.Print(resp.Text)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: exitbox-clip copy [TEXT]   (reads stdin when TEXT is omitted)")
+	fmt.Fprintln(os.Stderr, "       exitbox-clip paste")
+	os.Exit(1)
+}
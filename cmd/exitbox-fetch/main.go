@@ -0,0 +1,77 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-fetch is a standalone binary for importing a single file from the
+// host into an ExitBox container. The user approves each file; approved
+// files are copied to the session inbox, /run/exitbox/inbox. It
+// communicates with the host via a Unix domain socket using JSON-lines
+// protocol.
+//
+// Usage: exitbox-fetch <host-path>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type fetchPayload struct {
+	Path string `json:"path"`
+}
+
+type fetchResponse struct {
+	Approved bool   `json:"approved"`
+	Path     string `json:"path,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) != 2 || os.Args[1] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-fetch <host-path>")
+		fmt.Fprintln(os.Stderr, "  host-path is a path on the host, absolute or starting with ~/")
+		os.Exit(1)
+	}
+
+	c, err := client.Dial("exitbox-fetch")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Host file import requires firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp fetchResponse
+	err = c.Call("request_file", fetchPayload{Path: os.Args[1]}, &resp)
+	_ = c.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		os.Exit(1)
+	}
+	if !resp.Approved {
+		fmt.Fprintln(os.Stderr, "Denied by user.")
+		os.Exit(1)
+	}
+	fmt.Println(resp.Path)
+}
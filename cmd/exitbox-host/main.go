@@ -0,0 +1,135 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-host is a standalone binary for running one of the user's
+// preconfigured host actions (host_actions in host.yaml) from inside an
+// ExitBox container. The user approves each run; the command's output is
+// streamed back and its exit status becomes exitbox-host's. It
+// communicates with the host via a Unix domain socket using JSON-lines
+// protocol.
+//
+// Usage:
+//
+//	exitbox-host <action>
+//	exitbox-host -l
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type actionPayload struct {
+	Action string `json:"action"`
+}
+
+type actionResponse struct {
+	Approved bool   `json:"approved"`
+	Run      string `json:"run,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type outputPayload struct {
+	Run string `json:"run"`
+}
+
+type outputResponse struct {
+	Output   string `json:"output,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+type listResponse struct {
+	Actions []struct {
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+	} `json:"actions,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) != 2 || os.Args[1] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-host <action>")
+		fmt.Fprintln(os.Stderr, "       exitbox-host -l   (list actions)")
+		os.Exit(1)
+	}
+
+	c, err := client.Dial("exitbox-host")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Host actions require firewall mode")
+		}
+		fail(err.Error())
+	}
+	defer c.Close()
+
+	if os.Args[1] == "-l" || os.Args[1] == "--list" {
+		var resp listResponse
+		if err := c.Call("host_action_list", struct{}{}, &resp); err != nil {
+			fail(err.Error())
+		}
+		if resp.Error != "" {
+			fail(resp.Error)
+		}
+		if len(resp.Actions) == 0 {
+			fmt.Fprintln(os.Stderr, "No host actions configured.")
+		}
+		for _, a := range resp.Actions {
+			fmt.Printf("%-20s %s\n", a.Name, a.Description)
+		}
+		return
+	}
+
+	var resp actionResponse
+	if err := c.Call("host_action", actionPayload{Action: os.Args[1]}, &resp); err != nil {
+		fail(err.Error())
+	}
+	if resp.Error != "" {
+		fail(resp.Error)
+	}
+	if !resp.Approved {
+		fmt.Fprintln(os.Stderr, "Denied by user.")
+		os.Exit(1)
+	}
+
+	for {
+		var out outputResponse
+		if err := c.Call("host_action_output", outputPayload{Run: resp.Run}, &out); err != nil {
+			fail(err.Error())
+		}
+		if out.Error != "" {
+			fail(out.Error)
+		}
+		fmt.Print(out.Output)
+		if out.Done {
+			c.Close()
+			if out.ExitCode < 0 {
+				// Killed or never reported a status.
+				os.Exit(1)
+			}
+			os.Exit(out.ExitCode)
+		}
+	}
+}
+
+func fail(msg string) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
+	os.Exit(1)
+}
@@ -27,25 +27,12 @@
 package main
 
 import (
-	"bufio"
-	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"os"
-)
-
-type request struct {
-	Type    string      `json:"type"`
-	ID      string      `json:"id"`
-	Payload interface{} `json:"payload"`
-}
 
-type response struct {
-	Type    string          `json:"type"`
-	ID      string          `json:"id"`
-	Payload json.RawMessage `json:"payload"`
-}
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
 
 type kvGetPayload struct {
 	Key string `json:"key"`
@@ -134,14 +121,8 @@ func printUsage() {
 }
 
 func cmdGet(key string) {
-	resp, err := sendRequest("kv_get", kvGetPayload{Key: key})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	var payload kvGetResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := sendRequest("kv_get", kvGetPayload{Key: key}, &payload); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -156,14 +137,8 @@ func cmdGet(key string) {
 }
 
 func cmdSet(key, value string) {
-	resp, err := sendRequest("kv_set", kvSetPayload{Key: key, Value: value})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	var payload kvSetResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := sendRequest("kv_set", kvSetPayload{Key: key, Value: value}, &payload); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -174,14 +149,8 @@ func cmdSet(key, value string) {
 }
 
 func cmdDelete(key string) {
-	resp, err := sendRequest("kv_delete", kvDeletePayload{Key: key})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	var payload kvDeleteResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := sendRequest("kv_delete", kvDeletePayload{Key: key}, &payload); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -192,14 +161,8 @@ func cmdDelete(key string) {
 }
 
 func cmdList(prefix string) {
-	resp, err := sendRequest("kv_list", kvListPayload{Prefix: prefix})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-
 	var payload kvListResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := sendRequest("kv_list", kvListPayload{Prefix: prefix}, &payload); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -212,53 +175,16 @@ func cmdList(prefix string) {
 	}
 }
 
-func sendRequest(reqType string, payload interface{}) (*response, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
-
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("IPC socket not available. KV store requires the IPC server to be running")
+// sendRequest sends one request to the host and decodes the response
+// payload into result.
+func sendRequest(reqType string, payload, result interface{}) error {
+	c, err := client.Dial("exitbox-kv")
+	if errors.Is(err, client.ErrUnavailable) {
+		return fmt.Errorf("IPC socket not available. KV store requires the IPC server to be running")
 	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type:    reqType,
-		ID:      id,
-		Payload: payload,
-	}
-
-	data, err := json.Marshal(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
-		return nil, err
-	}
-
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
-		}
-		return nil, fmt.Errorf("no response from host")
-	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
-}
-
-func randomID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return fmt.Sprintf("%x", b)
+	defer c.Close()
+	return c.Call(reqType, payload, result)
 }
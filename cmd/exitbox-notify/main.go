@@ -0,0 +1,75 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-notify is a standalone binary for sending a notification to the
+// host user from inside an ExitBox container, e.g. when a long task has
+// finished or input is needed. It communicates with the host via a Unix
+// domain socket using JSON-lines protocol.
+//
+// Usage: exitbox-notify [-t TITLE] <message...>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type notifyPayload struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+type notifyResponse struct {
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
+
+func main() {
+	args := os.Args[1:]
+	title := ""
+	if len(args) >= 2 && (args[0] == "-t" || args[0] == "--title") {
+		title, args = args[1], args[2:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-notify [-t TITLE] <message...>")
+		os.Exit(1)
+	}
+
+	c, err := client.Dial("exitbox-notify")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Notifications require firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp notifyResponse
+	err = c.Call("notify", notifyPayload{Title: title, Message: strings.Join(args, " ")}, &resp)
+	_ = c.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		os.Exit(1)
+	}
+}
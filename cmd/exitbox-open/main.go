@@ -0,0 +1,78 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-open is a standalone binary for opening a URL in the host's
+// browser from inside an ExitBox container, e.g. for OAuth logins. The
+// image also installs it as xdg-open and sets BROWSER to it, so CLIs that
+// open a browser work unchanged. It communicates with the host via a Unix
+// domain socket using JSON-lines protocol.
+//
+// Usage: exitbox-open <url>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type openPayload struct {
+	URL string `json:"url"`
+}
+
+type openResponse struct {
+	Approved     bool   `json:"approved"`
+	CallbackPort int    `json:"callback_port,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) != 2 || os.Args[1] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-open <url>")
+		os.Exit(1)
+	}
+
+	c, err := client.Dial("exitbox-open")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Opening URLs on the host requires firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp openResponse
+	err = c.Call("open_url", openPayload{URL: os.Args[1]}, &resp)
+	_ = c.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		os.Exit(1)
+	}
+	if !resp.Approved {
+		fmt.Fprintln(os.Stderr, "Denied by user.")
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "Opened in the host browser.")
+	if resp.CallbackPort != 0 {
+		fmt.Fprintf(os.Stderr, "Relaying the login callback on localhost:%d into the sandbox.\n", resp.CallbackPort)
+	}
+}
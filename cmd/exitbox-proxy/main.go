@@ -0,0 +1,200 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-proxy is the built-in egress firewall, an alternative to the
+// Squid container. It runs in its own container on the internal and egress
+// networks and writes one JSON access log line per request to stdout.
+//
+// The same binary runs the package registry mirror (settings.proxy_cache)
+// in a separate container on the internal networks only.
+//
+// Usage:
+//
+//	exitbox-proxy [serve]       run the proxy
+//	exitbox-proxy set-acl       read an ACL as JSON on stdin and apply it
+//	exitbox-proxy ping          check that the proxy is up
+//	exitbox-proxy mirror        run the registry mirror
+//	exitbox-proxy mirror-stats  print the mirror's cache statistics
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/proxy"
+)
+
+const (
+	defaultListen       = ":3128"
+	defaultMirrorListen = ":3129"
+	defaultACL          = "/etc/exitbox-proxy/acl.json"
+	defaultControl      = "/run/exitbox-proxy/control.sock"
+	defaultCacheDir     = "/var/cache/exitbox-mirror"
+	defaultCADir        = "/etc/exitbox-proxy/ca"
+)
+
+func main() {
+	cmd := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("exitbox-proxy "+cmd, flag.ExitOnError)
+	listenDefault := defaultListen
+	if cmd == "mirror" || cmd == "mirror-stats" {
+		listenDefault = defaultMirrorListen
+	}
+	listen := fs.String("listen", listenDefault, "listen address")
+	aclPath := fs.String("acl", defaultACL, "initial ACL file (JSON)")
+	control := fs.String("control", defaultControl, "control socket path")
+	cacheDir := fs.String("cache-dir", defaultCacheDir, "mirror cache directory")
+	maxMB := fs.Int64("max-mb", 10240, "mirror cache size in MB")
+	upstream := fs.String("upstream", "", "forward proxy URL for mirror requests")
+	caDir := fs.String("ca-dir", defaultCADir, "extra PEM CA certificates for the mirror")
+	_ = fs.Parse(args)
+
+	var err error
+	switch cmd {
+	case "serve":
+		err = serve(*listen, *aclPath, *control)
+	case "set-acl":
+		var acl proxy.ACL
+		if err = json.NewDecoder(os.Stdin).Decode(&acl); err == nil {
+			err = proxy.SendControl(*control, proxy.ControlRequest{Type: "set_acl", ACL: &acl})
+		}
+	case "ping":
+		err = proxy.SendControl(*control, proxy.ControlRequest{Type: "ping"})
+	case "mirror":
+		err = mirror(*listen, *cacheDir, *maxMB, *upstream, *caDir)
+	case "mirror-stats":
+		err = mirrorStats(*listen)
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-proxy [serve|set-acl|ping|mirror|mirror-stats] [flags]")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-proxy: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func serve(listen, aclPath, controlPath string) error {
+	// Start from the ACL file if present; otherwise fail closed until the
+	// host pushes one.
+	var acl proxy.ACL
+	if data, err := os.ReadFile(aclPath); err == nil {
+		if err := json.Unmarshal(data, &acl); err != nil {
+			fmt.Fprintf(os.Stderr, "exitbox-proxy: ignoring invalid ACL file: %v\n", err)
+			acl = proxy.ACL{}
+		}
+	}
+
+	srv, err := proxy.NewServer(acl, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-proxy: ignoring invalid ACL file: %v\n", err)
+		if srv, err = proxy.NewServer(proxy.ACL{}, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(controlPath), 0700); err != nil {
+		return err
+	}
+	_ = os.Remove(controlPath)
+	cl, err := net.Listen("unix", controlPath)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	defer cl.Close()
+	go func() {
+		if err := srv.ServeControl(cl); err != nil {
+			fmt.Fprintf(os.Stderr, "exitbox-proxy: control socket: %v\n", err)
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "exitbox-proxy: listening on %s\n", listen)
+	return newHTTPServer(listen, srv).ListenAndServe()
+}
+
+// newHTTPServer bounds how long idle and slow clients can hold a connection.
+// There is no read or write timeout: CONNECT tunnels and large downloads
+// legitimately stay open for a long time.
+func newHTTPServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+func mirror(listen, cacheDir string, maxMB int64, upstream, caDir string) error {
+	cfg := proxy.MirrorConfig{CacheDir: cacheDir, MaxBytes: maxMB << 20}
+	if upstream != "" {
+		u, err := url.Parse(upstream)
+		if err != nil {
+			return fmt.Errorf("invalid upstream proxy: %w", err)
+		}
+		cfg.Proxy = u
+	}
+
+	// Registries are verified against the system roots plus the user's
+	// CA certificates, e.g. for a TLS-intercepting corporate proxy.
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if entries, err := os.ReadDir(caDir); err == nil {
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(caDir, e.Name()))
+			if err != nil || !pool.AppendCertsFromPEM(data) {
+				fmt.Fprintf(os.Stderr, "exitbox-proxy: ignoring CA file %s\n", e.Name())
+			}
+		}
+	}
+	cfg.RootCAs = pool
+
+	m, err := proxy.NewMirror(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exitbox-proxy: mirror listening on %s\n", listen)
+	return newHTTPServer(listen, m).ListenAndServe()
+}
+
+func mirrorStats(listen string) error {
+	_, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Get("http://127.0.0.1:" + port + proxy.MirrorStatsPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
@@ -0,0 +1,82 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-tool is a standalone binary for requesting an Alpine package from
+// inside an ExitBox container, which cannot install packages itself. An
+// approved package is added to the workspace and installed when the image
+// is rebuilt. It communicates with the host via a Unix domain socket using
+// JSON-lines protocol.
+//
+// Usage: exitbox-tool request <package>
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type toolPayload struct {
+	Package string `json:"package"`
+}
+
+type toolResponse struct {
+	Approved  bool   `json:"approved"`
+	Workspace string `json:"workspace,omitempty"`
+	Queued    bool   `json:"queued,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) != 3 || os.Args[1] != "request" {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-tool request <package>")
+		os.Exit(1)
+	}
+	pkg := os.Args[2]
+
+	c, err := client.Dial("exitbox-tool")
+	if err != nil {
+		if errors.Is(err, client.ErrUnavailable) {
+			err = fmt.Errorf("IPC socket not available. Tool requests require firewall mode")
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var resp toolResponse
+	err = c.Call("tool_request", toolPayload{Package: pkg}, &resp)
+	_ = c.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", resp.Error)
+		os.Exit(1)
+	}
+	if !resp.Approved {
+		fmt.Fprintln(os.Stderr, "Denied by user.")
+		os.Exit(1)
+	}
+	if resp.Queued {
+		fmt.Printf("Added %s to workspace '%s'.\n", pkg, resp.Workspace)
+	} else {
+		fmt.Printf("%s is already in workspace '%s'.\n", pkg, resp.Workspace)
+	}
+	fmt.Println("It is installed when the image is rebuilt: on the next run, or after restarting the session from the workspace menu.")
+}
@@ -24,28 +24,21 @@
 //	exitbox-vault set <KEY> <VALUE>  # stores a secret in the vault
 //	exitbox-vault list               # prints key names, one per line
 //	exitbox-vault env                # prints KEY=VALUE pairs (for eval)
+//	exitbox-vault exec [--map VAR=KEY]... [--from FILE] -- <command> [args...]
 package main
 
 import (
 	"bufio"
-	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"os"
-)
-
-type request struct {
-	Type    string      `json:"type"`
-	ID      string      `json:"id"`
-	Payload interface{} `json:"payload"`
-}
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
 
-type response struct {
-	Type    string          `json:"type"`
-	ID      string          `json:"id"`
-	Payload json.RawMessage `json:"payload"`
-}
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
 
 type vaultGetPayload struct {
 	Key string `json:"key"`
@@ -96,6 +89,8 @@ func main() {
 		cmdList()
 	case "env":
 		cmdEnv()
+	case "exec":
+		cmdExec(os.Args[2:])
 	default:
 		printUsage()
 		os.Exit(1)
@@ -108,6 +103,8 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  exitbox-vault set <KEY> <VALUE>  # stores a secret in the vault")
 	fmt.Fprintln(os.Stderr, "  exitbox-vault list               # prints key names, one per line")
 	fmt.Fprintln(os.Stderr, "  exitbox-vault env                # prints KEY=VALUE pairs (for eval)")
+	fmt.Fprintln(os.Stderr, "  exitbox-vault exec [--map VAR=KEY]... [--from FILE] -- <command> [args...]")
+	fmt.Fprintln(os.Stderr, "                                   # runs command with secrets in its environment")
 }
 
 func cmdGet(key string) {
@@ -204,163 +201,207 @@ func cmdEnv() {
 	}
 }
 
-func sendVaultGet(key string) (*vaultGetResponse, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
+// envNamePattern matches names usable as environment variables.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("IPC socket not available. Vault requires the IPC server to be running")
-	}
-	defer conn.Close()
+// envMapping maps an environment variable of the child to a vault key.
+type envMapping struct {
+	Var string
+	Key string
+}
 
-	id := randomID()
-	req := request{
-		Type: "vault_get",
-		ID:   id,
-		Payload: vaultGetPayload{
-			Key: key,
-		},
+// parseMapping parses "VAR=KEY", or a bare "KEY" for a variable of the
+// same name.
+func parseMapping(s string) (envMapping, error) {
+	v, k, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok {
+		k = v
+	}
+	v, k = strings.TrimSpace(v), strings.TrimSpace(k)
+	if !envNamePattern.MatchString(v) || k == "" {
+		return envMapping{}, fmt.Errorf("invalid mapping %q (want VAR=KEY)", s)
 	}
+	return envMapping{Var: v, Key: k}, nil
+}
 
-	data, err := json.Marshal(req)
+// readMappingFile reads VAR=KEY lines from path. Blank lines and lines
+// starting with # are skipped.
+func readMappingFile(path string) ([]envMapping, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	data = append(data, '\n')
+	defer f.Close()
 
-	if _, err := conn.Write(data); err != nil {
-		return nil, err
+	var maps []envMapping
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m, err := parseMapping(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		maps = append(maps, m)
 	}
+	return maps, sc.Err()
+}
 
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
+// parseExecArgs splits exec's arguments into mappings and the command.
+func parseExecArgs(args []string) ([]envMapping, []string, error) {
+	var maps []envMapping
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			args = args[1:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if name != "--map" && name != "-m" && name != "--from" && name != "-f" {
+			return nil, nil, fmt.Errorf("unknown flag %s", arg)
+		}
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, nil, fmt.Errorf("%s requires a value", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if name == "--map" || name == "-m" {
+			m, err := parseMapping(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			maps = append(maps, m)
+			continue
 		}
-		return nil, fmt.Errorf("no response from host")
+		fileMaps, err := readMappingFile(value)
+		if err != nil {
+			return nil, nil, err
+		}
+		maps = append(maps, fileMaps...)
 	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return nil, err
+	if len(maps) == 0 {
+		return nil, nil, fmt.Errorf("no secrets mapped (use --map or --from)")
 	}
-
-	var payload vaultGetResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
-		return nil, err
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("no command given")
 	}
-
-	return &payload, nil
+	return maps, args, nil
 }
 
-func sendVaultSet(key, value string) (*vaultSetResponse, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
-
-	conn, err := net.Dial("unix", socketPath)
+// cmdExec fetches the mapped secrets and replaces this process with the
+// command, so the values only ever exist in the command's environment.
+// Each fetched value is registered with the host's output redaction by
+// vault_get itself. Helper tokens are files under client.TokenDir, not
+// environment variables, so the command inherits none of them.
+func cmdExec(args []string) {
+	maps, command, err := parseExecArgs(args)
 	if err != nil {
-		return nil, fmt.Errorf("IPC socket not available. Vault requires the IPC server to be running")
-	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type: "vault_set",
-		ID:   id,
-		Payload: vaultSetPayload{
-			Key:   key,
-			Value: value,
-		},
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-vault exec [--map VAR=KEY]... [--from FILE] -- <command> [args...]")
+		os.Exit(1)
 	}
-
-	data, err := json.Marshal(req)
+	path, err := exec.LookPath(command[0])
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	data = append(data, '\n')
 
-	if _, err := conn.Write(data); err != nil {
-		return nil, err
+	values := make(map[string]string, len(maps))
+	for _, m := range maps {
+		resp, getErr := sendVaultGet(m.Key)
+		if getErr != nil {
+			fmt.Fprintf(os.Stderr, "Error getting %s: %v\n", m.Key, getErr)
+			os.Exit(1)
+		}
+		if resp.Error != "" {
+			fmt.Fprintf(os.Stderr, "Error getting %s: %s\n", m.Key, resp.Error)
+			os.Exit(1)
+		}
+		if !resp.Approved {
+			fmt.Fprintf(os.Stderr, "Denied: %s\n", m.Key)
+			os.Exit(1)
+		}
+		values[m.Var] = resp.Value
+	}
+	if hostConn != nil {
+		hostConn.Close()
 	}
 
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
+	env := make([]string, 0, len(os.Environ())+len(values))
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, mapped := values[name]; !mapped {
+			env = append(env, kv)
 		}
-		return nil, fmt.Errorf("no response from host")
 	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return nil, err
+	for name, value := range values {
+		env = append(env, name+"="+value)
 	}
 
-	var payload vaultSetResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
-		return nil, err
+	if err := syscall.Exec(path, command, env); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
-	return &payload, nil
 }
 
-func sendVaultList() (*vaultListResponse, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
+var hostConn *client.Client
 
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
+// host returns the connection to the host, dialing it on first use so
+// that "env" and "exec" send all of their requests over one connection.
+func host() (*client.Client, error) {
+	if hostConn != nil {
+		return hostConn, nil
+	}
+	c, err := client.Dial("exitbox-vault")
+	if errors.Is(err, client.ErrUnavailable) {
 		return nil, fmt.Errorf("IPC socket not available. Vault requires the IPC server to be running")
 	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type:    "vault_list",
-		ID:      id,
-		Payload: struct{}{},
+	if err != nil {
+		return nil, err
 	}
+	hostConn = c
+	return c, nil
+}
 
-	data, err := json.Marshal(req)
+func sendVaultGet(key string) (*vaultGetResponse, error) {
+	c, err := host()
 	if err != nil {
 		return nil, err
 	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
+	var payload vaultGetResponse
+	if err := c.Call("vault_get", vaultGetPayload{Key: key}, &payload); err != nil {
 		return nil, err
 	}
+	return &payload, nil
+}
 
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
-		}
-		return nil, fmt.Errorf("no response from host")
+func sendVaultSet(key, value string) (*vaultSetResponse, error) {
+	c, err := host()
+	if err != nil {
+		return nil, err
 	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+	var payload vaultSetResponse
+	if err := c.Call("vault_set", vaultSetPayload{Key: key, Value: value}, &payload); err != nil {
 		return nil, err
 	}
+	return &payload, nil
+}
 
+func sendVaultList() (*vaultListResponse, error) {
+	c, err := host()
+	if err != nil {
+		return nil, err
+	}
 	var payload vaultListResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := c.Call("vault_list", struct{}{}, &payload); err != nil {
 		return nil, err
 	}
-
 	return &payload, nil
 }
-
-func randomID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return fmt.Sprintf("%x", b)
-}
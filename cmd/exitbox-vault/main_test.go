@@ -0,0 +1,120 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExecArgs(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "secrets")
+	if err := os.WriteFile(from, []byte("# comment\n\nDB_PASS=db/password\nAPI_TOKEN\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad")
+	if err := os.WriteFile(bad, []byte("OK=ok\n1BAD=key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		maps    []envMapping
+		command []string
+		wantErr bool
+	}{
+		{
+			name:    "map",
+			args:    []string{"--map", "TOKEN=gh/token", "gh", "pr", "list"},
+			maps:    []envMapping{{"TOKEN", "gh/token"}},
+			command: []string{"gh", "pr", "list"},
+		},
+		{
+			name:    "short map with equals",
+			args:    []string{"-m=TOKEN=gh/token", "--", "gh"},
+			maps:    []envMapping{{"TOKEN", "gh/token"}},
+			command: []string{"gh"},
+		},
+		{
+			name:    "bare key",
+			args:    []string{"--map", "API_KEY", "curl"},
+			maps:    []envMapping{{"API_KEY", "API_KEY"}},
+			command: []string{"curl"},
+		},
+		{
+			name:    "from file",
+			args:    []string{"--from", from, "--map", "X=y", "--", "psql"},
+			maps:    []envMapping{{"DB_PASS", "db/password"}, {"API_TOKEN", "API_TOKEN"}, {"X", "y"}},
+			command: []string{"psql"},
+		},
+		{
+			name:    "dash dash keeps command flags",
+			args:    []string{"-m", "A=b", "--", "-x", "--map"},
+			maps:    []envMapping{{"A", "b"}},
+			command: []string{"-x", "--map"},
+		},
+		{name: "bad var name", args: []string{"--map", "1X=key", "cmd"}, wantErr: true},
+		{name: "var with dash", args: []string{"--map", "A-B=key", "cmd"}, wantErr: true},
+		{name: "empty key", args: []string{"--map", "A=", "cmd"}, wantErr: true},
+		{name: "bad name in file", args: []string{"--from", bad, "cmd"}, wantErr: true},
+		{name: "missing file", args: []string{"--from", filepath.Join(dir, "none"), "cmd"}, wantErr: true},
+		{name: "unknown flag", args: []string{"--env", "A=b", "cmd"}, wantErr: true},
+		{name: "flag without value", args: []string{"--map"}, wantErr: true},
+		{name: "no mappings", args: []string{"--", "cmd"}, wantErr: true},
+		{name: "no command", args: []string{"--map", "A=b", "--"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			maps, command, err := parseExecArgs(tc.args)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseExecArgs(%q) = %v, %v, want error", tc.args, maps, command)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExecArgs(%q): %v", tc.args, err)
+			}
+			if !reflect.DeepEqual(maps, tc.maps) {
+				t.Errorf("maps = %v, want %v", maps, tc.maps)
+			}
+			if !reflect.DeepEqual(command, tc.command) {
+				t.Errorf("command = %v, want %v", command, tc.command)
+			}
+		})
+	}
+}
+
+func TestReadMappingFileReportsLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	if err := os.WriteFile(path, []byte("A=a\n# skip\nnot valid=x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := readMappingFile(path)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want := path + ":3:"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err, want)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/platform"
+	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/cloud-exit/exitbox/internal/vault"
 	"github.com/spf13/cobra"
@@ -68,7 +69,7 @@ var infoCmd = &cobra.Command{
 
 		found := false
 		for _, name := range agent.AgentNames {
-			if rt != nil && rt.ImageExists("exitbox-"+name+"-core") {
+			if rt != nil && rt.ImageExists(project.CoreImageName(name)) {
 				fmt.Printf("  • %s (%s)\n", agent.DisplayName(name), name)
 				found = true
 			}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/cloud-exit/exitbox/internal/update"
 	"github.com/spf13/cobra"
@@ -61,7 +62,7 @@ var listCmd = &cobra.Command{
 
 			imageText := "not built"
 			imageColor := ui.Dim
-			if rt != nil && rt.ImageExists("exitbox-"+a.Name+"-core") {
+			if rt != nil && rt.ImageExists(project.CoreImageName(a.Name)) {
 				imageText = "built"
 				imageColor = ui.Green
 			}
@@ -0,0 +1,122 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/policy"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newPolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect auto-approval policies",
+		Long: "Policies allow or deny sandbox requests (domain access, vault reads and\n" +
+			"writes) without prompting. Rules are read from the project, workspace and\n" +
+			"global policy.yaml files, in that order; the first matching rule wins.",
+	}
+	cmd.AddCommand(newPolicyTestCmd())
+	return cmd
+}
+
+func newPolicyTestCmd() *cobra.Command {
+	var (
+		workspaceOverride string
+		agentName         string
+		projectDir        string
+		at                string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "test <type> [target]",
+		Short: "Show how a request would be decided",
+		Long: "Dry-runs a request against the policies for a project, e.g.\n\n" +
+			"  exitbox policy test vault_get GITHUB_TOKEN --agent claude\n" +
+			"  exitbox policy test allow_domain registry.npmjs.org --at 22:30",
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if projectDir == "" {
+				projectDir, _ = os.Getwd()
+			}
+			projectDir, _ = filepath.Abs(projectDir)
+
+			workspaceName, err := resolveSessionsWorkspace(config.LoadOrDefault(), projectDir, workspaceOverride)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			when, err := parsePolicyTime(at)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+
+			req := policy.Request{Type: args[0], Agent: agentName, Project: projectDir, Time: when}
+			if len(args) > 1 {
+				req.Target = args[1]
+			}
+			engine := &policy.Engine{Workspace: workspaceName, ProjectDir: projectDir, Agent: agentName}
+			d, err := engine.Evaluate(req)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+
+			fmt.Printf("Workspace: %s\n", workspaceName)
+			fmt.Printf("Project:   %s\n", projectDir)
+			fmt.Println("Policies:")
+			for _, p := range []string{policy.ProjectFile(projectDir), policy.WorkspaceFile(workspaceName), policy.GlobalFile()} {
+				state := "not found"
+				if _, err := os.Stat(p); err == nil {
+					state = "loaded"
+				}
+				fmt.Printf("  %s (%s)\n", p, state)
+			}
+			fmt.Println()
+			fmt.Printf("Decision:  %s\n", d)
+		},
+	}
+	cmd.Flags().StringVarP(&workspaceOverride, "workspace", "w", "", "Workspace to test (defaults to resolved active workspace)")
+	cmd.Flags().StringVar(&agentName, "agent", "", "Agent making the request: claude|codex|opencode")
+	cmd.Flags().StringVar(&projectDir, "project", "", "Project directory (defaults to the current directory)")
+	cmd.Flags().StringVar(&at, "at", "", "Evaluate at this local time, HH:MM or \"YYYY-MM-DD HH:MM\" (defaults to now)")
+	return cmd
+}
+
+// parsePolicyTime parses the --at flag. An empty value means now.
+func parsePolicyTime(s string) (time.Time, error) {
+	now := time.Now()
+	if s == "" {
+		return now, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("15:04", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --at %q: want HH:MM or \"YYYY-MM-DD HH:MM\"", s)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+}
+
+func init() {
+	rootCmd.AddCommand(newPolicyCmd())
+}
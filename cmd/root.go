@@ -36,6 +36,7 @@ var skipWizardCommands = map[string]bool{
 	"help":       true,
 	"completion": true,
 	"update":     true,
+	"daemon":     true,
 }
 
 var rootCmd = &cobra.Command{
@@ -46,6 +47,10 @@ var rootCmd = &cobra.Command{
 		v, _ := cmd.Flags().GetBool("verbose")
 		ui.Verbose = v
 
+		if err := config.CheckResourcePrefix(config.LoadOrDefault().Settings.ResourcePrefix); err != nil {
+			return err
+		}
+
 		// Trigger setup wizard on first run
 		if !config.ConfigExists() && !skipWizardCommands[cmd.Name()] {
 			ui.Info("No configuration found. Running setup wizard...")
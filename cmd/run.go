@@ -30,12 +30,15 @@ import (
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/image"
+	"github.com/cloud-exit/exitbox/internal/ipc"
+	"github.com/cloud-exit/exitbox/internal/network"
 	"github.com/cloud-exit/exitbox/internal/profile"
 	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/run"
 	"github.com/cloud-exit/exitbox/internal/session"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/cloud-exit/exitbox/internal/update"
+	"github.com/cloud-exit/exitbox/internal/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -74,7 +77,8 @@ Flags (passed after the agent name):
   -t, --tools PKG         Add Alpine packages to the image
   -i, --include-dir DIR   Mount host dir inside /workspace
   -a, --allow-urls DOM    Allow extra domains for this session
-      --ollama            Use host Ollama for local models
+      --local-llm NAME    Use a local model server (see settings.local_llms)
+      --ollama            Shorthand for --local-llm ollama
       --memory SIZE       Container memory limit (default: 8g)
       --cpus COUNT        Container CPU limit (default: 4)
 
@@ -86,7 +90,7 @@ Examples:
   exitbox run claude --resume "feature-x"   Resume session "feature-x" by name
   exitbox run claude -f -e GITHUB_TOKEN=$GITHUB_TOKEN
   exitbox run claude --workspace work
-  exitbox run opencode --ollama --memory 16g --cpus 8`,
+  exitbox run opencode --local-llm ollama --memory 16g --cpus 8`,
 }
 
 func newAgentRunCmd(agentName string) *cobra.Command {
@@ -209,7 +213,13 @@ func runAgent(agentName string, passthrough []string) {
 	var latestVersion atomic.Value
 	containerCmd := container.Cmd(rt)
 	containerName := project.ContainerName(agentName, projectDir)
-	update.RunUpdatePopup(containerCmd, containerName, Version, &wantUpdate, &latestVersion)
+	update.RunUpdatePopup(containerCmd, containerName, Version, updatePrompt(cfg, rt, projectDir, flags.Workspace, containerName), &wantUpdate, &latestVersion)
+
+	// Unlock the upstream proxy password once, outside the run loop, so a
+	// workspace switch does not prompt for it again.
+	if !flags.NoFirewall {
+		unlockUpstreamProxyPassword(cfg, projectDir, flags.Workspace)
+	}
 
 	// Main run loop: re-launches on workspace switch.
 	for {
@@ -240,7 +250,7 @@ func runAgent(agentName string, passthrough []string) {
 			Verbose:           flags.Verbose,
 			StatusBar:         cfg.Settings.StatusBar,
 			Version:           Version,
-			Ollama:            flags.Ollama,
+			LocalLLM:          flags.LocalLLM,
 			Memory:            flags.Memory,
 			CPUs:              flags.CPUs,
 			Keybindings:       cfg.Settings.Keybindings.EnvValue(),
@@ -321,6 +331,33 @@ func runAgent(agentName string, passthrough []string) {
 	}
 }
 
+// unlockUpstreamProxyPassword reads the upstream proxy password from the
+// vault and hands it to the network package, which keeps it in memory only.
+func unlockUpstreamProxyPassword(cfg *config.Config, projectDir, workspaceOverride string) {
+	up := cfg.Settings.UpstreamProxy
+	if up.URL == "" || up.PasswordVaultKey == "" {
+		return
+	}
+	ws := up.VaultWorkspace
+	if ws == "" {
+		active, err := profile.ResolveActiveWorkspace(cfg, projectDir, workspaceOverride)
+		if err == nil && active != nil {
+			ws = active.Workspace.Name
+		}
+	}
+	if ws == "" || !vault.IsInitialized(ws) {
+		ui.Warnf("Upstream proxy: no vault for workspace '%s'; egress through the proxy will fail", ws)
+		return
+	}
+	password := promptPassword(fmt.Sprintf("Enter vault password for '%s' (upstream proxy): ", ws))
+	value, err := vault.QuickGet(ws, password, up.PasswordVaultKey)
+	if err != nil {
+		ui.Warnf("Failed to read upstream proxy password from vault: %v", err)
+		return
+	}
+	network.SetUpstreamProxyPassword(value)
+}
+
 type parsedFlags struct {
 	NoFirewall     bool
 	ReadOnly       bool
@@ -331,16 +368,16 @@ type parsedFlags struct {
 	SessionName    string
 	SessionNameSet bool // true when --name was explicitly passed
 	Verbose        bool
-	ForceUpdate bool
-	Workspace   string
-	Ollama      bool
-	Memory      string
-	CPUs        string
-	EnvVars     []string
-	IncludeDirs []string
-	AllowURLs   []string
-	Tools       []string
-	Remaining   []string
+	ForceUpdate    bool
+	Workspace      string
+	LocalLLM       string
+	Memory         string
+	CPUs           string
+	EnvVars        []string
+	IncludeDirs    []string
+	AllowURLs      []string
+	Tools          []string
+	Remaining      []string
 }
 
 func parseRunFlags(passthrough []string, defaults config.DefaultFlags) parsedFlags {
@@ -407,8 +444,13 @@ func parseRunFlags(passthrough []string, defaults config.DefaultFlags) parsedFla
 				i++
 				f.AllowURLs = append(f.AllowURLs, passthrough[i])
 			}
+		case "--local-llm":
+			if i+1 < len(passthrough) {
+				i++
+				f.LocalLLM = passthrough[i]
+			}
 		case "--ollama":
-			f.Ollama = true
+			f.LocalLLM = config.LocalLLMOllama
 		case "--memory":
 			if i+1 < len(passthrough) {
 				i++
@@ -485,3 +527,23 @@ func init() {
 
 	rootCmd.AddCommand(runCmd)
 }
+
+// updatePrompt returns how to ask about an available update: nil for the
+// default tmux popup, otherwise the workspace's approval backend.
+func updatePrompt(cfg *config.Config, rt container.Runtime, projectDir, workspace, containerName string) func(current, latest string) (bool, error) {
+	active, err := profile.ResolveActiveWorkspace(cfg, projectDir, workspace)
+	if err != nil || active == nil || active.Workspace.Approver == "" || active.Workspace.Approver == ipc.ApproverTmux {
+		return nil
+	}
+	approver, err := ipc.NewApprover(active.Workspace.Approver, rt, containerName)
+	if err != nil {
+		return nil
+	}
+	return func(current, latest string) (bool, error) {
+		return approver.Confirm(context.Background(), ipc.Prompt{
+			Header: "ExitBox",
+			Title:  "Update available. Update after this session?",
+			Fields: []ipc.Field{{Label: "Current", Value: "v" + current}, {Label: "Latest", Value: "v" + latest}},
+		})
+	}
+}
@@ -66,6 +66,17 @@ func TestParseRunFlags_Tools(t *testing.T) {
 	}
 }
 
+func TestParseRunFlags_LocalLLM(t *testing.T) {
+	f := parseRunFlags([]string{"--local-llm", "gpu-box"}, config.DefaultFlags{})
+	if f.LocalLLM != "gpu-box" {
+		t.Errorf("LocalLLM = %q, want %q", f.LocalLLM, "gpu-box")
+	}
+	f = parseRunFlags([]string{"--ollama"}, config.DefaultFlags{})
+	if f.LocalLLM != config.LocalLLMOllama {
+		t.Errorf("--ollama: LocalLLM = %q, want %q", f.LocalLLM, config.LocalLLMOllama)
+	}
+}
+
 func TestParseRunFlags_DoubleDash(t *testing.T) {
 	f := parseRunFlags([]string{"-f", "--", "-r", "extra"}, config.DefaultFlags{})
 	if !f.NoFirewall {
@@ -25,6 +25,7 @@ import (
 	"github.com/cloud-exit/exitbox/internal/agent"
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -63,12 +64,14 @@ var uninstallCmd = &cobra.Command{
 			if rt != nil {
 				// Stop and remove containers
 				ui.Info("Stopping and removing all exitbox containers...")
-				names, psErr := rt.PS("name=exitbox-", "{{.ID}}")
+				names, psErr := rt.PS("name="+config.Namespace()+"-", "{{.Names}}")
 				if psErr != nil {
 					ui.Warnf("Failed to list containers: %v", psErr)
 				}
-				for _, id := range names {
-					_ = rt.Remove(id)
+				for _, name := range names {
+					if project.IsOwnContainer(name) {
+						_ = rt.Remove(name)
+					}
 				}
 
 				// Remove images
@@ -133,7 +136,7 @@ var uninstallCmd = &cobra.Command{
 }
 
 func removeAgentImages(rt container.Runtime, agentName string) {
-	images, err := rt.ImageList("exitbox-" + agentName + "-*")
+	images, err := rt.ImageList(config.Namespace() + "-" + agentName + "-*")
 	if err != nil {
 		ui.Warnf("Failed to list %s images: %v", agentName, err)
 		return
@@ -146,7 +149,8 @@ func removeAgentImages(rt container.Runtime, agentName string) {
 func cleanImages(rt container.Runtime, mode string) {
 	switch mode {
 	case "all":
-		images, err := rt.ImageList("exitbox-*")
+		// Only this user's images; base and proxy images are shared.
+		images, err := rt.ImageList(config.Namespace() + "-*")
 		if err != nil {
 			ui.Warnf("Failed to list exitbox images: %v", err)
 			return
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 )
 
@@ -58,9 +59,12 @@ func (r *testRuntime) ImageRemove(img string) error {
 func (r *testRuntime) PS(_, _ string) ([]string, error)       { return nil, nil }
 func (r *testRuntime) Stop(_ string) error                    { return nil }
 func (r *testRuntime) Remove(_ string) error                  { return nil }
-func (r *testRuntime) NetworkCreate(_ string, _ bool) error   { return nil }
+func (r *testRuntime) NetworkCreate(_ string, _ bool, _ string) error { return nil }
 func (r *testRuntime) NetworkExists(_ string) bool            { return false }
 func (r *testRuntime) NetworkConnect(_, _ string) error       { return nil }
+func (r *testRuntime) NetworkDisconnect(_, _ string) error    { return nil }
+func (r *testRuntime) NetworkList() ([]string, error)         { return nil, nil }
+func (r *testRuntime) NetworkRemove(_ string) error           { return nil }
 func (r *testRuntime) NetworkInspect(_, _ string) (string, error) { return "", nil }
 func (r *testRuntime) IsRootless() bool                       { return false }
 
@@ -68,11 +72,12 @@ func (r *testRuntime) IsRootless() bool                       { return false }
 var _ container.Runtime = (*testRuntime)(nil)
 
 func TestRemoveAgentImages(t *testing.T) {
+	ns := config.Namespace()
 	rt := newTestRuntime(
-		"exitbox-claude-core",
-		"exitbox-claude-project1",
-		"exitbox-claude-project2",
-		"exitbox-codex-core",
+		ns+"-claude-core",
+		ns+"-claude-project1",
+		ns+"-claude-project2",
+		ns+"-codex-core",
 	)
 
 	removeAgentImages(rt, "claude")
@@ -83,23 +88,26 @@ func TestRemoveAgentImages(t *testing.T) {
 	}
 
 	// codex image should still exist
-	if !rt.images["exitbox-codex-core"] {
+	if !rt.images[ns+"-codex-core"] {
 		t.Error("codex image should not have been removed")
 	}
 }
 
 func TestCleanImagesAll(t *testing.T) {
+	ns := config.Namespace()
 	rt := newTestRuntime(
-		"exitbox-claude-core",
-		"exitbox-codex-core",
+		ns+"-claude-core",
+		ns+"-codex-core",
 		"exitbox-base",
 		"exitbox-squid",
-		"exitbox-claude-project1",
+		ns+"-claude-project1",
+		"someone-else-claude-core",
 	)
 
 	cleanImages(rt, "all")
 
-	if len(rt.images) != 0 {
-		t.Errorf("expected all images removed, got %d remaining: %v", len(rt.images), rt.images)
+	// Shared images and other users' images are left alone.
+	if len(rt.images) != 3 || !rt.images["exitbox-base"] || !rt.images["exitbox-squid"] || !rt.images["someone-else-claude-core"] {
+		t.Errorf("expected only this user's images removed, got %d remaining: %v", len(rt.images), rt.images)
 	}
 }
@@ -234,3 +234,13 @@ func FetchAndSearch(query string) ([]Package, error) {
 	}
 	return Search(index, query, 50), nil
 }
+
+// Find returns the package called name, matched exactly.
+func Find(index []Package, name string) (Package, bool) {
+	for _, p := range index {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
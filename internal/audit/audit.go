@@ -0,0 +1,233 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package audit keeps an append-only, hash-chained JSONL log of security
+// relevant events: approvals, vault and KV access, firewall changes and
+// mounts. Each event carries the hash of the one before it, so editing or
+// removing an event breaks the chain for every event after it.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/flock"
+)
+
+// Event types.
+const (
+	SessionStart   = "session_start"
+	SessionEnd     = "session_end"
+	Mount          = "mount"
+	FirewallReload = "firewall_reload"
+	IPCRejected    = "ipc_rejected"
+)
+
+// Outcomes.
+const (
+	Approved = "approved"
+	Denied   = "denied"
+	OK       = "ok"
+	Failed   = "failed"
+)
+
+// genesis is the previous hash of the first event in a log.
+const genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Event is one line of the audit log.
+type Event struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Session   string    `json:"session,omitempty"` // container name
+	Workspace string    `json:"workspace,omitempty"`
+	Agent     string    `json:"agent,omitempty"`
+	Project   string    `json:"project,omitempty"`
+	Type      string    `json:"type"`             // IPC message type or one of the constants above
+	Target    string    `json:"target,omitempty"` // domain, key, path, ...
+	Outcome   string    `json:"outcome,omitempty"`
+	Detail    string    `json:"detail,omitempty"` // who decided, error text, ...
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash"`
+}
+
+// File returns the path to the audit log.
+func File() string {
+	return filepath.Join(config.Data, "audit.jsonl")
+}
+
+// Log appends events for one session. A nil *Log discards events, so
+// callers need not check whether auditing is set up.
+type Log struct {
+	Path      string
+	Session   string
+	Workspace string
+	Agent     string
+	Project   string
+}
+
+// Record appends an event, filling in the session fields, sequence number,
+// time and hashes. The file is locked while appending so concurrent
+// sessions keep a single chain.
+func (l *Log) Record(ev Event) error {
+	if l == nil {
+		return nil
+	}
+	ev.Session, ev.Workspace, ev.Agent, ev.Project = l.Session, l.Workspace, l.Agent, l.Project
+	ev.Time = time.Now().UTC()
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := flock.Lock(f); err != nil {
+		return err
+	}
+	defer func() { _ = flock.Unlock(f) }()
+
+	ev.Prev = genesis
+	last, err := lastLine(f)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		var prev Event
+		if err := json.Unmarshal(last, &prev); err != nil {
+			return fmt.Errorf("audit log %s: last event unreadable: %w", l.Path, err)
+		}
+		ev.Seq = prev.Seq + 1
+		ev.Prev = prev.Hash
+	}
+	ev.Hash, err = hashEvent(ev)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// hashEvent hashes ev with its Hash field cleared. Prev is part of the
+// hashed data, which is what chains the events together.
+func hashEvent(ev Event) (string, error) {
+	ev.Hash = ""
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastLine returns the last non-empty line of f, or nil if f is empty.
+func lastLine(f *os.File) ([]byte, error) {
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var tail []byte
+	const chunk = 4096
+	for pos := end; pos > 0; {
+		n := int64(chunk)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, pos); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+	}
+	if trimmed := bytes.TrimRight(tail, "\n"); len(trimmed) > 0 {
+		return trimmed, nil
+	}
+	return nil, nil
+}
+
+// Read returns every event in the log at path. A missing log has no events.
+func Read(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+// Verify checks the hash chain of the log at path and returns the number
+// of events checked. The error names the first event that does not match.
+func Verify(path string) (int, error) {
+	events, err := Read(path)
+	if err != nil {
+		return 0, err
+	}
+	prev := genesis
+	for i, ev := range events {
+		if ev.Prev != prev {
+			return i, fmt.Errorf("event %d (seq %d): previous hash does not match; an event before it was changed or removed", i+1, ev.Seq)
+		}
+		if ev.Seq != int64(i) {
+			return i, fmt.Errorf("event %d: sequence %d, want %d", i+1, ev.Seq, i)
+		}
+		sum, err := hashEvent(ev)
+		if err != nil {
+			return i, err
+		}
+		if sum != ev.Hash {
+			return i, fmt.Errorf("event %d (seq %d): hash does not match its contents", i+1, ev.Seq)
+		}
+		prev = ev.Hash
+	}
+	return len(events), nil
+}
@@ -0,0 +1,112 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecordAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := &Log{Path: path, Session: "exitbox-1000-claude-proj-abcd1234", Agent: "claude"}
+
+	if err := l.Record(Event{Type: SessionStart, Outcome: OK}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := l.Record(Event{Type: "vault_get", Target: "GITHUB_TOKEN", Outcome: Approved, Detail: "user"}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	events, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Prev != genesis || events[1].Prev != events[0].Hash {
+		t.Errorf("events are not chained: %+v", events)
+	}
+	if events[1].Seq != 1 || events[1].Session != l.Session || events[1].Agent != "claude" {
+		t.Errorf("event 1 = %+v", events[1])
+	}
+	if n, err := Verify(path); err != nil || n != 2 {
+		t.Errorf("Verify = %d, %v; want 2, nil", n, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	for name, tamper := range map[string]func(lines []string) []string{
+		"edited": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"outcome":"denied"`, `"outcome":"approved"`, 1)
+			return lines
+		},
+		"removed": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			l := &Log{Path: path}
+			for _, outcome := range []string{OK, Denied, OK} {
+				if err := l.Record(Event{Type: "allow_domain", Target: "example.com", Outcome: outcome}); err != nil {
+					t.Fatalf("Record: %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tamper(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := Verify(path)
+			if err == nil {
+				t.Fatal("Verify succeeded on a tampered log")
+			}
+			if n != 1 {
+				t.Errorf("Verify reported %d good events, want 1", n)
+			}
+		})
+	}
+}
+
+func TestRecordConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := &Log{Path: path}
+			if err := l.Record(Event{Type: "kv_set", Outcome: OK}); err != nil {
+				t.Errorf("Record: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, err := Verify(path); err != nil || n != 20 {
+		t.Errorf("Verify = %d, %v; want 20, nil", n, err)
+	}
+}
+
+func TestNilLogDiscards(t *testing.T) {
+	var l *Log
+	if err := l.Record(Event{Type: SessionStart}); err != nil {
+		t.Errorf("nil Log Record: %v", err)
+	}
+}
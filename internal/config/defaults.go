@@ -71,6 +71,17 @@ func DefaultConfig() *Config {
 	}
 }
 
+// DefaultAgentProviders maps each agent to the AI provider domains it may
+// reach. OpenCode talks to whichever providers it is configured for; those
+// hosts are added at run time from its config.
+func DefaultAgentProviders() map[string][]string {
+	return map[string][]string{
+		"claude":   {"anthropic.com", "claude.ai", "claude.com"},
+		"codex":    {"openai.com", "chatgpt.com", "oaiusercontent.com"},
+		"opencode": {"opencode.ai", "models.dev"},
+	}
+}
+
 // DefaultAllowlist returns the default domain allowlist.
 func DefaultAllowlist() *Allowlist {
 	return &Allowlist{
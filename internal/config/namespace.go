@@ -0,0 +1,69 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	resourcePrefixPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+	userPrefixPattern     = regexp.MustCompile(`^exitbox-[0-9]+(-|$)`)
+)
+
+// Namespace returns the prefix of the containers, networks, volumes and
+// images this user owns, so that users sharing one rootful Docker daemon do
+// not collide. It is "exitbox-<uid>" unless settings.resource_prefix names
+// another valid one (see CheckResourcePrefix).
+func Namespace() string {
+	if p := LoadOrDefault().Settings.ResourcePrefix; p != "" && CheckResourcePrefix(p) == nil {
+		return p
+	}
+	return defaultNamespace()
+}
+
+func defaultNamespace() string {
+	if uid := os.Getuid(); uid >= 0 {
+		return fmt.Sprintf("exitbox-%d", uid)
+	}
+	return "exitbox"
+}
+
+// CheckResourcePrefix validates settings.resource_prefix. Besides the
+// character set, it rejects prefixes that would cover other users'
+// resources: "exitbox" itself or any prefix of it (whose "<prefix>-*"
+// filters match everyone's names), and "exitbox-<uid>" or anything under
+// it, such as "exitbox-1000-claude", whose filters match that user's
+// containers and images. An empty prefix means the default.
+func CheckResourcePrefix(p string) error {
+	if p == "" || p == defaultNamespace() {
+		return nil
+	}
+	if !resourcePrefixPattern.MatchString(p) {
+		return fmt.Errorf("invalid settings.resource_prefix %q: use lowercase letters, digits, '_', '.' and '-'", p)
+	}
+	if strings.HasPrefix("exitbox", p) {
+		return fmt.Errorf("invalid settings.resource_prefix %q: it would match every user's exitbox resources", p)
+	}
+	if userPrefixPattern.MatchString(p) {
+		return fmt.Errorf("invalid settings.resource_prefix %q: it overlaps a user's default exitbox-<uid> namespace", p)
+	}
+	return nil
+}
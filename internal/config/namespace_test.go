@@ -0,0 +1,76 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	origHome := Home
+	Home = t.TempDir()
+	defer func() { Home = origHome }()
+
+	if got, want := Namespace(), fmt.Sprintf("exitbox-%d", os.Getuid()); got != want {
+		t.Errorf("default Namespace() = %q, want %q", got, want)
+	}
+
+	for prefix, want := range map[string]string{
+		"exitbox-alice": "exitbox-alice",
+		"ci.runner_2":   "ci.runner_2",
+		"Bad Prefix":    fmt.Sprintf("exitbox-%d", os.Getuid()),
+		"-leading":      fmt.Sprintf("exitbox-%d", os.Getuid()),
+		"exitbox":       fmt.Sprintf("exitbox-%d", os.Getuid()),
+	} {
+		cfg := DefaultConfig()
+		cfg.Settings.ResourcePrefix = prefix
+		if err := SaveConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if got := Namespace(); got != want {
+			t.Errorf("Namespace() with resource_prefix %q = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestCheckResourcePrefix(t *testing.T) {
+	own := fmt.Sprintf("exitbox-%d", os.Getuid())
+	other := fmt.Sprintf("exitbox-%d", os.Getuid()+1)
+	for prefix, ok := range map[string]bool{
+		"":                    true,
+		own:                   true,
+		"exitbox-alice":       true,
+		"ci.runner_2":         true,
+		"exitbox":             false,
+		"exit":                false,
+		"e":                   false,
+		other:                 false,
+		other + "-":           false,
+		other + "-claude":     false,
+		own + "-claude":       false,
+		"exitbox-1000-claude": false,
+		"exitbox-":            true,
+		"exitbox-1000x":       true,
+		"Bad Prefix":          false,
+	} {
+		if err := CheckResourcePrefix(prefix); (err == nil) != ok {
+			t.Errorf("CheckResourcePrefix(%q) = %v, want ok=%v", prefix, err, ok)
+		}
+	}
+}
@@ -92,6 +92,11 @@ func AllowlistFile() string {
 	return filepath.Join(Home, "allowlist.yaml")
 }
 
+// HostFile returns the path to host.yaml.
+func HostFile() string {
+	return filepath.Join(Home, "host.yaml")
+}
+
 // ProjectsDir returns the path to the projects directory.
 func ProjectsDir() string {
 	return filepath.Join(Home, "projects")
@@ -47,6 +47,13 @@ type Workspace struct {
 	Packages    []string    `yaml:"packages,omitempty"`
 	Directory   string      `yaml:"directory,omitempty"`
 	Vault       VaultConfig `yaml:"vault,omitempty"`
+	Deny        []string    `yaml:"deny,omitempty"`
+	// CACertificates lists PEM files trusted in this workspace's image, on
+	// top of settings.ca_certificates.
+	CACertificates []string `yaml:"ca_certificates,omitempty"`
+	// Approver selects how approval prompts are shown: tmux (default),
+	// terminal, desktop or queue.
+	Approver string `yaml:"approver,omitempty"`
 }
 
 // AgentConfig holds enable/disable state for each agent.
@@ -75,14 +82,89 @@ type BinaryConfig struct {
 
 // SettingsConfig holds global settings.
 type SettingsConfig struct {
-	AutoUpdate       bool              `yaml:"auto_update"`
-	StatusBar        bool              `yaml:"status_bar"`
-	RTK              bool              `yaml:"rtk"`
+	AutoUpdate       bool          `yaml:"auto_update"`
+	StatusBar        bool          `yaml:"status_bar"`
+	RTK              bool          `yaml:"rtk"`
+	FirewallBackend  string        `yaml:"firewall_backend,omitempty"`  // "squid" (default) or "builtin"
+	NetworkIsolation string        `yaml:"network_isolation,omitempty"` // "session" (default) or "workspace"
+	SNIEnforcement   bool          `yaml:"sni_enforcement,omitempty"`
+	UpstreamProxy    UpstreamProxy `yaml:"upstream_proxy,omitempty"`
+	CACertificates   []string      `yaml:"ca_certificates,omitempty"` // PEM files trusted in all images
+	ProxyCache       ProxyCache    `yaml:"proxy_cache,omitempty"`
+	LocalLLMs        []LocalLLM    `yaml:"local_llms,omitempty"`
+	// SharedAIProviders gives every agent the full ai_providers list instead
+	// of only its own provider domains.
+	SharedAIProviders bool `yaml:"shared_ai_providers,omitempty"`
+	// AgentSubnetPool is the IPv4 range agent networks get their subnets
+	// from (default 10.213.0.0/16, see network.AgentSubnetPool).
+	AgentSubnetPool string `yaml:"agent_subnet_pool,omitempty"`
+	// ResourcePrefix replaces the per-user "exitbox-<uid>" prefix of
+	// container, network and volume names (see Namespace).
+	ResourcePrefix string `yaml:"resource_prefix,omitempty"`
+	// NoDaemon runs firewall operations in each exitbox process instead of
+	// the exitboxd coordinator daemon.
+	NoDaemon         bool              `yaml:"no_daemon,omitempty"`
 	DefaultWorkspace string            `yaml:"default_workspace,omitempty"`
 	DefaultFlags     DefaultFlags      `yaml:"default_flags"`
 	Keybindings      KeybindingsConfig `yaml:"keybindings,omitempty"`
 }
 
+// Firewall backends for SettingsConfig.FirewallBackend.
+const (
+	FirewallSquid   = "squid"
+	FirewallBuiltin = "builtin"
+)
+
+// Agent network isolation modes for SettingsConfig.NetworkIsolation.
+const (
+	NetworkPerSession   = "session"
+	NetworkPerWorkspace = "workspace"
+)
+
+// UpstreamProxy routes all firewall egress through a parent HTTP proxy.
+type UpstreamProxy struct {
+	URL     string   `yaml:"url,omitempty"`      // e.g. http://user@proxy.corp:8080
+	NoProxy []string `yaml:"no_proxy,omitempty"` // destinations reached directly
+	// PasswordVaultKey names the vault key holding the proxy password.
+	// The vault of VaultWorkspace is used, or the active workspace's if empty.
+	PasswordVaultKey string `yaml:"password_vault_key,omitempty"`
+	VaultWorkspace   string `yaml:"vault_workspace,omitempty"`
+}
+
+// ProxyCache enables a persistent package cache: a registry mirror shared
+// by all sessions that fetches through the firewall proxy.
+type ProxyCache struct {
+	Enabled bool `yaml:"enabled"`
+	SizeMB  int  `yaml:"size_mb,omitempty"` // on-disk cache size (default 10240)
+}
+
+// LocalLLM is a named local model server selectable with --local-llm.
+type LocalLLM struct {
+	Name string `yaml:"name"`
+	// Type is the server software: ollama, lmstudio, vllm or llamacpp.
+	// It selects the default port and the API the agents are pointed at.
+	Type string `yaml:"type"`
+	Host string `yaml:"host,omitempty"` // host as seen from the host (default 127.0.0.1)
+	Port int    `yaml:"port,omitempty"` // default depends on Type
+	// API is the wire protocol the server speaks: anthropic or openai.
+	// When empty it defaults from Type.
+	API string `yaml:"api,omitempty"`
+}
+
+// Local LLM server types for LocalLLM.Type.
+const (
+	LocalLLMOllama   = "ollama"
+	LocalLLMLMStudio = "lmstudio"
+	LocalLLMVLLM     = "vllm"
+	LocalLLMLlamaCpp = "llamacpp"
+)
+
+// Local LLM APIs for LocalLLM.API.
+const (
+	LocalLLMAPIAnthropic = "anthropic"
+	LocalLLMAPIOpenAI    = "openai"
+)
+
 // KeybindingsConfig holds configurable tmux keybinding overrides.
 type KeybindingsConfig struct {
 	WorkspaceMenu string `yaml:"workspace_menu,omitempty"`
@@ -91,13 +173,50 @@ type KeybindingsConfig struct {
 
 // DefaultFlags holds the default CLI flag values.
 type DefaultFlags struct {
-	NoFirewall      bool   `yaml:"no_firewall"`
-	ReadOnly        bool   `yaml:"read_only"`
-	NoEnv           bool   `yaml:"no_env"`
-	AutoResume      bool   `yaml:"auto_resume"`
-	FullGitSupport  bool   `yaml:"full_git_support"`
-	Memory          string `yaml:"memory,omitempty"`
-	CPUs            string `yaml:"cpus,omitempty"`
+	NoFirewall     bool   `yaml:"no_firewall"`
+	ReadOnly       bool   `yaml:"read_only"`
+	NoEnv          bool   `yaml:"no_env"`
+	AutoResume     bool   `yaml:"auto_resume"`
+	FullGitSupport bool   `yaml:"full_git_support"`
+	Memory         string `yaml:"memory,omitempty"`
+	CPUs           string `yaml:"cpus,omitempty"`
+}
+
+// HostConfig holds settings that make ExitBox run commands on the host
+// (host.yaml). Like config.yaml, it is never mounted into containers.
+type HostConfig struct {
+	Notify  NotifyConfig  `yaml:"notify,omitempty"`
+	OpenURL OpenURLConfig `yaml:"open_url,omitempty"`
+	// HostActions are the commands exitbox-host may run, by name.
+	HostActions map[string]HostAction `yaml:"host_actions,omitempty"`
+}
+
+// NotifyConfig controls how exitbox-notify messages reach the user.
+type NotifyConfig struct {
+	// Methods is any of desktop, bell and hook; empty means desktop and bell.
+	Methods []string `yaml:"methods,omitempty"`
+	// Hook is the command (program and arguments) run by the hook method.
+	Hook []string `yaml:"hook,omitempty"`
+	// Interval is the minimum time between notifications from one session,
+	// e.g. "30s". Default 10s.
+	Interval string `yaml:"interval,omitempty"`
+}
+
+// OpenURLConfig controls how exitbox-open URLs are opened on the host.
+type OpenURLConfig struct {
+	// NoCallbackRelay stops OAuth redirects to localhost:<port> from being
+	// relayed into the container.
+	NoCallbackRelay bool `yaml:"no_callback_relay,omitempty"`
+}
+
+// HostAction is a fixed host command that exitbox-host can ask to run. The
+// container only names the action; it cannot add arguments.
+type HostAction struct {
+	// Command is the program and its arguments.
+	Command []string `yaml:"command"`
+	// Dir is the working directory; empty means the project directory.
+	Dir         string `yaml:"dir,omitempty"`
+	Description string `yaml:"description,omitempty"`
 }
 
 // Allowlist is the domain allowlist (allowlist.yaml).
@@ -108,6 +227,61 @@ type Allowlist struct {
 	CloudServices  []string `yaml:"cloud_services"`
 	CommonServices []string `yaml:"common_services"`
 	Custom         []string `yaml:"custom,omitempty"`
+	// Deny lists hosts that are blocked even when an allow category covers
+	// them. "host.example.com" blocks that host only; "*.example.com"
+	// blocks the domain and all of its subdomains.
+	Deny []string `yaml:"deny,omitempty"`
+	// AgentProviders overrides which ai_providers domains each agent may
+	// reach (see DefaultAgentProviders).
+	AgentProviders map[string][]string `yaml:"agent_providers,omitempty"`
+}
+
+// ProvidersFor returns the AI provider domains the named agent may reach.
+func (a *Allowlist) ProvidersFor(agent string) []string {
+	if domains, ok := a.AgentProviders[agent]; ok {
+		return domains
+	}
+	return DefaultAgentProviders()[agent]
+}
+
+// SharedDomains returns the domains every agent may reach when AI
+// providers are restricted per agent: all categories, minus the
+// ai_providers domains that belong to an agent (see ProvidersFor, both the
+// defaults and agent_providers). Provider domains no agent claims, and
+// those that also appear in another category, stay shared.
+func (a *Allowlist) SharedDomains() []string {
+	claimed := make(map[string]bool)
+	for _, providers := range []map[string][]string{DefaultAgentProviders(), a.AgentProviders} {
+		for _, domains := range providers {
+			for _, d := range domains {
+				claimed[d] = true
+			}
+		}
+	}
+	var unclaimed []string
+	for _, d := range a.AIProviders {
+		if !claimed[d] {
+			unclaimed = append(unclaimed, d)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	var result []string
+	for _, list := range [][]string{
+		unclaimed,
+		a.Development,
+		a.CloudServices,
+		a.CommonServices,
+		a.Custom,
+	} {
+		for _, d := range list {
+			if _, ok := seen[d]; !ok {
+				seen[d] = struct{}{}
+				result = append(result, d)
+			}
+		}
+	}
+	return result
 }
 
 // AllDomains returns all domains flattened and deduplicated.
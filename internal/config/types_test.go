@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -217,3 +218,41 @@ func TestVaultConfig_YAMLRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestProvidersFor(t *testing.T) {
+	al := DefaultAllowlist()
+	if got := al.ProvidersFor("codex"); len(got) == 0 || got[0] != "openai.com" {
+		t.Errorf("default codex providers = %v", got)
+	}
+	if got := al.ProvidersFor("unknown"); got != nil {
+		t.Errorf("unknown agent providers = %v, want nil", got)
+	}
+
+	al.AgentProviders = map[string][]string{"claude": {"bedrock.example.com"}}
+	if got := al.ProvidersFor("claude"); len(got) != 1 || got[0] != "bedrock.example.com" {
+		t.Errorf("overridden claude providers = %v", got)
+	}
+}
+
+func TestSharedDomains_ExcludesAIProviders(t *testing.T) {
+	al := &Allowlist{
+		AIProviders:   []string{"anthropic.com", "amazonaws.com"},
+		CloudServices: []string{"amazonaws.com"},
+		Custom:        []string{"example.com"},
+	}
+	got := al.SharedDomains()
+	if len(got) != 2 || got[0] != "amazonaws.com" || got[1] != "example.com" {
+		t.Errorf("SharedDomains() = %v, want [amazonaws.com example.com]", got)
+	}
+}
+
+func TestSharedDomains_KeepsUnclaimedAIProviders(t *testing.T) {
+	al := &Allowlist{
+		AIProviders:    []string{"anthropic.com", "openai.com", "huggingface.co", "localhost", "bedrock.example.com"},
+		AgentProviders: map[string][]string{"claude": {"bedrock.example.com"}},
+	}
+	got := strings.Join(al.SharedDomains(), " ")
+	if got != "huggingface.co localhost" {
+		t.Errorf("SharedDomains() = %q, want only the provider domains no agent claims", got)
+	}
+}
@@ -17,6 +17,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -135,6 +137,59 @@ func SaveConfigTo(cfg *Config, path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// ContainerConfig returns the copy of cfg that is mounted into containers.
+// It carries only what the in-container workspace menu reads and writes:
+// workspace names and directories, and the active and default workspace.
+// Denylists, CA certificates, approvers and firewall settings stay on the
+// host, so an agent cannot weaken them for later sessions.
+func ContainerConfig(cfg *Config) *Config {
+	view := &Config{Version: cfg.Version}
+	view.Workspaces.Active = cfg.Workspaces.Active
+	for _, w := range cfg.Workspaces.Items {
+		view.Workspaces.Items = append(view.Workspaces.Items, Workspace{Name: w.Name, Directory: w.Directory})
+	}
+	view.Settings.DefaultWorkspace = cfg.Settings.DefaultWorkspace
+	return view
+}
+
+// LoadContainerConfig reads a container's copy of config.yaml as written,
+// without defaults or migrations.
+func LoadContainerConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ApplyContainerSelection copies the active and default workspace chosen
+// in a container's copy into cfg, ignoring names that are not workspaces
+// of cfg. It reports whether cfg changed.
+func ApplyContainerSelection(cfg, view *Config) bool {
+	known := func(name string) bool {
+		for _, w := range cfg.Workspaces.Items {
+			if w.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	changed := false
+	if a := view.Workspaces.Active; a != cfg.Workspaces.Active && known(a) {
+		cfg.Workspaces.Active = a
+		changed = true
+	}
+	if d := view.Settings.DefaultWorkspace; d != cfg.Settings.DefaultWorkspace && known(d) {
+		cfg.Settings.DefaultWorkspace = d
+		changed = true
+	}
+	return changed
+}
+
 // LoadAllowlist reads and parses allowlist.yaml.
 func LoadAllowlist() (*Allowlist, error) {
 	return LoadAllowlistFrom(AllowlistFile())
@@ -167,6 +222,28 @@ func SaveAllowlistTo(al *Allowlist, path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// LoadHostConfig reads host.yaml. A missing file yields an empty config.
+func LoadHostConfig() (*HostConfig, error) {
+	return LoadHostConfigFrom(HostFile())
+}
+
+// LoadHostConfigFrom reads a host.yaml at path. A missing file yields an
+// empty config.
+func LoadHostConfigFrom(path string) (*HostConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &HostConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var hc HostConfig
+	if err := yaml.Unmarshal(data, &hc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &hc, nil
+}
+
 // LoadOrDefault loads config or returns defaults if file doesn't exist.
 func LoadOrDefault() *Config {
 	cfg, err := LoadConfig()
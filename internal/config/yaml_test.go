@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,3 +105,54 @@ agents:
 		t.Fatalf("expected default workspace 'myws', got %q", cfg.Settings.DefaultWorkspace)
 	}
 }
+
+func TestContainerConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Workspaces.Active = "work"
+	cfg.Workspaces.Items = []Workspace{
+		{Name: "work", Directory: "/src/work", Deny: []string{"pastebin.com"}, CACertificates: []string{"/etc/corp.pem"}, Approver: "desktop"},
+		{Name: "home"},
+	}
+	cfg.Settings.DefaultWorkspace = "work"
+	cfg.Settings.SNIEnforcement = true
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := SaveConfigTo(ContainerConfig(cfg), path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"pastebin.com", "corp.pem", "desktop", "sni_enforcement: true"} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("container config contains %q:\n%s", leak, data)
+		}
+	}
+
+	// The container switches workspace, names an unknown default and clears
+	// its deny list; only the switch reaches the real config.
+	view, err := LoadContainerConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Workspaces.Items[0].Directory != "/src/work" {
+		t.Errorf("directory not carried: %+v", view.Workspaces.Items[0])
+	}
+	view.Workspaces.Active = "home"
+	view.Settings.DefaultWorkspace = "evil"
+	view.Workspaces.Items[0].Deny = nil
+
+	if !ApplyContainerSelection(cfg, view) {
+		t.Fatal("selection not applied")
+	}
+	if cfg.Workspaces.Active != "home" || cfg.Settings.DefaultWorkspace != "work" {
+		t.Errorf("active = %q, default = %q", cfg.Workspaces.Active, cfg.Settings.DefaultWorkspace)
+	}
+	if len(cfg.Workspaces.Items[0].Deny) != 1 {
+		t.Errorf("deny list changed: %+v", cfg.Workspaces.Items[0])
+	}
+	if ApplyContainerSelection(cfg, view) {
+		t.Error("unchanged selection reported a change")
+	}
+}
@@ -81,7 +81,7 @@ func (m *MockRuntime) PS(filter, format string) ([]string, error) {
 func (m *MockRuntime) Stop(_ string) error  { return nil }
 func (m *MockRuntime) Remove(_ string) error { return nil }
 
-func (m *MockRuntime) NetworkCreate(name string, _ bool) error {
+func (m *MockRuntime) NetworkCreate(name string, _ bool, _ string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.Networks[name] = true
@@ -94,7 +94,25 @@ func (m *MockRuntime) NetworkExists(name string) bool {
 	return m.Networks[name]
 }
 
+func (m *MockRuntime) NetworkList() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for n := range m.Networks {
+		names = append(names, n)
+	}
+	return names, nil
+}
+
+func (m *MockRuntime) NetworkRemove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Networks, name)
+	return nil
+}
+
 func (m *MockRuntime) NetworkConnect(_, _ string) error           { return nil }
+func (m *MockRuntime) NetworkDisconnect(_, _ string) error        { return nil }
 func (m *MockRuntime) NetworkInspect(_, _ string) (string, error) { return "", nil }
 func (m *MockRuntime) IsRootless() bool                           { return false }
 
@@ -37,10 +37,14 @@ type Runtime interface {
 	PS(filter, format string) ([]string, error)
 	Stop(container string) error
 	Remove(container string) error
-	NetworkCreate(name string, internal bool) error
+	// NetworkCreate creates a network; subnet is optional (CIDR).
+	NetworkCreate(name string, internal bool, subnet string) error
 	NetworkExists(name string) bool
 	NetworkConnect(network, container string) error
+	NetworkDisconnect(network, container string) error
 	NetworkInspect(name, format string) (string, error)
+	NetworkList() ([]string, error)
+	NetworkRemove(name string) error
 	IsRootless() bool
 }
 
@@ -146,32 +150,60 @@ func (r *shellRuntime) Remove(ctr string) error {
 	return exec.Command(r.cmd, "rm", "-f", ctr).Run()
 }
 
-func (r *shellRuntime) NetworkCreate(name string, internal bool) error {
+func (r *shellRuntime) NetworkCreate(name string, internal bool, subnet string) error {
 	args := []string{"network", "create"}
 	if internal {
 		args = append(args, "--internal")
 	}
+	if subnet != "" {
+		args = append(args, "--subnet", subnet)
+	}
 	args = append(args, name)
-	return exec.Command(r.cmd, args...).Run()
+	if out, err := exec.Command(r.cmd, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
 }
 
 func (r *shellRuntime) NetworkExists(name string) bool {
-	out, err := exec.Command(r.cmd, "network", "ls", "--format", "{{.Name}}").Output()
+	names, err := r.NetworkList()
 	if err != nil {
 		return false
 	}
-	for _, line := range strings.Split(string(out), "\n") {
-		if strings.TrimSpace(line) == name {
+	for _, n := range names {
+		if n == name {
 			return true
 		}
 	}
 	return false
 }
 
+func (r *shellRuntime) NetworkList() ([]string, error) {
+	out, err := exec.Command(r.cmd, "network", "ls", "--format", "{{.Name}}").Output()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+func (r *shellRuntime) NetworkRemove(name string) error {
+	return exec.Command(r.cmd, "network", "rm", name).Run()
+}
+
 func (r *shellRuntime) NetworkConnect(network, ctr string) error {
 	return exec.Command(r.cmd, "network", "connect", network, ctr).Run()
 }
 
+func (r *shellRuntime) NetworkDisconnect(network, ctr string) error {
+	return exec.Command(r.cmd, "network", "disconnect", network, ctr).Run()
+}
+
 func (r *shellRuntime) NetworkInspect(name, format string) (string, error) {
 	args := []string{"network", "inspect", name}
 	if format != "" {
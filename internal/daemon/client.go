@@ -0,0 +1,154 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/flock"
+)
+
+// ErrUnavailable is returned by Call when the daemon could not be reached
+// or started.
+var ErrUnavailable = errors.New("exitboxd unavailable")
+
+// ErrAlreadyRunning is returned by Listen when another daemon holds the lock.
+var ErrAlreadyRunning = errors.New("exitboxd is already running")
+
+// spawn starts a daemon in the background. Tests replace it.
+var spawn = spawnDaemon
+
+// Call sends req to the daemon, starting the daemon first if it is not
+// running. Operations can take as long as starting the proxy container.
+func Call(req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), time.Second)
+	if err != nil {
+		if err := spawn(); err != nil {
+			return Response{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		conn, err = dialWithRetry(5 * time.Second)
+		if err != nil {
+			return Response{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Minute))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return Response{}, fmt.Errorf("%w: no response", ErrUnavailable)
+	}
+	var resp Response
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		return Response{}, fmt.Errorf("invalid daemon response: %w", err)
+	}
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func dialWithRetry(timeout time.Duration) (net.Conn, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("unix", SocketPath(), time.Second)
+		if err == nil || time.Now().After(deadline) {
+			return conn, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// spawnDaemon runs "exitbox daemon" detached from the calling terminal,
+// logging to exitboxd.log in the cache directory.
+func spawnDaemon() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.Cache, 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(config.Cache, "exitboxd.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	c := exec.Command(exe, "daemon")
+	c.Stdout = logFile
+	c.Stderr = logFile
+	c.SysProcAttr = detached()
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Process.Release()
+}
+
+// Listen takes the daemon lock and opens the socket. The returned close
+// function removes the socket and releases the lock.
+func Listen() (net.Listener, func(), error) {
+	if err := os.MkdirAll(config.Cache, 0755); err != nil {
+		return nil, nil, err
+	}
+	lock, err := os.OpenFile(lockPath(), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := flock.TryLock(lock); err != nil {
+		lock.Close()
+		if errors.Is(err, flock.ErrLocked) {
+			return nil, nil, ErrAlreadyRunning
+		}
+		return nil, nil, err
+	}
+
+	// Holding the lock means any existing socket is stale.
+	_ = os.Remove(SocketPath())
+	l, err := net.Listen("unix", SocketPath())
+	if err != nil {
+		lock.Close()
+		return nil, nil, err
+	}
+	if err := os.Chmod(SocketPath(), 0600); err != nil {
+		l.Close()
+		lock.Close()
+		return nil, nil, err
+	}
+	return l, func() {
+		l.Close()
+		_ = os.Remove(SocketPath())
+		lock.Close()
+	}, nil
+}
@@ -0,0 +1,270 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package daemon implements exitboxd, a per-user host daemon that owns the
+// firewall proxy. Every exitbox run talks to it over a Unix socket, so
+// proxy start/stop and config writes happen in one process instead of
+// racing between concurrent sessions.
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// Operations understood by the daemon.
+const (
+	OpPing    = "ping"
+	OpAcquire = "acquire" // register a session and make sure the proxy runs
+	OpRelease = "release" // unregister a session
+	OpAllow   = "allow"   // add a domain to a session and reload
+	OpCleanup = "cleanup" // stop the proxy if no sessions remain
+)
+
+// Request is one JSON line sent to the daemon.
+type Request struct {
+	Op        string   `json:"op"`
+	Container string   `json:"container,omitempty"`
+	Network   string   `json:"network,omitempty"`
+	URLs      []string `json:"urls,omitempty"`
+	Domain    string   `json:"domain,omitempty"`
+	// UpstreamPassword is the upstream proxy password unlocked by the
+	// client. The daemon keeps it in memory for later reconfigures.
+	UpstreamPassword string `json:"upstream_password,omitempty"`
+}
+
+// Response is the daemon's reply to a Request.
+type Response struct {
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Sessions int    `json:"sessions"`
+}
+
+// Coordinator performs the firewall work for each operation. The daemon
+// calls it with one operation at a time.
+type Coordinator interface {
+	// Acquire gets upstreamPassword when the client unlocked one.
+	Acquire(container, network string, urls []string, upstreamPassword string) error
+	Release(container string) error
+	Allow(container, domain string) error
+	// Cleanup runs after sessions end. active holds the networks of the
+	// sessions still registered, which must be kept.
+	Cleanup(active []string) error
+	// Running reports whether a session's container still exists.
+	Running(container string) bool
+}
+
+// SocketPath is the daemon's Unix socket.
+func SocketPath() string {
+	return filepath.Join(config.Cache, "exitboxd.sock")
+}
+
+// lockPath is held by the running daemon for its whole lifetime.
+func lockPath() string {
+	return filepath.Join(config.Cache, "exitboxd.lock")
+}
+
+const maxRequestSize = 64 * 1024
+
+type session struct {
+	network string
+	since   time.Time
+}
+
+// Server dispatches requests to a Coordinator and reference-counts sessions.
+type Server struct {
+	coord Coordinator
+
+	mu       sync.Mutex // serialises all operations
+	sessions map[string]session
+	idleFrom time.Time
+
+	// StartupGrace is how long a session may exist before its container
+	// is expected to be running.
+	StartupGrace time.Duration
+	// IdleTimeout makes Serve return after this long without sessions.
+	IdleTimeout time.Duration
+	// SweepInterval is how often sessions of vanished containers are
+	// released.
+	SweepInterval time.Duration
+
+	now func() time.Time
+}
+
+// NewServer returns a server using coord.
+func NewServer(coord Coordinator) *Server {
+	return &Server{
+		coord:         coord,
+		sessions:      make(map[string]session),
+		StartupGrace:  2 * time.Minute,
+		IdleTimeout:   10 * time.Minute,
+		SweepInterval: 30 * time.Second,
+		now:           time.Now,
+		idleFrom:      time.Now(),
+	}
+}
+
+// Serve accepts connections on l until it has been idle for IdleTimeout or
+// l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(s.SweepInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				s.sweep()
+				if s.idleFor() >= s.IdleTimeout {
+					l.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConnection(conn)
+	}
+}
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, maxRequestSize), maxRequestSize)
+	if !scanner.Scan() {
+		return
+	}
+	var req Request
+	var resp Response
+	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+		resp = Response{Error: "invalid request"}
+	} else {
+		resp = s.Handle(req)
+	}
+	data, _ := json.Marshal(resp)
+	_, _ = conn.Write(append(data, '\n'))
+}
+
+// Handle runs a single request.
+func (s *Server) Handle(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	switch req.Op {
+	case OpPing:
+	case OpAcquire:
+		if req.Container == "" {
+			err = fmt.Errorf("missing container")
+			break
+		}
+		// Count the session first so a concurrent cleanup never sees zero
+		// sessions while the proxy is starting for it.
+		s.sessions[req.Container] = session{network: req.Network, since: s.now()}
+		if err = s.coord.Acquire(req.Container, req.Network, req.URLs, req.UpstreamPassword); err != nil {
+			s.remove(req.Container)
+		}
+	case OpRelease:
+		if _, ok := s.sessions[req.Container]; ok {
+			s.remove(req.Container)
+		}
+		err = s.coord.Release(req.Container)
+	case OpAllow:
+		err = s.coord.Allow(req.Container, req.Domain)
+	case OpCleanup:
+		err = s.coord.Cleanup(s.activeNetworks())
+	default:
+		err = fmt.Errorf("unknown operation: %s", req.Op)
+	}
+
+	resp := Response{OK: err == nil, Sessions: len(s.sessions)}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+// remove drops a session; the caller holds s.mu.
+func (s *Server) remove(container string) {
+	delete(s.sessions, container)
+	if len(s.sessions) == 0 {
+		s.idleFrom = s.now()
+	}
+}
+
+// sweep releases sessions whose exitbox run process died without releasing
+// them, i.e. whose container is gone after the startup grace period.
+func (s *Server) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	released := false
+	for name, sess := range s.sessions {
+		if s.now().Sub(sess.since) < s.StartupGrace || s.coord.Running(name) {
+			continue
+		}
+		s.remove(name)
+		_ = s.coord.Release(name)
+		released = true
+	}
+	if released {
+		_ = s.coord.Cleanup(s.activeNetworks())
+	}
+}
+
+// activeNetworks returns the networks of registered sessions; the caller
+// holds s.mu.
+func (s *Server) activeNetworks() []string {
+	var networks []string
+	for _, sess := range s.sessions {
+		if sess.network != "" {
+			networks = append(networks, sess.network)
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// idleFor returns how long the daemon has had no sessions.
+func (s *Server) idleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessions) > 0 {
+		return 0
+	}
+	return s.now().Sub(s.idleFrom)
+}
@@ -0,0 +1,189 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package daemon
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+type fakeCoordinator struct {
+	calls   []string
+	active  [][]string
+	running map[string]bool
+	failing bool
+}
+
+func (f *fakeCoordinator) Acquire(container, network string, urls []string, _ string) error {
+	f.calls = append(f.calls, "acquire "+container+" "+network+" "+strings.Join(urls, ","))
+	if f.failing {
+		return errors.New("proxy failed")
+	}
+	return nil
+}
+
+func (f *fakeCoordinator) Release(container string) error {
+	f.calls = append(f.calls, "release "+container)
+	return nil
+}
+
+func (f *fakeCoordinator) Allow(container, domain string) error {
+	f.calls = append(f.calls, "allow "+container+" "+domain)
+	return nil
+}
+
+func (f *fakeCoordinator) Cleanup(active []string) error {
+	f.calls = append(f.calls, "cleanup")
+	f.active = append(f.active, active)
+	return nil
+}
+
+func (f *fakeCoordinator) Running(container string) bool {
+	return f.running[container]
+}
+
+func TestHandle_ReferenceCountsSessions(t *testing.T) {
+	coord := &fakeCoordinator{}
+	s := NewServer(coord)
+
+	if resp := s.Handle(Request{Op: OpAcquire, Container: "exitbox-a", Network: "exitbox-int-a", URLs: []string{"example.com"}}); !resp.OK || resp.Sessions != 1 {
+		t.Fatalf("acquire a = %+v", resp)
+	}
+	if resp := s.Handle(Request{Op: OpAcquire, Container: "exitbox-b", Network: "exitbox-int-b"}); resp.Sessions != 2 {
+		t.Fatalf("acquire b = %+v", resp)
+	}
+
+	s.Handle(Request{Op: OpRelease, Container: "exitbox-a"})
+	resp := s.Handle(Request{Op: OpCleanup})
+	if resp.Sessions != 1 {
+		t.Errorf("sessions after release = %d, want 1", resp.Sessions)
+	}
+	if got := strings.Join(coord.active[0], ","); got != "exitbox-int-b" {
+		t.Errorf("cleanup active networks = %q, want exitbox-int-b", got)
+	}
+
+	s.Handle(Request{Op: OpRelease, Container: "exitbox-b"})
+	s.Handle(Request{Op: OpCleanup})
+	if len(coord.active[1]) != 0 {
+		t.Errorf("cleanup with no sessions got active networks %v", coord.active[1])
+	}
+	if coord.calls[0] != "acquire exitbox-a exitbox-int-a example.com" {
+		t.Errorf("first call = %q", coord.calls[0])
+	}
+}
+
+func TestHandle_FailedAcquireIsNotCounted(t *testing.T) {
+	s := NewServer(&fakeCoordinator{failing: true})
+
+	resp := s.Handle(Request{Op: OpAcquire, Container: "exitbox-a"})
+	if resp.OK || resp.Error != "proxy failed" {
+		t.Errorf("acquire = %+v, want error", resp)
+	}
+	if resp.Sessions != 0 {
+		t.Errorf("sessions = %d, want 0", resp.Sessions)
+	}
+}
+
+func TestHandle_Invalid(t *testing.T) {
+	s := NewServer(&fakeCoordinator{})
+	if resp := s.Handle(Request{Op: OpAcquire}); resp.OK {
+		t.Error("acquire without container should fail")
+	}
+	if resp := s.Handle(Request{Op: "bogus"}); resp.OK {
+		t.Error("unknown operation should fail")
+	}
+}
+
+func TestSweep_ReleasesVanishedSessions(t *testing.T) {
+	coord := &fakeCoordinator{running: map[string]bool{"exitbox-a": true}}
+	s := NewServer(coord)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Handle(Request{Op: OpAcquire, Container: "exitbox-a", Network: "exitbox-int-a"})
+	s.Handle(Request{Op: OpAcquire, Container: "exitbox-b", Network: "exitbox-int-b"})
+
+	// Within the startup grace period nothing is released.
+	s.sweep()
+	if resp := s.Handle(Request{Op: OpPing}); resp.Sessions != 2 {
+		t.Fatalf("sessions = %d, want 2", resp.Sessions)
+	}
+
+	now = now.Add(s.StartupGrace + time.Second)
+	s.sweep()
+	if resp := s.Handle(Request{Op: OpPing}); resp.Sessions != 1 {
+		t.Errorf("sessions = %d, want 1", resp.Sessions)
+	}
+	last := coord.calls[len(coord.calls)-2:]
+	if last[0] != "release exitbox-b" || last[1] != "cleanup" {
+		t.Errorf("calls = %v, want release of exitbox-b then cleanup", last)
+	}
+}
+
+func TestIdleFor(t *testing.T) {
+	s := NewServer(&fakeCoordinator{})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Handle(Request{Op: OpAcquire, Container: "exitbox-a"})
+	now = now.Add(time.Hour)
+	if d := s.idleFor(); d != 0 {
+		t.Errorf("idleFor with a session = %v, want 0", d)
+	}
+	s.Handle(Request{Op: OpRelease, Container: "exitbox-a"})
+	now = now.Add(time.Minute)
+	if d := s.idleFor(); d != time.Minute {
+		t.Errorf("idleFor = %v, want 1m", d)
+	}
+}
+
+func TestCall_OverSocket(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+
+	l, closeListener, err := Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListener()
+
+	if _, _, err := Listen(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("second Listen error = %v, want ErrAlreadyRunning", err)
+	}
+
+	coord := &fakeCoordinator{}
+	go func() { _ = NewServer(coord).Serve(l) }()
+
+	resp, err := Call(Request{Op: OpAcquire, Container: "exitbox-a"})
+	if err != nil || resp.Sessions != 1 {
+		t.Fatalf("Call = %+v, %v", resp, err)
+	}
+	if _, err := Call(Request{Op: "bogus"}); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("daemon error = %v, want the daemon's error", err)
+	}
+}
+
+func TestCall_Unavailable(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+
+	origSpawn := spawn
+	spawn = func() error { return errors.New("no daemon") }
+	defer func() { spawn = origSpawn }()
+
+	if _, err := Call(Request{Op: OpPing}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("error = %v, want ErrUnavailable", err)
+	}
+}
@@ -102,25 +102,9 @@ func buildLocalIntermediary(ctx context.Context, rt container.Runtime, cmd, base
 		return fmt.Errorf("failed to write Dockerfile.local: %w", err)
 	}
 
-	// Write pre-built exitbox-allow binary for the container's architecture.
-	if extra, err := writeExitboxAllow(buildCtx); err == nil && extra != "" {
-		if err := appendToFile(dockerfilePath, extra); err != nil {
-			ui.Warnf("Failed to append exitbox-allow to Dockerfile: %v", err)
-		}
-	}
-
-	// Write pre-built exitbox-vault binary for the container's architecture.
-	if extra, err := writeExitboxVault(buildCtx); err == nil && extra != "" {
-		if err := appendToFile(dockerfilePath, extra); err != nil {
-			ui.Warnf("Failed to append exitbox-vault to Dockerfile: %v", err)
-		}
-	}
-
-	// Write pre-built exitbox-kv binary for the container's architecture.
-	if extra, err := writeExitboxKV(buildCtx); err == nil && extra != "" {
-		if err := appendToFile(dockerfilePath, extra); err != nil {
-			ui.Warnf("Failed to append exitbox-kv to Dockerfile: %v", err)
-		}
+	// Write the pre-built helper binaries for the container's architecture.
+	if err := appendToFile(dockerfilePath, writeHelpers(buildCtx)); err != nil {
+		ui.Warnf("Failed to append helpers to Dockerfile: %v", err)
 	}
 
 	args := buildArgs(cmd)
@@ -185,58 +169,35 @@ func buildBaseFull(ctx context.Context, rt container.Runtime, cmd, imageName str
 	return nil
 }
 
-// writeExitboxAllow writes the exitbox-allow binary into the build context
-// and returns the Dockerfile snippet to COPY it. Returns empty string if
-// the binary could not be written.
-func writeExitboxAllow(buildCtx string) (string, error) {
-	var allowBin []byte
-	switch runtime.GOARCH {
-	case "arm64":
-		allowBin = static.ExitboxAllowArm64
-	default:
-		allowBin = static.ExitboxAllowAmd64
-	}
-	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-allow"), allowBin, 0755); err != nil {
-		ui.Warnf("Failed to write exitbox-allow: %v", err)
-		return "", err
-	}
-	return "\n# IPC client\nCOPY exitbox-allow /usr/local/bin/exitbox-allow\n", nil
-}
-
-// writeExitboxVault writes the exitbox-vault binary into the build context
-// and returns the Dockerfile snippet to COPY it. Returns empty string if
-// the binary could not be written.
-func writeExitboxVault(buildCtx string) (string, error) {
-	var vaultBin []byte
-	switch runtime.GOARCH {
-	case "arm64":
-		vaultBin = static.ExitboxVaultArm64
-	default:
-		vaultBin = static.ExitboxVaultAmd64
-	}
-	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-vault"), vaultBin, 0755); err != nil {
-		ui.Warnf("Failed to write exitbox-vault: %v", err)
-		return "", err
+// writeHelpers writes every static.Helpers binary for the container's
+// architecture into the build context and returns the Dockerfile snippet
+// that installs them. A helper that cannot be written is left out.
+func writeHelpers(buildCtx string) string {
+	var b strings.Builder
+	for _, h := range static.Helpers {
+		bin, err := static.Binary(h.Name, binaryArch())
+		if err == nil {
+			err = os.WriteFile(filepath.Join(buildCtx, h.Name), bin, 0755)
+		}
+		if err != nil {
+			ui.Warnf("Failed to write %s: %v", h.Name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n# %s\n", h.Comment)
+		for _, name := range append([]string{h.Name}, h.Aliases...) {
+			fmt.Fprintf(&b, "COPY %s /usr/local/bin/%s\n", h.Name, name)
+		}
 	}
-	return "\n# Vault IPC client\nCOPY exitbox-vault /usr/local/bin/exitbox-vault\n", nil
+	return b.String()
 }
 
-// writeExitboxKV writes the exitbox-kv binary into the build context
-// and returns the Dockerfile snippet to COPY it. Returns empty string if
-// the binary could not be written.
-func writeExitboxKV(buildCtx string) (string, error) {
-	var kvBin []byte
-	switch runtime.GOARCH {
-	case "arm64":
-		kvBin = static.ExitboxKVArm64
-	default:
-		kvBin = static.ExitboxKVAmd64
-	}
-	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-kv"), kvBin, 0755); err != nil {
-		ui.Warnf("Failed to write exitbox-kv: %v", err)
-		return "", err
+// binaryArch is the architecture of the embedded binaries matching the
+// host; containers run on the host's architecture.
+func binaryArch() string {
+	if runtime.GOARCH == "arm64" {
+		return "arm64"
 	}
-	return "\n# KV IPC client\nCOPY exitbox-kv /usr/local/bin/exitbox-kv\n", nil
+	return "amd64"
 }
 
 // pullImage pulls a container image, using a spinner in quiet mode or
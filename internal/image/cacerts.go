@@ -0,0 +1,123 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package image
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// caCertDir is the build context subdirectory holding extra CA certificates.
+	caCertDir = "ca-certificates"
+	// caBundle is the system bundle regenerated by update-ca-certificates.
+	caBundle = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// caFingerprint returns a short hash over the contents of the given PEM
+// files, so that replacing a certificate changes image hashes. Unreadable
+// files contribute their path instead; the build reports them.
+func caFingerprint(paths []string) string {
+	h := sha256.New()
+	for _, p := range paths {
+		data, err := os.ReadFile(expandHome(p))
+		if err != nil {
+			fmt.Fprintf(h, "missing:%s\n", p)
+			continue
+		}
+		h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:8])
+}
+
+// writeCACertificates validates the PEM files, copies them into the build
+// context and returns the Dockerfile snippet that installs them. prefix
+// keeps files from different layers apart in /usr/local/share/ca-certificates.
+// It returns an empty snippet when paths is empty.
+func writeCACertificates(buildCtx, prefix string, paths []string) (string, error) {
+	dir := filepath.Join(buildCtx, caCertDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("failed to clean CA certificate dir: %w", err)
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create CA certificate dir: %w", err)
+	}
+
+	for i, p := range paths {
+		data, err := os.ReadFile(expandHome(p))
+		if err != nil {
+			return "", fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if err := validatePEMCertificates(data); err != nil {
+			return "", fmt.Errorf("invalid CA certificate %s: %w", p, err)
+		}
+		// update-ca-certificates only picks up *.crt files.
+		name := fmt.Sprintf("exitbox-%s-%d.crt", prefix, i)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return "", fmt.Errorf("failed to write CA certificate: %w", err)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Extra CA certificates (settings.ca_certificates)\n")
+	fmt.Fprintf(&b, "COPY %s/ /usr/local/share/ca-certificates/\n", caCertDir)
+	b.WriteString("RUN update-ca-certificates 2>/dev/null\n")
+	fmt.Fprintf(&b, "ENV SSL_CERT_FILE=%[1]s \\\n    REQUESTS_CA_BUNDLE=%[1]s \\\n    NODE_EXTRA_CA_CERTS=%[1]s\n\n", caBundle)
+	return b.String(), nil
+}
+
+// validatePEMCertificates checks that data holds at least one PEM
+// certificate and nothing but certificates.
+func validatePEMCertificates(data []byte) error {
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no PEM certificate found")
+	}
+	return nil
+}
+
+// expandHome expands a leading ~/ to the user's home directory.
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
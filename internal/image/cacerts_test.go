@@ -0,0 +1,115 @@
+package image
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// writeTestCA writes a freshly generated self-signed CA to dir/name.
+func writeTestCA(t *testing.T, dir, name string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "Test Corp CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWriteCACertificates(t *testing.T) {
+	src := t.TempDir()
+	buildCtx := t.TempDir()
+	ca := writeTestCA(t, src, "corp.pem")
+
+	// A stale file from an earlier build must be removed.
+	stale := filepath.Join(buildCtx, caCertDir, "old.crt")
+	_ = os.MkdirAll(filepath.Dir(stale), 0755)
+	_ = os.WriteFile(stale, []byte("old"), 0644)
+
+	snippet, err := writeCACertificates(buildCtx, "global", []string{ca})
+	if err != nil {
+		t.Fatalf("writeCACertificates: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(buildCtx, caCertDir, "exitbox-global-0.crt")); err != nil {
+		t.Errorf("certificate not copied into build context: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale certificate should have been removed")
+	}
+	for _, want := range []string{
+		"COPY ca-certificates/ /usr/local/share/ca-certificates/",
+		"RUN update-ca-certificates",
+		"SSL_CERT_FILE=" + caBundle,
+		"REQUESTS_CA_BUNDLE=" + caBundle,
+		"NODE_EXTRA_CA_CERTS=" + caBundle,
+	} {
+		if !strings.Contains(snippet, want) {
+			t.Errorf("snippet missing %q:\n%s", want, snippet)
+		}
+	}
+}
+
+func TestWriteCACertificates_Empty(t *testing.T) {
+	snippet, err := writeCACertificates(t.TempDir(), "global", nil)
+	if err != nil || snippet != "" {
+		t.Errorf("writeCACertificates(nil) = %q, %v; want empty snippet", snippet, err)
+	}
+}
+
+func TestWriteCACertificates_Invalid(t *testing.T) {
+	src := t.TempDir()
+	bad := filepath.Join(src, "key.pem")
+	_ = os.WriteFile(bad, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")}), 0644)
+
+	if _, err := writeCACertificates(t.TempDir(), "global", []string{bad}); err == nil {
+		t.Error("expected error for a PEM file without certificates")
+	}
+	if _, err := writeCACertificates(t.TempDir(), "global", []string{filepath.Join(src, "missing.pem")}); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestToolsHash_IncludesCACertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := writeTestCA(t, dir, "corp.pem")
+	cfg := &config.Config{Settings: config.SettingsConfig{CACertificates: []string{ca}}}
+
+	h1 := ToolsHash(cfg)
+	if h1 == ToolsHash(&config.Config{}) {
+		t.Error("ToolsHash should differ when CA certificates are configured")
+	}
+
+	// Replacing the certificate at the same path must change the hash.
+	writeTestCA(t, dir, "corp.pem")
+	if ToolsHash(cfg) == h1 {
+		t.Error("ToolsHash should change when the certificate content changes")
+	}
+}
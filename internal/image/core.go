@@ -29,12 +29,13 @@ import (
 	"github.com/cloud-exit/exitbox/internal/agent"
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	proj "github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
-// BuildCore builds the agent core image (exitbox-<agent>-core).
+// BuildCore builds the agent core image (<namespace>-<agent>-core).
 func BuildCore(ctx context.Context, rt container.Runtime, agentName string, force bool) error {
-	imageName := fmt.Sprintf("exitbox-%s-core", agentName)
+	imageName := proj.CoreImageName(agentName)
 	cmd := container.Cmd(rt)
 
 	a := agent.Get(agentName)
@@ -63,6 +64,7 @@ func BuildCore(ctx context.Context, rt container.Runtime, agentName string, forc
 				if err := BuildBase(ctx, rt, false); err != nil {
 					return err
 				}
+				ensureFirewallImage(ctx, rt)
 				return nil
 			}
 		} else {
@@ -88,6 +90,7 @@ func BuildCore(ctx context.Context, rt container.Runtime, agentName string, forc
 	if squidErr := BuildSquid(ctx, rt, false); squidErr != nil {
 		ui.Warnf("Failed to build squid image: %v", squidErr)
 	}
+	ensureFirewallImage(ctx, rt)
 
 	if !ui.Verbose {
 		ui.Info("Building containers (use -v for build output)")
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/static"
 )
 
 func TestFormatDuration(t *testing.T) {
@@ -278,3 +279,37 @@ func TestRegistryConstants(t *testing.T) {
 		t.Errorf("SquidImageRegistry = %q, should not end with /", SquidImageRegistry)
 	}
 }
+
+func TestWriteHelpers(t *testing.T) {
+	dir := t.TempDir()
+	snippet := writeHelpers(dir)
+	for _, h := range static.Helpers {
+		if _, err := os.Stat(filepath.Join(dir, h.Name)); err != nil {
+			t.Errorf("%s not written: %v", h.Name, err)
+		}
+		if !strings.Contains(snippet, "COPY "+h.Name+" /usr/local/bin/"+h.Name+"\n") {
+			t.Errorf("no COPY line for %s", h.Name)
+		}
+	}
+	if !strings.Contains(snippet, "COPY exitbox-open /usr/local/bin/xdg-open\n") {
+		t.Error("no COPY line for the xdg-open alias")
+	}
+}
+
+// Every cmd/exitbox-* helper must be in static.Helpers, or it is built
+// but never installed.
+func TestHelpersCoverCmd(t *testing.T) {
+	listed := map[string]bool{"exitbox-proxy": true} // runs in the proxy image
+	for _, h := range static.Helpers {
+		listed[h.Name] = true
+	}
+	dirs, err := filepath.Glob(filepath.Join("..", "..", "cmd", "exitbox-*"))
+	if err != nil || len(dirs) == 0 {
+		t.Fatalf("no helpers found in cmd/: %v", err)
+	}
+	for _, dir := range dirs {
+		if name := filepath.Base(dir); !listed[name] {
+			t.Errorf("%s is missing from static.Helpers", name)
+		}
+	}
+}
@@ -43,6 +43,9 @@ func WorkspaceHash(cfg *config.Config, projectDir string, overrideName string) s
 		parts = append(parts, active.Scope, active.Workspace.Name)
 		parts = append(parts, active.Workspace.Development...)
 		parts = append(parts, active.Workspace.Packages...)
+		if len(active.Workspace.CACertificates) > 0 {
+			parts = append(parts, "ca="+caFingerprint(active.Workspace.CACertificates))
+		}
 	}
 	parts = append(parts, SessionTools...)
 	h := sha256.Sum256([]byte(strings.Join(parts, ",")))
@@ -56,7 +59,7 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 	cfg := config.LoadOrDefault()
 	wh := WorkspaceHash(cfg, projectDir, workspaceOverride)
 	imageName := proj.ImageName(agentName, projectDir, wh)
-	toolsImage := fmt.Sprintf("exitbox-%s-tools", agentName)
+	toolsImage := proj.ToolsImageName(agentName)
 	cmd := container.Cmd(rt)
 
 	// Ensure tools image exists (tools → core → base cascade)
@@ -102,6 +105,16 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 	// but be explicit in case that changes)
 	df.WriteString("USER root\n\n")
 
+	var workspaceCAs []string
+	if active != nil {
+		workspaceCAs = active.Workspace.CACertificates
+	}
+	caSnippet, err := writeCACertificates(buildCtx, "workspace", workspaceCAs)
+	if err != nil {
+		return err
+	}
+	df.WriteString(caSnippet)
+
 	// Validate all development profiles up front.
 	for _, p := range developmentProfiles {
 		if !profile.Exists(p) {
@@ -0,0 +1,86 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package image
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/cloud-exit/exitbox/static"
+)
+
+// BuildProxy builds the exitbox-proxy image used by the builtin firewall
+// backend and the registry mirror. The image only holds the embedded static binary, so it is always
+// built locally.
+func BuildProxy(ctx context.Context, rt container.Runtime, force bool) error {
+	imageName := "exitbox-proxy"
+
+	if !force && rt.ImageExists(imageName) {
+		v, _ := rt.ImageInspect(imageName, `{{index .Config.Labels "exitbox.version"}}`)
+		if v == Version {
+			return nil
+		}
+		ui.Infof("Proxy image version mismatch (%s != %s). Rebuilding...", v, Version)
+	}
+
+	ui.Info("Building built-in proxy image...")
+
+	buildCtx := filepath.Join(config.Cache, "build-proxy")
+	if err := os.MkdirAll(buildCtx, 0755); err != nil {
+		return fmt.Errorf("failed to create build context dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(buildCtx, "Dockerfile"), static.DockerfileProxy, 0644); err != nil {
+		return fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+
+	proxyBin, err := static.Binary("exitbox-proxy", binaryArch())
+	if err != nil {
+		return fmt.Errorf("failed to read exitbox-proxy: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-proxy"), proxyBin, 0755); err != nil {
+		return fmt.Errorf("failed to write exitbox-proxy: %w", err)
+	}
+
+	args := buildArgs(container.Cmd(rt))
+	args = append(args,
+		"--build-arg", fmt.Sprintf("EXITBOX_VERSION=%s", Version),
+		"-t", imageName,
+		buildCtx,
+	)
+	if err := buildImage(rt, args, "Building built-in proxy image..."); err != nil {
+		return fmt.Errorf("failed to build proxy image: %w", err)
+	}
+	return nil
+}
+
+// ensureFirewallImage builds the proxy image for the builtin firewall
+// backend and the registry mirror (settings.proxy_cache), which runs the
+// same binary. The Squid image is handled by BuildCore as before.
+func ensureFirewallImage(ctx context.Context, rt container.Runtime) {
+	s := config.LoadOrDefault().Settings
+	if s.FirewallBackend != config.FirewallBuiltin && !s.ProxyCache.Enabled {
+		return
+	}
+	if err := BuildProxy(ctx, rt, false); err != nil {
+		ui.Warnf("Failed to build proxy image: %v", err)
+	}
+}
@@ -26,18 +26,23 @@ import (
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	proj "github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
 // ToolsHash computes a short hash of the global tool configuration
-// (user packages and binary downloads). This hash is stored as a label
-// on the tools image and used to detect when it needs rebuilding.
+// (user packages, binary downloads and extra CA certificates). This hash
+// is stored as a label on the tools image and used to detect when it
+// needs rebuilding.
 func ToolsHash(cfg *config.Config) string {
 	var parts []string
 	parts = append(parts, cfg.Tools.User...)
 	for _, b := range cfg.Tools.Binaries {
 		parts = append(parts, b.Name+"="+b.URLPattern)
 	}
+	if len(cfg.Settings.CACertificates) > 0 {
+		parts = append(parts, "ca="+caFingerprint(cfg.Settings.CACertificates))
+	}
 	h := sha256.Sum256([]byte(strings.Join(parts, ",")))
 	return fmt.Sprintf("%x", h[:8])
 }
@@ -50,8 +55,8 @@ func ToolsHash(cfg *config.Config) string {
 func BuildTools(ctx context.Context, rt container.Runtime, agentName string, force bool) error {
 	cfg := config.LoadOrDefault()
 	toolsHash := ToolsHash(cfg)
-	imageName := fmt.Sprintf("exitbox-%s-tools", agentName)
-	coreImage := fmt.Sprintf("exitbox-%s-core", agentName)
+	imageName := proj.ToolsImageName(agentName)
+	coreImage := proj.CoreImageName(agentName)
 	cmd := container.Cmd(rt)
 
 	// Ensure core image exists
@@ -89,6 +94,14 @@ func BuildTools(ctx context.Context, rt container.Runtime, agentName string, for
 	// Switch to root for package installation
 	df.WriteString("USER root\n\n")
 
+	// Trust extra CAs before anything is downloaded, so installs work
+	// behind TLS-intercepting proxies.
+	caSnippet, err := writeCACertificates(buildCtx, "global", cfg.Settings.CACertificates)
+	if err != nil {
+		return err
+	}
+	df.WriteString(caSnippet)
+
 	// Install global tools (from tool categories)
 	if len(cfg.Tools.User) > 0 {
 		fmt.Fprintf(&df, "RUN --mount=type=cache,target=/var/cache/apk apk add --no-cache %s\n\n", strings.Join(cfg.Tools.User, " "))
@@ -0,0 +1,155 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	cryptoRand "crypto/rand"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// Approval backends, selected per workspace with the "approver" key.
+const (
+	ApproverTmux     = "tmux"     // popup in the agent's tmux session (default)
+	ApproverTerminal = "terminal" // prompt on the terminal exitbox runs in
+	ApproverDesktop  = "desktop"  // desktop notification with action buttons
+	ApproverQueue    = "queue"    // answered with `exitbox approve` / `exitbox deny`
+)
+
+// ApproverKinds lists the valid approver values.
+var ApproverKinds = []string{ApproverTmux, ApproverTerminal, ApproverDesktop, ApproverQueue}
+
+// Field is one labelled line of a Prompt, e.g. "Domain: example.com".
+type Field struct {
+	Label string
+	Value string
+}
+
+// Prompt describes what the user is asked to approve.
+type Prompt struct {
+	Header string // e.g. "ExitBox Vault"
+	Title  string // the question, e.g. "Allow secret read?"
+	Fields []Field
+}
+
+// Approver asks the host user to approve requests from the container.
+type Approver interface {
+	// Confirm reports whether the user approved p. A dismissed prompt is
+	// a denial, not an error.
+	Confirm(ctx context.Context, p Prompt) (bool, error)
+	// Password asks the user to type a secret.
+	Password(ctx context.Context, p Prompt) (string, error)
+}
+
+// NewApprover returns the approval backend named kind for a session
+// running in containerName. An empty kind selects the tmux popup.
+func NewApprover(kind string, rt container.Runtime, containerName string) (Approver, error) {
+	tmux := &TmuxApprover{Runtime: rt, ContainerName: containerName}
+	switch kind {
+	case "", ApproverTmux:
+		return tmux, nil
+	case ApproverTerminal:
+		return &TerminalApprover{}, nil
+	case ApproverDesktop:
+		return &DesktopApprover{Fallback: tmux}, nil
+	case ApproverQueue:
+		return &QueueApprover{Dir: ApprovalDir(), Session: containerName}, nil
+	}
+	return nil, fmt.Errorf("unknown approver %q (valid: %s)", kind, strings.Join(ApproverKinds, ", "))
+}
+
+// approverOrTmux returns a, or a tmux popup approver if a is nil.
+func approverOrTmux(a Approver, rt container.Runtime, containerName string) Approver {
+	if a != nil {
+		return a
+	}
+	return &TmuxApprover{Runtime: rt, ContainerName: containerName}
+}
+
+// TmuxApprover shows prompts as tmux popups inside the agent container.
+type TmuxApprover struct {
+	Runtime       container.Runtime
+	ContainerName string
+}
+
+// popupText returns printf-ready text for p: the header, title and fields.
+// Values are sanitized because they come from the container.
+func popupText(p Prompt) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `\n  \033[1;33m[%s]\033[0m %s\n\n`, p.Header, p.Title)
+	for _, f := range p.Fields {
+		fmt.Fprintf(&b, `  %s: \033[1m%s\033[0m\n`, f.Label, sanitizeForShell(f.Value))
+	}
+	return b.String()
+}
+
+// Confirm shows a y/N popup. The user types y + Enter (not `read -n1`),
+// which works in all terminals.
+func (a *TmuxApprover) Confirm(ctx context.Context, p Prompt) (bool, error) {
+	script := `printf '` + popupText(p) + `\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
+	return confirmPopup(ctx, a.Runtime, a.ContainerName, 55, 7+len(p.Fields), script)
+}
+
+// Password shows a popup that captures a secret. Since tmux display-popup
+// runs the script inside the popup's terminal (stdout goes to the popup,
+// not back through docker exec), a temp file inside the container carries
+// the answer back to the host.
+func (a *TmuxApprover) Password(ctx context.Context, p Prompt) (string, error) {
+	cmd := container.Cmd(a.Runtime)
+
+	// Generate a random temp file path inside the container.
+	randomBytes := make([]byte, 8)
+	if _, err := cryptoRand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("generating random path: %w", err)
+	}
+	tmpFile := fmt.Sprintf("/tmp/.exitbox-vault-pw-%x", randomBytes)
+
+	// The script exits 0 only if a non-empty password was entered.
+	script := `printf '` + popupText(p) + `  Password: '; ` +
+		`stty -echo 2>/dev/null; read pw; stty echo 2>/dev/null; printf '\n'; ` +
+		`echo "$pw" > ` + tmpFile + `; [ -n "$pw" ]`
+
+	// Run the popup (blocks until user submits or dismisses).
+	popupErr := runPopup(ctx, a.Runtime, a.ContainerName, 55, 7+len(p.Fields), script)
+
+	// Read the password from the temp file.
+	out, readErr := exec.Command(cmd, "exec", a.ContainerName, "cat", tmpFile).Output()
+
+	// Clean up the temp file regardless of outcome.
+	_ = exec.Command(cmd, "exec", a.ContainerName, "rm", "-f", tmpFile).Run()
+
+	if errors.Is(popupErr, errPopupDismissed) {
+		return "", fmt.Errorf("password entry cancelled")
+	}
+	if popupErr != nil {
+		return "", popupErr
+	}
+	if readErr != nil {
+		return "", fmt.Errorf("failed to read password: %w", readErr)
+	}
+
+	password := strings.TrimRight(string(out), "\n\r")
+	if password == "" {
+		return "", fmt.Errorf("empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,104 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DesktopApprover asks through a desktop notification with Allow/Deny
+// buttons: freedesktop notifications over D-Bus (notify-send) on Linux,
+// a dialog via osascript on macOS. Passwords, and every prompt on hosts
+// without a notification service, go to Fallback.
+type DesktopApprover struct {
+	Fallback Approver
+}
+
+// lookPath is exec.LookPath, swappable for testing.
+var lookPath = exec.LookPath
+
+// desktopCommand returns the command that shows p with Allow/Deny
+// buttons, or nil if no notification service is available.
+func desktopCommand(ctx context.Context, p Prompt) *exec.Cmd {
+	var body []string
+	for _, f := range p.Fields {
+		body = append(body, f.Label+": "+f.Value)
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := lookPath("osascript"); err != nil {
+			return nil
+		}
+		script := fmt.Sprintf(`display dialog %s with title %s buttons {"Deny", "Allow"} default button "Deny" cancel button "Deny"`,
+			appleScriptString(p.Title+"\n\n"+strings.Join(body, "\n")), appleScriptString(p.Header))
+		return exec.CommandContext(ctx, "osascript", "-e", script)
+	default:
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return nil
+		}
+		if _, err := lookPath("notify-send"); err != nil {
+			return nil
+		}
+		return exec.CommandContext(ctx, "notify-send",
+			"--app-name=ExitBox", "--urgency=critical", "--wait",
+			"--action=allow=Allow", "--action=deny=Deny",
+			p.Header+": "+p.Title, notifyMarkupEscape(strings.Join(body, "\n")))
+	}
+}
+
+// Confirm shows the notification and waits for a button. Closing the
+// notification denies.
+func (a *DesktopApprover) Confirm(ctx context.Context, p Prompt) (bool, error) {
+	c := desktopCommand(ctx, p)
+	if c == nil {
+		return a.Fallback.Confirm(ctx, p)
+	}
+	out, err := c.Output()
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok && runtime.GOOS == "darwin" {
+			return false, nil // "Deny" is the cancel button
+		}
+		return false, fmt.Errorf("desktop notification failed: %w", err)
+	}
+	ans := strings.TrimSpace(string(out))
+	return ans == "allow" || ans == "button returned:Allow", nil
+}
+
+// Password is not asked through notifications; it goes to Fallback.
+func (a *DesktopApprover) Password(ctx context.Context, p Prompt) (string, error) {
+	return a.Fallback.Password(ctx, p)
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// notifyMarkupEscape escapes s for notification bodies, which servers
+// may render as markup.
+func notifyMarkupEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
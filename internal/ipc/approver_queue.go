@@ -0,0 +1,262 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"bufio"
+	"context"
+	cryptoRand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// ApprovalDir is where the queue approver leaves requests for
+// `exitbox approve` and `exitbox deny`.
+func ApprovalDir() string {
+	return filepath.Join(config.Cache, "approvals")
+}
+
+// PendingApproval is a request waiting in the approval queue.
+type PendingApproval struct {
+	ID      string    `json:"id"`
+	Session string    `json:"session"`
+	Header  string    `json:"header"`
+	Title   string    `json:"title"`
+	Fields  []Field   `json:"fields,omitempty"`
+	Secret  bool      `json:"secret,omitempty"` // answered with a password
+	Created time.Time `json:"created"`
+}
+
+type approvalAnswer struct {
+	Approved bool   `json:"approved"`
+	Password string `json:"password,omitempty"`
+}
+
+var approvalIDPattern = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// answerTimeout bounds reading or writing one answer on a request's socket.
+const answerTimeout = 10 * time.Second
+
+// QueueApprover leaves each request in Dir until it is answered from
+// another terminal. Answers arrive on a Unix socket next to the request
+// rather than in a file, so a vault password is never written to disk.
+type QueueApprover struct {
+	Dir     string
+	Session string
+}
+
+// Confirm queues p and waits for `exitbox approve` or `exitbox deny`.
+func (a *QueueApprover) Confirm(ctx context.Context, p Prompt) (bool, error) {
+	ans, err := a.wait(ctx, p, false)
+	return ans.Approved, err
+}
+
+// Password queues p; `exitbox approve` asks for the password.
+func (a *QueueApprover) Password(ctx context.Context, p Prompt) (string, error) {
+	ans, err := a.wait(ctx, p, true)
+	if err != nil {
+		return "", err
+	}
+	if !ans.Approved || ans.Password == "" {
+		return "", fmt.Errorf("password entry cancelled")
+	}
+	return ans.Password, nil
+}
+
+func (a *QueueApprover) wait(ctx context.Context, p Prompt, secret bool) (approvalAnswer, error) {
+	if err := os.MkdirAll(a.Dir, 0700); err != nil {
+		return approvalAnswer{}, fmt.Errorf("create approval queue: %w", err)
+	}
+
+	b := make([]byte, 4)
+	if _, err := cryptoRand.Read(b); err != nil {
+		return approvalAnswer{}, err
+	}
+	id := hex.EncodeToString(b)
+	// Values come from the container; strip anything a terminal could
+	// interpret before `exitbox approve` prints them.
+	fields := make([]Field, len(p.Fields))
+	for i, f := range p.Fields {
+		fields[i] = Field{Label: f.Label, Value: sanitizeForShell(f.Value)}
+	}
+	data, err := json.Marshal(PendingApproval{
+		ID:      id,
+		Session: a.Session,
+		Header:  p.Header,
+		Title:   p.Title,
+		Fields:  fields,
+		Secret:  secret,
+		Created: time.Now().UTC(),
+	})
+	if err != nil {
+		return approvalAnswer{}, err
+	}
+	socketFile := filepath.Join(a.Dir, id+".sock")
+	l, err := net.Listen("unix", socketFile)
+	if err != nil {
+		return approvalAnswer{}, fmt.Errorf("queue approval: %w", err)
+	}
+	defer os.Remove(socketFile)
+	defer l.Close()
+	if err := os.Chmod(socketFile, 0600); err != nil {
+		return approvalAnswer{}, fmt.Errorf("queue approval: %w", err)
+	}
+	requestFile := filepath.Join(a.Dir, id+".json")
+	if err := writeFileAtomic(requestFile, data); err != nil {
+		return approvalAnswer{}, fmt.Errorf("queue approval: %w", err)
+	}
+	defer os.Remove(requestFile)
+
+	answers := make(chan approvalAnswer, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if ans, ok := readAnswer(conn); ok {
+				answers <- ans
+				return
+			}
+		}
+	}()
+	select {
+	case <-ctx.Done():
+		return approvalAnswer{}, ctx.Err()
+	case ans := <-answers:
+		return ans, nil
+	}
+}
+
+// readAnswer reads one answer from conn and acknowledges it. Malformed
+// answers are dropped so the request stays pending.
+func readAnswer(conn net.Conn) (approvalAnswer, bool) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(answerTimeout))
+	line, err := bufio.NewReader(io.LimitReader(conn, 64*1024)).ReadBytes('\n')
+	if err != nil {
+		return approvalAnswer{}, false
+	}
+	var ans approvalAnswer
+	if json.Unmarshal(line, &ans) != nil {
+		return approvalAnswer{}, false
+	}
+	_, _ = conn.Write([]byte("ok\n"))
+	return ans, true
+}
+
+// ListApprovals returns the requests queued in dir, oldest first.
+func ListApprovals(dir string) ([]PendingApproval, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending []PendingApproval
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue // answered and removed meanwhile
+		}
+		var p PendingApproval
+		if json.Unmarshal(data, &p) == nil {
+			pending = append(pending, p)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Created.Before(pending[j].Created) })
+	return pending, nil
+}
+
+// FindApproval returns the request queued in dir under id.
+func FindApproval(dir, id string) (PendingApproval, error) {
+	if !approvalIDPattern.MatchString(id) {
+		return PendingApproval{}, fmt.Errorf("invalid approval id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return PendingApproval{}, fmt.Errorf("no pending approval %q", id)
+	}
+	if err != nil {
+		return PendingApproval{}, err
+	}
+	var p PendingApproval
+	if err := json.Unmarshal(data, &p); err != nil {
+		return PendingApproval{}, err
+	}
+	return p, nil
+}
+
+// AnswerApproval answers the request queued in dir under id by handing
+// the answer to the waiting session over the request's socket. password
+// is only used for requests with Secret set.
+func AnswerApproval(dir, id string, approved bool, password string) error {
+	if _, err := FindApproval(dir, id); err != nil {
+		return err
+	}
+	data, err := json.Marshal(approvalAnswer{Approved: approved, Password: password})
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("unix", filepath.Join(dir, id+".sock"), answerTimeout)
+	if err != nil {
+		return fmt.Errorf("approval %q is no longer pending", id)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(answerTimeout))
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("answer approval: %w", err)
+	}
+	if ack, err := bufio.NewReader(conn).ReadString('\n'); err != nil || ack != "ok\n" {
+		return fmt.Errorf("approval %q is no longer pending", id)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data readable only by the owner, so a reader
+// never sees a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,176 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// TerminalApprover prompts on the terminal exitbox was started from. It
+// suits headless sessions, where the agent's output is not on a terminal
+// and there is no tmux session anyone is watching.
+type TerminalApprover struct {
+	// TTY overrides /dev/tty for testing.
+	TTY string
+
+	mu sync.Mutex // one prompt on the terminal at a time
+}
+
+// Confirm asks a y/N question on the terminal.
+func (a *TerminalApprover) Confirm(ctx context.Context, p Prompt) (bool, error) {
+	ans, err := a.ask(ctx, p, "[y/N]: ", false)
+	if err != nil {
+		return false, err
+	}
+	ans = strings.ToLower(strings.TrimSpace(ans))
+	return ans == "y" || ans == "yes", nil
+}
+
+// Password reads a secret from the terminal without echoing it.
+func (a *TerminalApprover) Password(ctx context.Context, p Prompt) (string, error) {
+	pw, err := a.ask(ctx, p, "Password: ", true)
+	if err != nil {
+		return "", err
+	}
+	if pw == "" {
+		return "", fmt.Errorf("empty password")
+	}
+	return pw, nil
+}
+
+func (a *TerminalApprover) ask(ctx context.Context, p Prompt, question string, secret bool) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path := a.TTY
+	if path == "" {
+		path = "/dev/tty"
+	}
+	// Non-blocking, so the runtime poller owns the descriptor and a read
+	// deadline can interrupt a pending read when the request is cancelled.
+	tty, err := os.OpenFile(path, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal for approval prompt: %w", err)
+	}
+	defer tty.Close()
+
+	// The terminal may be in raw mode while a container is attached, so
+	// lines end in \r\n explicitly.
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r\n\033[1;33m[%s]\033[0m %s\r\n", p.Header, p.Title)
+	for _, f := range p.Fields {
+		fmt.Fprintf(&b, "  %s: \033[1m%s\033[0m\r\n", f.Label, sanitizeForShell(f.Value))
+	}
+	b.WriteString("  " + question)
+	if _, err := io.WriteString(tty, b.String()); err != nil {
+		return "", err
+	}
+
+	if secret {
+		// Raw mode turns echo off; the state is restored before returning,
+		// also when the request is cancelled.
+		restore, err := rawMode(tty)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	type answer struct {
+		text string
+		err  error
+	}
+	done := make(chan answer, 1)
+	go func() {
+		line, err := readTerminalLine(tty)
+		done <- answer{line, err}
+	}()
+
+	select {
+	case ans := <-done:
+		if secret {
+			_, _ = io.WriteString(tty, "\r\n")
+		}
+		return ans.text, ans.err
+	case <-ctx.Done():
+		// Stop the pending read so it does not swallow the next key press.
+		_ = tty.SetReadDeadline(time.Now())
+		<-done
+		_, _ = io.WriteString(tty, "\r\n  (request cancelled)\r\n")
+		return "", ctx.Err()
+	}
+}
+
+// rawMode puts tty into raw mode and returns a function restoring its
+// previous state. It goes through SyscallConn because File.Fd would put
+// the descriptor back into blocking mode.
+func rawMode(tty *os.File) (func(), error) {
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var state *term.State
+	if ctlErr := conn.Control(func(fd uintptr) { state, err = term.MakeRaw(int(fd)) }); ctlErr != nil {
+		return nil, ctlErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("disable terminal echo: %w", err)
+	}
+	return func() {
+		_ = conn.Control(func(fd uintptr) { _ = term.Restore(int(fd), state) })
+	}, nil
+}
+
+// readTerminalLine reads up to \n or \r, whichever the terminal mode sends.
+// In raw mode it also handles backspace and treats Ctrl-C as cancelling.
+func readTerminalLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n == 1 {
+			switch buf[0] {
+			case '\n', '\r':
+				return string(line), nil
+			case 0x7f, '\b':
+				if len(line) > 0 {
+					line = line[:len(line)-1]
+				}
+			case 0x03:
+				return "", fmt.Errorf("prompt cancelled")
+			default:
+				line = append(line, buf[0])
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				return string(line), nil
+			}
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package ipc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubApprover answers every prompt the same way and records them.
+type stubApprover struct {
+	approve  bool
+	password string
+	prompts  []Prompt
+}
+
+func (s *stubApprover) Confirm(_ context.Context, p Prompt) (bool, error) {
+	s.prompts = append(s.prompts, p)
+	return s.approve, nil
+}
+
+func (s *stubApprover) Password(_ context.Context, p Prompt) (string, error) {
+	s.prompts = append(s.prompts, p)
+	return s.password, nil
+}
+
+func TestNewApprover(t *testing.T) {
+	for kind, want := range map[string]string{
+		"":         "*ipc.TmuxApprover",
+		"tmux":     "*ipc.TmuxApprover",
+		"terminal": "*ipc.TerminalApprover",
+		"desktop":  "*ipc.DesktopApprover",
+		"queue":    "*ipc.QueueApprover",
+	} {
+		a, err := NewApprover(kind, nil, "ctr")
+		if err != nil {
+			t.Fatalf("NewApprover(%q): %v", kind, err)
+		}
+		if got := fmt.Sprintf("%T", a); got != want {
+			t.Errorf("NewApprover(%q) = %s, want %s", kind, got, want)
+		}
+	}
+	if _, err := NewApprover("carrier-pigeon", nil, "ctr"); err == nil {
+		t.Error("expected error for unknown approver")
+	}
+}
+
+func TestPopupTextSanitizesValues(t *testing.T) {
+	got := popupText(Prompt{
+		Header: "ExitBox",
+		Title:  "Allow domain access?",
+		Fields: []Field{{Label: "Domain", Value: "evil.com'; rm -rf / #"}},
+	})
+	if strings.Contains(got, "'") || strings.Contains(got, "#") {
+		t.Errorf("popup text not sanitized: %q", got)
+	}
+	if !strings.Contains(got, "Domain: ") || !strings.Contains(got, "evil.com rm -rf") {
+		t.Errorf("popup text = %q", got)
+	}
+}
+
+func TestDesktopApproverFallsBack(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	origLookPath := lookPath
+	lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	defer func() { lookPath = origLookPath }()
+
+	fallback := &stubApprover{approve: true, password: "pw"}
+	a := &DesktopApprover{Fallback: fallback}
+	ok, err := a.Confirm(context.Background(), Prompt{Title: "Allow?"})
+	if err != nil || !ok {
+		t.Errorf("Confirm = %v, %v; want fallback answer", ok, err)
+	}
+	pw, err := a.Password(context.Background(), Prompt{Title: "Password"})
+	if err != nil || pw != "pw" {
+		t.Errorf("Password = %q, %v; want fallback answer", pw, err)
+	}
+	if len(fallback.prompts) != 2 {
+		t.Errorf("fallback got %d prompts, want 2", len(fallback.prompts))
+	}
+}
+
+// waitPending waits for one request to appear in the queue.
+func waitPending(t *testing.T, dir string) PendingApproval {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		pending, err := ListApprovals(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == 1 {
+			return pending[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("request never queued")
+	return PendingApproval{}
+}
+
+func TestQueueApprover(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "approvals")
+	a := &QueueApprover{Dir: dir, Session: "exitbox-claude-app"}
+	prompt := Prompt{Header: "ExitBox", Title: "Allow domain access?", Fields: []Field{{Label: "Domain", Value: "example.com"}}}
+
+	for _, approve := range []bool{true, false} {
+		result := make(chan bool, 1)
+		go func() {
+			ok, err := a.Confirm(context.Background(), prompt)
+			if err != nil {
+				t.Errorf("Confirm: %v", err)
+			}
+			result <- ok
+		}()
+
+		p := waitPending(t, dir)
+		if p.Session != "exitbox-claude-app" || p.Title != prompt.Title || p.Fields[0].Value != "example.com" || p.Secret {
+			t.Errorf("pending = %+v", p)
+		}
+		if err := AnswerApproval(dir, p.ID, approve, ""); err != nil {
+			t.Fatalf("AnswerApproval: %v", err)
+		}
+		if got := <-result; got != approve {
+			t.Errorf("Confirm = %v, want %v", got, approve)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("queue not cleaned up: %d files left", len(entries))
+	}
+}
+
+func TestQueueApproverPassword(t *testing.T) {
+	dir := t.TempDir()
+	a := &QueueApprover{Dir: dir}
+	result := make(chan string, 1)
+	go func() {
+		pw, err := a.Password(context.Background(), Prompt{Title: "Enter vault password:"})
+		if err != nil {
+			t.Errorf("Password: %v", err)
+		}
+		result <- pw
+	}()
+
+	p := waitPending(t, dir)
+	if !p.Secret {
+		t.Error("password request not marked secret")
+	}
+	if err := AnswerApproval(dir, p.ID, true, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-result; got != "hunter2" {
+		t.Errorf("Password = %q", got)
+	}
+}
+
+func TestQueueApproverCancel(t *testing.T) {
+	dir := t.TempDir()
+	a := &QueueApprover{Dir: dir}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Confirm(ctx, Prompt{Title: "Allow?"})
+		done <- err
+	}()
+
+	p := waitPending(t, dir)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Confirm after cancel = %v", err)
+	}
+	if err := AnswerApproval(dir, p.ID, true, ""); err == nil {
+		t.Error("answering a cancelled request should fail")
+	}
+}
+
+func TestAnswerApprovalRejectsBadIDs(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"", "../config", "deadbeef"} {
+		if err := AnswerApproval(dir, id, true, ""); err == nil {
+			t.Errorf("AnswerApproval(%q) succeeded", id)
+		}
+	}
+}
+
+func TestReadTerminalLine(t *testing.T) {
+	for input, want := range map[string]string{
+		"y\n":        "y",
+		"yes\r":      "yes",
+		"no":         "no",
+		"yex\x7fs\r": "yes",
+	} {
+		got, err := readTerminalLine(strings.NewReader(input))
+		if err != nil || got != want {
+			t.Errorf("readTerminalLine(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := readTerminalLine(strings.NewReader("hun\x03")); err == nil {
+		t.Error("Ctrl-C should cancel the prompt")
+	}
+}
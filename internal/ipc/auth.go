@@ -0,0 +1,119 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+)
+
+// TokenDir is the directory next to the socket holding one file per
+// helper, named after it, with that helper's token.
+const TokenDir = "tokens"
+
+// alwaysAllowed are message types every authenticated helper may send.
+var alwaysAllowed = map[string]bool{"hello": true, "status": true}
+
+// scope is what one helper's token lets it do.
+type scope struct {
+	helper string
+	token  string
+	types  map[string]bool
+}
+
+func (sc *scope) allows(msgType string) bool {
+	return alwaysAllowed[msgType] || sc.types[msgType]
+}
+
+// Scope creates a random token for helper that permits only the given
+// message types. Every request must carry a token created this way; the
+// tokens reach the container through WriteTokens. Must be called before Start.
+func (s *Server) Scope(helper string, types ...string) string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("ipc: no randomness for session token: " + err.Error())
+	}
+	sc := &scope{helper: helper, token: hex.EncodeToString(b), types: make(map[string]bool)}
+	for _, t := range types {
+		sc.types[t] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scopes = append(s.scopes, sc)
+	return sc.token
+}
+
+// WriteTokens writes each helper's token to TokenDir in the socket
+// directory, readable only by the owner. The tokens thus stay out of the
+// container's environment, which every process inherits and the runtime
+// shows in inspect output.
+func (s *Server) WriteTokens() error {
+	dir := filepath.Join(s.socketDir, TokenDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create token dir: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sc := range s.scopes {
+		path := filepath.Join(dir, sc.helper)
+		_ = os.Remove(path)
+		if err := os.WriteFile(path, []byte(sc.token), 0o400); err != nil {
+			return fmt.Errorf("write %s token: %w", sc.helper, err)
+		}
+	}
+	return nil
+}
+
+// authenticate returns the scope token belongs to, or nil.
+func (s *Server) authenticate(token string) *scope {
+	if token == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found *scope
+	for _, sc := range s.scopes {
+		if subtle.ConstantTimeCompare([]byte(sc.token), []byte(token)) == 1 {
+			found = sc
+		}
+	}
+	return found
+}
+
+// reject records a refused request.
+func (s *Server) reject(req *Request, sc *scope, reason string) {
+	from := "unauthenticated client"
+	if sc != nil {
+		from = sc.helper
+	}
+	s.logger.Printf("rejected %q (id %q) from %s: %s", req.Type, req.ID, from, reason)
+	if err := s.audit.Record(audit.Event{
+		Type:    audit.IPCRejected,
+		Target:  req.Type,
+		Outcome: audit.Denied,
+		Detail:  from + ": " + reason,
+	}); err != nil {
+		s.logger.Printf("audit: %v", err)
+	}
+}
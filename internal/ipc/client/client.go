@@ -0,0 +1,244 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package client is the container side of the ExitBox IPC protocol. It is
+// shared by the helper binaries (exitbox-allow, exitbox-kv, exitbox-vault,
+// exitbox-notify, exitbox-clip, exitbox-open, exitbox-fetch, exitbox-tool,
+// exitbox-host) and deliberately imports nothing else from ExitBox so they
+// stay small.
+package client
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProtocolVersion is the IPC protocol version spoken by this package.
+//
+// Version 1 hosts accept a single request per connection and have no
+// hello exchange. Version 2 adds hello and keeps connections open for
+// further requests.
+const ProtocolVersion = 2
+
+// DefaultSocket is where the host IPC socket is mounted in the container.
+const DefaultSocket = "/run/exitbox/host.sock"
+
+// ErrUnavailable is returned when the host IPC socket cannot be reached.
+var ErrUnavailable = errors.New("IPC socket not available")
+
+// ErrUnsupported is returned by Call when the host does not handle the
+// requested message type, usually because ExitBox on the host is older
+// than the helper.
+var ErrUnsupported = errors.New("not supported by this ExitBox host")
+
+// TokenDir is the directory next to the socket holding each helper's
+// session token in a file named after the helper.
+const TokenDir = "tokens"
+
+// ErrUnauthorized is returned when the host rejects the session token,
+// usually because the helper runs outside the session that started it.
+var ErrUnauthorized = errors.New("not authorized by the ExitBox host")
+
+type request struct {
+	Type    string      `json:"type"`
+	ID      string      `json:"id"`
+	Payload interface{} `json:"payload"`
+	Token   string      `json:"token,omitempty"`
+}
+
+type response struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type helloRequest struct {
+	Version int    `json:"version"`
+	Client  string `json:"client"`
+}
+
+type helloResponse struct {
+	Version int      `json:"version"`
+	Types   []string `json:"types"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Client is a connection to the host IPC server.
+type Client struct {
+	path    string
+	token   string
+	conn    net.Conn
+	reader  *bufio.Reader
+	used    bool
+	version int
+	types   map[string]bool
+}
+
+// SocketPath returns the host socket path, honouring EXITBOX_IPC_SOCKET.
+func SocketPath() string {
+	if p := os.Getenv("EXITBOX_IPC_SOCKET"); p != "" {
+		return p
+	}
+	return DefaultSocket
+}
+
+// Token returns name's session token, or "" when it has none.
+func Token(name string) string {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(SocketPath()), TokenDir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Dial connects to the host socket and negotiates the protocol version.
+// name identifies the helper to the host and selects its session token.
+func Dial(name string) (*Client, error) {
+	return DialPath(SocketPath(), name, Token(name))
+}
+
+// DialPath is Dial with an explicit socket path and token.
+func DialPath(path, name, token string) (*Client, error) {
+	c := &Client{path: path, token: token}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	var hello helloResponse
+	if err := c.roundTrip("hello", helloRequest{Version: ProtocolVersion, Client: name}, &hello); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if hello.Error != "" || hello.Version < 2 {
+		// A version 1 host answers hello with "unknown message type" and
+		// closes the connection. Fall back to one connection per request.
+		c.version = 1
+		return c, nil
+	}
+
+	c.version = hello.Version
+	if c.version > ProtocolVersion {
+		c.version = ProtocolVersion
+	}
+	c.types = make(map[string]bool, len(hello.Types))
+	for _, t := range hello.Types {
+		c.types[t] = true
+	}
+	return c, nil
+}
+
+// Version returns the negotiated protocol version.
+func (c *Client) Version() int {
+	return c.version
+}
+
+// Supports reports whether the host handles msgType. Version 1 hosts do
+// not advertise their types, so Supports returns true for them.
+func (c *Client) Supports(msgType string) bool {
+	if c.types == nil {
+		return true
+	}
+	return c.types[msgType]
+}
+
+// Call sends a request and decodes the response payload into result.
+// Application-level errors are left in result for the caller to inspect.
+func (c *Client) Call(msgType string, payload, result interface{}) error {
+	if !c.Supports(msgType) {
+		return fmt.Errorf("%s: %w", msgType, ErrUnsupported)
+	}
+	if c.version < 2 && c.used {
+		c.conn.Close()
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+	return c.roundTrip(msgType, payload, result)
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+func (c *Client) connect() error {
+	conn, err := net.Dial("unix", c.path)
+	if err != nil {
+		return ErrUnavailable
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.used = false
+	return nil
+}
+
+// roundTrip writes one request line and reads its response line.
+func (c *Client) roundTrip(msgType string, payload, result interface{}) error {
+	c.used = true
+	id := randomID()
+	data, err := json.Marshal(request{Type: msgType, ID: id, Payload: payload, Token: c.token})
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("no response from host")
+		}
+		return err
+	}
+
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return err
+	}
+	if resp.ID != "" && resp.ID != id {
+		return fmt.Errorf("response id %q does not match request", resp.ID)
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(resp.Payload, &e) == nil {
+		switch {
+		case e.Error == "unauthorized":
+			return ErrUnauthorized
+		case msgType != "hello" && strings.HasPrefix(e.Error, "unknown message type"):
+			return fmt.Errorf("%s: %w", msgType, ErrUnsupported)
+		}
+	}
+	return json.Unmarshal(resp.Payload, result)
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,179 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/ipc"
+)
+
+func TestClientHelloAndMultipleRequests(t *testing.T) {
+	srv, err := ipc.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	var calls int32
+	srv.Handle("echo", func(req *ipc.Request) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		var p map[string]string
+		if err := json.Unmarshal(req.Payload, &p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+	srv.Start()
+
+	c, err := DialPath(filepath.Join(srv.SocketDir(), "host.sock"), "test", srv.Scope("test", "echo"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	if c.Version() != ProtocolVersion {
+		t.Errorf("version = %d, want %d", c.Version(), ProtocolVersion)
+	}
+	if !c.Supports("echo") || c.Supports("missing") {
+		t.Errorf("advertised types not applied")
+	}
+
+	for _, msg := range []string{"one", "two", "three"} {
+		var got map[string]string
+		if err := c.Call("echo", map[string]string{"msg": msg}, &got); err != nil {
+			t.Fatalf("Call(%s): %v", msg, err)
+		}
+		if got["msg"] != msg {
+			t.Errorf("echo = %q, want %q", got["msg"], msg)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("handler calls = %d, want 3", n)
+	}
+
+	if err := c.Call("missing", struct{}{}, &struct{}{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Call(missing) = %v, want ErrUnsupported", err)
+	}
+}
+
+// legacyServer answers one request per connection like a version 1 host.
+func legacyServer(t *testing.T) (string, *int32) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "host.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var conns int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			scanner := bufio.NewScanner(conn)
+			if scanner.Scan() {
+				var req request
+				_ = json.Unmarshal(scanner.Bytes(), &req)
+				payload := map[string]interface{}{"error": "unknown message type: " + req.Type}
+				if req.Type == "kv_get" {
+					payload = map[string]interface{}{"value": "v", "found": true}
+				}
+				data, _ := json.Marshal(map[string]interface{}{"type": req.Type, "id": req.ID, "payload": payload})
+				_, _ = conn.Write(append(data, '\n'))
+			}
+			conn.Close()
+		}
+	}()
+	return path, &conns
+}
+
+func TestClientLegacyHost(t *testing.T) {
+	path, conns := legacyServer(t)
+
+	c, err := DialPath(path, "test", "")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	if c.Version() != 1 {
+		t.Errorf("version = %d, want 1", c.Version())
+	}
+	for i := 0; i < 2; i++ {
+		var got struct {
+			Value string `json:"value"`
+			Found bool   `json:"found"`
+		}
+		if err := c.Call("kv_get", map[string]string{"key": "k"}, &got); err != nil {
+			t.Fatalf("Call: %v", err)
+		}
+		if !got.Found || got.Value != "v" {
+			t.Errorf("kv_get = %+v", got)
+		}
+	}
+	// hello plus one connection per request.
+	if n := atomic.LoadInt32(conns); n != 3 {
+		t.Errorf("connections = %d, want 3", n)
+	}
+
+	if err := c.Call("vault_get", struct{}{}, &struct{}{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Call(vault_get) = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestDialUnavailable(t *testing.T) {
+	_, err := DialPath(filepath.Join(t.TempDir(), "missing.sock"), "test", "")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Dial = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestTokenReadsHelperFile(t *testing.T) {
+	srv, err := ipc.NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	kv := srv.Scope("exitbox-kv", "kv_get")
+	vault := srv.Scope("exitbox-vault", "vault_get")
+	if err := srv.WriteTokens(); err != nil {
+		t.Fatalf("WriteTokens: %v", err)
+	}
+	t.Setenv("EXITBOX_IPC_SOCKET", filepath.Join(srv.SocketDir(), "host.sock"))
+
+	if got := Token("exitbox-kv"); got != kv {
+		t.Errorf("Token(exitbox-kv) = %q, want %q", got, kv)
+	}
+	if got := Token("exitbox-vault"); got != vault {
+		t.Errorf("Token(exitbox-vault) = %q, want %q", got, vault)
+	}
+	if got := Token("exitbox-notify"); got != "" {
+		t.Errorf("Token(exitbox-notify) = %q, want none", got)
+	}
+	info, err := os.Stat(filepath.Join(srv.SocketDir(), TokenDir, "exitbox-kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o400 {
+		t.Errorf("token file mode = %o, want 400", perm)
+	}
+}
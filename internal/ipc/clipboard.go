@@ -0,0 +1,104 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// hostCopy puts text on the host clipboard: pbcopy on macOS, wl-copy or
+// xclip on Linux, and otherwise an OSC 52 escape written to the terminal,
+// which most terminal emulators turn into a clipboard write.
+func hostCopy(text string) error {
+	if argv := clipboardCommand(true); argv != nil {
+		// Output is not captured: wl-copy and xclip leave a child behind to
+		// serve the selection, which would hold a pipe open.
+		c := exec.Command(argv[0], argv[1:]...)
+		c.Stdin = strings.NewReader(text)
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("%s: %w", argv[0], err)
+		}
+		return nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("no clipboard tool found and no terminal for OSC 52: %w", err)
+	}
+	defer tty.Close()
+	_, err = fmt.Fprintf(tty, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// hostPaste reads the host clipboard. Unlike copying, there is no terminal
+// fallback: most terminals refuse OSC 52 reads.
+func hostPaste() (string, error) {
+	argv := clipboardCommand(false)
+	if argv == nil {
+		return "", fmt.Errorf("no clipboard tool found (install wl-clipboard or xclip)")
+	}
+	var stderr bytes.Buffer
+	c := exec.Command(argv[0], argv[1:]...)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v: %s", argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// clipTool is a clipboard program, used only when env is set (if any).
+type clipTool struct {
+	env  string
+	argv []string
+}
+
+// clipboardCommand returns the command line that writes or reads
+// the host clipboard, or nil if no tool is available.
+func clipboardCommand(write bool) []string {
+	var tools []clipTool
+	switch {
+	case runtime.GOOS == "darwin" && write:
+		tools = []clipTool{{"", []string{"pbcopy"}}}
+	case runtime.GOOS == "darwin":
+		tools = []clipTool{{"", []string{"pbpaste"}}}
+	case write:
+		tools = []clipTool{
+			{"WAYLAND_DISPLAY", []string{"wl-copy"}},
+			{"DISPLAY", []string{"xclip", "-selection", "clipboard"}},
+		}
+	default:
+		tools = []clipTool{
+			{"WAYLAND_DISPLAY", []string{"wl-paste", "--no-newline"}},
+			{"DISPLAY", []string{"xclip", "-selection", "clipboard", "-o"}},
+		}
+	}
+	for _, t := range tools {
+		if t.env != "" && os.Getenv(t.env) == "" {
+			continue
+		}
+		if _, err := lookPath(t.argv[0]); err == nil {
+			return t.argv
+		}
+	}
+	return nil
+}
@@ -17,34 +17,48 @@
 package ipc
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 
+	"github.com/cloud-exit/exitbox/internal/audit"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/policy"
 )
 
 // AllowDomainHandlerConfig holds dependencies for the allow_domain handler.
 type AllowDomainHandlerConfig struct {
 	Runtime       container.Runtime
 	ContainerName string
-	// PromptFunc overrides the tmux popup prompt for testing.
+	// Denylist holds entries that are refused without prompting.
+	Denylist []string
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// PromptFunc overrides the approval prompt for testing.
 	PromptFunc func(domain string) (bool, error)
 	// ReloadFunc overrides domain reload for testing.
 	ReloadFunc func(domain string) error
 }
 
 // NewAllowDomainHandler returns a HandlerFunc that validates a domain,
-// prompts the user via a tmux popup in the container, and hot-reloads
-// Squid on approval.
+// checks the policy, asks the user through the configured Approver if no
+// rule decided, and hot-reloads Squid on approval.
 func NewAllowDomainHandler(cfg AllowDomainHandlerConfig) HandlerFunc {
-	promptFn := cfg.PromptFunc
-	if promptFn == nil {
-		promptFn = func(domain string) (bool, error) {
-			return promptViaTmuxPopup(cfg.Runtime, cfg.ContainerName, domain)
+	approver := approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName)
+	promptFn := func(ctx context.Context, domain string) (bool, error) {
+		return approver.Confirm(ctx, Prompt{
+			Header: "ExitBox",
+			Title:  "Allow domain access?",
+			Fields: []Field{{Label: "Domain", Value: domain}},
+		})
+	}
+	if cfg.PromptFunc != nil {
+		promptFn = func(_ context.Context, domain string) (bool, error) {
+			return cfg.PromptFunc(domain)
 		}
 	}
 
@@ -72,17 +86,33 @@ func NewAllowDomainHandler(cfg AllowDomainHandlerConfig) HandlerFunc {
 			return AllowDomainResponse{Error: fmt.Sprintf("invalid domain: %v", err)}, nil
 		}
 
-		approved, err := promptFn(domain)
+		if network.IsDenied(domain, cfg.Denylist) {
+			req.record(audit.Event{Type: req.Type, Target: domain, Outcome: audit.Denied, Detail: "denylist"})
+			return AllowDomainResponse{Error: fmt.Sprintf("domain %s is on the denylist", domain)}, nil
+		}
+
+		approved, d, err := approve(req, cfg.Policy, domain, promptFn)
 		if err != nil {
+			recordFailure(req, domain, err)
 			return AllowDomainResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
 		}
+		recordDecision(req, domain, approved, d)
+		if d.Action == policy.Deny {
+			return AllowDomainResponse{Error: deniedByPolicy(d)}, nil
+		}
 
 		if !approved {
 			return AllowDomainResponse{Approved: false}, nil
 		}
 
 		// Use the normalized form for Squid (may have leading dot for hostnames).
-		if err := reloadFn(normalized); err != nil {
+		err = reloadFn(normalized)
+		reload := audit.Event{Type: audit.FirewallReload, Target: normalized, Outcome: audit.OK}
+		if err != nil {
+			reload.Outcome, reload.Detail = audit.Failed, err.Error()
+		}
+		req.record(reload)
+		if err != nil {
 			return AllowDomainResponse{Error: fmt.Sprintf("failed to update firewall: %v", err)}, nil
 		}
 
@@ -90,57 +120,16 @@ func NewAllowDomainHandler(cfg AllowDomainHandlerConfig) HandlerFunc {
 	}
 }
 
-// promptViaTmuxPopup shows a tmux display-popup inside the agent container.
-// The host execs into the container's tmux to present an interactive popup
-// overlaying the agent session. This avoids competing with tmux for /dev/tty.
-//
-// The popup script reads a line and exits 0 on "y"/"yes", 1 otherwise.
-// tmux display-popup -E returns the script's exit code.
-func promptViaTmuxPopup(rt container.Runtime, containerName, domain string) (bool, error) {
-	cmd := container.Cmd(rt)
-
-	// Sanitize domain for shell embedding — only keep safe chars.
-	safeDomain := sanitizeForShell(domain)
-
-	// Shell script runs inside the popup. Uses `read` (not `read -n1`)
-	// so the user types y + Enter, which works in all terminals.
-	script := `printf '\n  \033[1;33m[ExitBox]\033[0m Allow domain access?\n\n  Domain: \033[1m` +
-		safeDomain +
-		`\033[0m\n\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
-
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "50", "-h", "8",
-		"sh", "-c", script,
-	)
-
-	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	err := c.Run()
-
-	if err == nil {
-		return true, nil // exit 0 = approved
-	}
-
-	// If stderr is empty, the popup ran and the user denied or dismissed it.
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if stderr.Len() == 0 {
-			return false, nil
-		}
-		return false, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
-	}
-
-	return false, fmt.Errorf("popup exec failed: %w", err)
-}
-
 // sanitizeForShell strips any characters that aren't safe for embedding
 // in a single-quoted shell string. Allows alphanumeric, dots, dashes, colons,
-// underscores, and spaces (vault keys use underscores, display labels use spaces).
+// underscores, spaces and slashes (vault keys use underscores, display labels
+// use spaces, file paths use slashes).
 func sanitizeForShell(s string) string {
 	var b strings.Builder
 	for _, r := range s {
 		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
 			(r >= '0' && r <= '9') || r == '.' || r == '-' || r == ':' ||
-			r == '_' || r == ' ' {
+			r == '_' || r == ' ' || r == '/' {
 			b.WriteRune(r)
 		}
 	}
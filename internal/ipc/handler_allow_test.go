@@ -4,7 +4,12 @@ import (
 	"bufio"
 	"encoding/json"
 	"net"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/policy"
 )
 
 func TestAllowDomainHandlerApproved(t *testing.T) {
@@ -127,6 +132,7 @@ func sendAllowDomain(t *testing.T, srv *Server, domain string) AllowDomainRespon
 		Type:    "allow_domain",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.Scope("test", "allow_domain"),
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -156,3 +162,106 @@ func sendAllowDomain(t *testing.T, srv *Server, domain string) AllowDomainRespon
 	}
 	return result
 }
+
+func TestAllowDomainHandlerDenylisted(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
+		Denylist: []string{"*.amazonaws.com"},
+		PromptFunc: func(domain string) (bool, error) {
+			t.Error("prompt should not be called for denied domain")
+			return true, nil
+		},
+		ReloadFunc: func(domain string) error {
+			t.Error("reload should not be called for denied domain")
+			return nil
+		},
+	}))
+	srv.Start()
+
+	resp := sendAllowDomain(t, srv, "s3.amazonaws.com")
+	if resp.Approved {
+		t.Error("expected approved=false for denied domain")
+	}
+	if resp.Error == "" {
+		t.Error("expected error for denied domain")
+	}
+}
+
+type stubPolicy map[string]policy.Decision
+
+func (p stubPolicy) Decide(msgType, target string) (policy.Decision, error) {
+	if d, ok := p[msgType+" "+target]; ok {
+		return d, nil
+	}
+	return policy.Decision{Action: policy.Prompt}, nil
+}
+
+func TestAllowDomainHandlerPolicy(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	srv.SetAuditLog(&audit.Log{Path: auditPath})
+
+	prompted := map[string]bool{}
+	reloaded := map[string]bool{}
+	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
+		Policy: stubPolicy{
+			"allow_domain registry.npmjs.org": {Action: policy.Allow, Rule: "npm", Source: "project"},
+			"allow_domain pastebin.com":       {Action: policy.Deny, Rule: "no-paste", Source: "global"},
+		},
+		PromptFunc: func(domain string) (bool, error) {
+			prompted[domain] = true
+			return false, nil
+		},
+		ReloadFunc: func(domain string) error {
+			reloaded[domain] = true
+			return nil
+		},
+	}))
+	srv.Start()
+
+	if resp := sendAllowDomain(t, srv, "registry.npmjs.org"); !resp.Approved || resp.Error != "" {
+		t.Errorf("allowed by policy: got %+v", resp)
+	}
+	resp := sendAllowDomain(t, srv, "pastebin.com")
+	if resp.Approved || resp.Error != `denied by global policy rule "no-paste"` {
+		t.Errorf("denied by policy: got %+v", resp)
+	}
+	if resp := sendAllowDomain(t, srv, "example.com"); resp.Approved {
+		t.Errorf("no rule: got %+v, want the prompt's answer", resp)
+	}
+
+	if prompted["registry.npmjs.org"] || prompted["pastebin.com"] || !prompted["example.com"] {
+		t.Errorf("prompted for %v, want only example.com", prompted)
+	}
+	if len(reloaded) != 1 {
+		t.Errorf("reloaded %v, want only registry.npmjs.org", reloaded)
+	}
+
+	events, err := audit.Read(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Type+" "+ev.Target+" "+ev.Outcome+" "+ev.Detail)
+	}
+	want := []string{
+		`allow_domain registry.npmjs.org approved project policy rule "npm"`,
+		"firewall_reload .registry.npmjs.org ok ",
+		`allow_domain pastebin.com denied global policy rule "no-paste"`,
+		"allow_domain example.com denied user",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
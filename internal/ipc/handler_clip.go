@@ -0,0 +1,185 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/policy"
+	"github.com/cloud-exit/exitbox/internal/redactor"
+)
+
+// maxClipSize bounds clipboard text in either direction. Requests are
+// limited to maxRequestSize, so copies are smaller still.
+const maxClipSize = 32 * 1024
+
+// ClipHandlerConfig holds dependencies for the clipboard handlers.
+type ClipHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// Secrets returns vault values (value -> key) that are redacted from
+	// clipboard text in both directions; nil redacts nothing.
+	Secrets redactor.SecretProvider
+	// PromptFunc overrides the approval prompt for testing.
+	PromptFunc func(p Prompt) (bool, error)
+	// CopyFunc overrides writing the host clipboard for testing.
+	CopyFunc func(text string) error
+	// PasteFunc overrides reading the host clipboard for testing.
+	PasteFunc func() (string, error)
+}
+
+func (cfg ClipHandlerConfig) prompt() func(context.Context, Prompt) (bool, error) {
+	if cfg.PromptFunc != nil {
+		return func(_ context.Context, p Prompt) (bool, error) {
+			return cfg.PromptFunc(p)
+		}
+	}
+	approver := approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName)
+	return approver.Confirm
+}
+
+// redact replaces known vault secrets in text.
+func (cfg ClipHandlerConfig) redact(text string) (string, bool) {
+	if cfg.Secrets == nil {
+		return text, false
+	}
+	out := string(redactor.NewWithProvider(cfg.Secrets).Filter([]byte(text)))
+	return out, out != text
+}
+
+// NewClipCopyHandler returns a HandlerFunc for "clip_copy" requests. The
+// text is redacted, shown for approval, and put on the host clipboard.
+func NewClipCopyHandler(cfg ClipHandlerConfig) HandlerFunc {
+	confirm := cfg.prompt()
+	copyFn := cfg.CopyFunc
+	if copyFn == nil {
+		copyFn = hostCopy
+	}
+
+	return func(req *Request) (interface{}, error) {
+		var payload ClipCopyRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return ClipCopyResponse{Error: "invalid payload"}, nil
+		}
+		if payload.Text == "" {
+			return ClipCopyResponse{Error: "nothing to copy"}, nil
+		}
+		if len(payload.Text) > maxClipSize {
+			return ClipCopyResponse{Error: fmt.Sprintf("text too large (%d bytes, limit %d)", len(payload.Text), maxClipSize)}, nil
+		}
+
+		text, redacted := cfg.redact(payload.Text)
+		approved, d, err := approve(req, cfg.Policy, "", func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, clipPrompt("Copy to host clipboard?", text, redacted))
+		})
+		if err != nil {
+			recordFailure(req, "", err)
+			return ClipCopyResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, clipSummary(text), approved, d)
+		if d.Action == policy.Deny {
+			return ClipCopyResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return ClipCopyResponse{Approved: false}, nil
+		}
+
+		if err := copyFn(text); err != nil {
+			return ClipCopyResponse{Error: fmt.Sprintf("clipboard write failed: %v", err)}, nil
+		}
+		return ClipCopyResponse{Approved: true, Redacted: redacted}, nil
+	}
+}
+
+// NewClipPasteHandler returns a HandlerFunc for "clip_paste" requests. The
+// host clipboard is read, shown for approval, and returned redacted.
+func NewClipPasteHandler(cfg ClipHandlerConfig) HandlerFunc {
+	confirm := cfg.prompt()
+	pasteFn := cfg.PasteFunc
+	if pasteFn == nil {
+		pasteFn = hostPaste
+	}
+
+	return func(req *Request) (interface{}, error) {
+		raw, err := pasteFn()
+		if err != nil {
+			return ClipPasteResponse{Error: fmt.Sprintf("clipboard read failed: %v", err)}, nil
+		}
+		if raw == "" {
+			return ClipPasteResponse{Error: "host clipboard is empty"}, nil
+		}
+		if len(raw) > maxClipSize {
+			return ClipPasteResponse{Error: fmt.Sprintf("clipboard too large (%d bytes, limit %d)", len(raw), maxClipSize)}, nil
+		}
+		if !utf8.ValidString(raw) {
+			return ClipPasteResponse{Error: "host clipboard does not hold text"}, nil
+		}
+
+		text, redacted := cfg.redact(raw)
+		approved, d, err := approve(req, cfg.Policy, "", func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, clipPrompt("Paste host clipboard into sandbox?", text, redacted))
+		})
+		if err != nil {
+			recordFailure(req, "", err)
+			return ClipPasteResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, clipSummary(text), approved, d)
+		if d.Action == policy.Deny {
+			return ClipPasteResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return ClipPasteResponse{Approved: false}, nil
+		}
+		return ClipPasteResponse{Text: text, Approved: true, Redacted: redacted}, nil
+	}
+}
+
+// clipPrompt asks about clipboard text, showing its size and first line.
+func clipPrompt(title, text string, redacted bool) Prompt {
+	fields := []Field{
+		{Label: "Size", Value: clipSummary(text)},
+		{Label: "Starts", Value: clipPreview(text)},
+	}
+	if redacted {
+		fields = append(fields, Field{Label: "Note", Value: "vault secrets redacted"})
+	}
+	return Prompt{Header: "ExitBox", Title: title, Fields: fields}
+}
+
+// clipSummary describes text by size, for prompts and the audit log.
+func clipSummary(text string) string {
+	lines := strings.Count(strings.TrimRight(text, "\n"), "\n") + 1
+	return fmt.Sprintf("%d bytes, %d lines", len(text), lines)
+}
+
+// clipPreview returns the start of text's first line.
+func clipPreview(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if r := []rune(line); len(r) > 40 {
+		line = string(r[:40]) + "..."
+	}
+	return line
+}
@@ -0,0 +1,114 @@
+package ipc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/policy"
+)
+
+func callClip(t *testing.T, h HandlerFunc, msgType string, payload interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: msgType, Payload: data})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return out
+}
+
+func testClipSecrets() map[string]string {
+	return map[string]string{"hunter2-token": "API_KEY"}
+}
+
+func TestClipCopyHandler(t *testing.T) {
+	var prompts []Prompt
+	var copied []string
+	answer := true
+	h := NewClipCopyHandler(ClipHandlerConfig{
+		Secrets: testClipSecrets,
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompts = append(prompts, p)
+			return answer, nil
+		},
+		CopyFunc: func(text string) error {
+			copied = append(copied, text)
+			return nil
+		},
+	})
+
+	var resp ClipCopyResponse
+	_ = json.Unmarshal(callClip(t, h, "clip_copy", ClipCopyRequest{Text: "export KEY=hunter2-token\n"}), &resp)
+	if !resp.Approved || !resp.Redacted || resp.Error != "" {
+		t.Fatalf("copy: got %+v", resp)
+	}
+	if len(copied) != 1 || strings.Contains(copied[0], "hunter2-token") {
+		t.Fatalf("copied %q, want secret redacted", copied)
+	}
+	if len(prompts) != 1 || strings.Contains(prompts[0].Fields[1].Value, "hunter2-token") {
+		t.Errorf("prompt = %+v, want redacted preview", prompts)
+	}
+
+	answer = false
+	resp = ClipCopyResponse{}
+	_ = json.Unmarshal(callClip(t, h, "clip_copy", ClipCopyRequest{Text: "hello"}), &resp)
+	if resp.Approved || resp.Error != "" || len(copied) != 1 {
+		t.Errorf("declined copy: got %+v, copied %d", resp, len(copied))
+	}
+
+	resp = ClipCopyResponse{}
+	_ = json.Unmarshal(callClip(t, h, "clip_copy", ClipCopyRequest{Text: strings.Repeat("x", maxClipSize+1)}), &resp)
+	if !strings.HasPrefix(resp.Error, "text too large") {
+		t.Errorf("oversized copy: got %+v", resp)
+	}
+}
+
+func TestClipPasteHandler(t *testing.T) {
+	prompted := false
+	h := NewClipPasteHandler(ClipHandlerConfig{
+		Secrets: testClipSecrets,
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompted = true
+			return true, nil
+		},
+		PasteFunc: func() (string, error) {
+			return "token is hunter2-token", nil
+		},
+	})
+
+	var resp ClipPasteResponse
+	_ = json.Unmarshal(callClip(t, h, "clip_paste", ClipPasteRequest{}), &resp)
+	if !prompted || !resp.Approved || !resp.Redacted {
+		t.Fatalf("paste: got %+v (prompted %v)", resp, prompted)
+	}
+	if strings.Contains(resp.Text, "hunter2-token") || !strings.HasPrefix(resp.Text, "token is ") {
+		t.Errorf("pasted %q, want secret redacted", resp.Text)
+	}
+}
+
+func TestClipHandlerPolicy(t *testing.T) {
+	h := NewClipPasteHandler(ClipHandlerConfig{
+		Policy: stubPolicy{
+			"clip_paste ": {Action: policy.Deny, Rule: "no-paste", Source: "workspace"},
+		},
+		PromptFunc: func(p Prompt) (bool, error) {
+			t.Error("prompted despite deny rule")
+			return true, nil
+		},
+		PasteFunc: func() (string, error) { return "hello", nil },
+	})
+
+	var resp ClipPasteResponse
+	_ = json.Unmarshal(callClip(t, h, "clip_paste", ClipPasteRequest{}), &resp)
+	if resp.Approved || resp.Text != "" || resp.Error != `denied by workspace policy rule "no-paste"` {
+		t.Errorf("denied paste: got %+v", resp)
+	}
+}
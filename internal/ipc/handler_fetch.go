@@ -0,0 +1,260 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/policy"
+)
+
+// maxFetchSize bounds files copied into the session inbox.
+const maxFetchSize = 100 << 20
+
+// FetchHandlerConfig holds dependencies for the request_file handler.
+type FetchHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// InboxDir is the host directory approved files are copied to, and
+	// ContainerInboxDir the same directory as mounted in the container.
+	// InboxDir is created and opened by NewFetchHandler, which must run
+	// before the container can reach it.
+	InboxDir          string
+	ContainerInboxDir string
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// PromptFunc overrides the approval prompt for testing.
+	PromptFunc func(p Prompt) (bool, error)
+}
+
+// NewFetchHandler returns a HandlerFunc for "request_file" requests. The
+// host file is shown with its size for approval and, when approved, copied
+// into the session inbox. Credential stores and ExitBox's own directories
+// are refused without prompting.
+func NewFetchHandler(cfg FetchHandlerConfig) HandlerFunc {
+	inbox, inboxErr := openInbox(cfg.InboxDir)
+	confirm := func(ctx context.Context, p Prompt) (bool, error) {
+		return approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName).Confirm(ctx, p)
+	}
+	if cfg.PromptFunc != nil {
+		confirm = func(_ context.Context, p Prompt) (bool, error) {
+			return cfg.PromptFunc(p)
+		}
+	}
+
+	return func(req *Request) (interface{}, error) {
+		var payload FetchRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return FetchResponse{Error: "invalid payload"}, nil
+		}
+		if inboxErr != nil {
+			return FetchResponse{Error: fmt.Sprintf("inbox unavailable: %v", inboxErr)}, nil
+		}
+
+		path, err := hostFilePath(payload.Path)
+		if err != nil {
+			return FetchResponse{Error: err.Error()}, nil
+		}
+		if dir := protectedDir(path); dir != "" {
+			req.record(audit.Event{Type: req.Type, Target: path, Outcome: audit.Denied, Detail: "protected path"})
+			return FetchResponse{Error: fmt.Sprintf("%s is under %s, which cannot be shared", payload.Path, dir)}, nil
+		}
+		// The file is opened once, before prompting, and everything after
+		// (size, type, copy) goes through this descriptor, so swapping in
+		// a symlink while the prompt is open cannot change what is copied.
+		f, err := openNoFollow(path)
+		if err != nil {
+			if unwrapped := errors.Unwrap(err); unwrapped != nil {
+				err = unwrapped
+			}
+			return FetchResponse{Error: fmt.Sprintf("cannot read %s: %v", payload.Path, err)}, nil
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return FetchResponse{Error: fmt.Sprintf("cannot read %s: %v", payload.Path, errors.Unwrap(err))}, nil
+		}
+		if !info.Mode().IsRegular() {
+			return FetchResponse{Error: fmt.Sprintf("%s is not a regular file", payload.Path)}, nil
+		}
+		if info.Size() > maxFetchSize {
+			return FetchResponse{Error: fmt.Sprintf("%s is too large (%s, limit %s)", payload.Path, formatBytes(info.Size()), formatBytes(maxFetchSize))}, nil
+		}
+
+		approved, d, err := approve(req, cfg.Policy, path, func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, Prompt{
+				Header: "ExitBox",
+				Title:  "Copy host file into sandbox?",
+				Fields: []Field{
+					{Label: "Path", Value: path},
+					{Label: "Size", Value: formatBytes(info.Size())},
+				},
+			})
+		})
+		if err != nil {
+			recordFailure(req, path, err)
+			return FetchResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, path, approved, d)
+		if d.Action == policy.Deny {
+			return FetchResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return FetchResponse{Approved: false}, nil
+		}
+
+		name, size, err := copyToInbox(f, filepath.Base(path), inbox)
+		if err != nil {
+			return FetchResponse{Error: fmt.Sprintf("copy failed: %v", err)}, nil
+		}
+		return FetchResponse{
+			Approved: true,
+			Path:     filepath.ToSlash(filepath.Join(cfg.ContainerInboxDir, name)),
+			Size:     size,
+		}, nil
+	}
+}
+
+// hostFilePath returns the absolute host path for p, which must be absolute
+// or start with ~/, with symlinks resolved so protected directories cannot
+// be reached through a link.
+func hostFilePath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~: %v", err)
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	if !filepath.IsAbs(p) {
+		return "", fmt.Errorf("host path must be absolute or start with ~/")
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", p, errors.Unwrap(err))
+	}
+	return resolved, nil
+}
+
+// protectedDir returns the protected directory path lies in, or "" if
+// there is none.
+func protectedDir(path string) string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, d := range []string{".ssh", ".aws", ".gnupg"} {
+			dirs = append(dirs, filepath.Join(home, d))
+		}
+	}
+	dirs = append(dirs, config.Home, config.Data)
+	for _, dir := range dirs {
+		// The directories themselves may be symlinks.
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// openInbox creates dir unless it is already a directory and opens it.
+// The container can write to dir's parent, so files are created relative
+// to the open directory: replacing dir with a symlink later does not
+// redirect them, and a symlink already at dir is refused.
+func openInbox(dir string) (*os.Root, error) {
+	if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opened, err := root.Stat("."); err != nil || !os.SameFile(info, opened) {
+		root.Close()
+		return nil, fmt.Errorf("%s changed while it was being opened", dir)
+	}
+	return root, nil
+}
+
+// copyToInbox copies in into dir as base, adding a numeric suffix instead
+// of replacing an earlier file. It returns the name used and the number of
+// bytes copied.
+func copyToInbox(in io.Reader, base string, dir *os.Root) (string, int64, error) {
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = stem + "-" + strconv.Itoa(i) + ext
+		}
+		out, err := dir.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		// The file may have grown since it was approved.
+		n, err := io.Copy(out, io.LimitReader(in, maxFetchSize))
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = dir.Remove(name)
+			return "", 0, err
+		}
+		return name, n, nil
+	}
+}
+
+// formatBytes formats a byte count as a human-readable string.
+func formatBytes(b int64) string {
+	const (
+		kb = 1024
+		mb = 1024 * kb
+	)
+	switch {
+	case b >= mb:
+		return fmt.Sprintf("%.1f MB", float64(b)/float64(mb))
+	case b >= kb:
+		return fmt.Sprintf("%.1f KB", float64(b)/float64(kb))
+	default:
+		return fmt.Sprintf("%d B", b)
+	}
+}
@@ -0,0 +1,245 @@
+package ipc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func callFetch(t *testing.T, h HandlerFunc, path string) FetchResponse {
+	t.Helper()
+	payload, err := json.Marshal(FetchRequest{Path: path})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: "request_file", Payload: payload})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return resp.(FetchResponse)
+}
+
+func TestFetchHandler(t *testing.T) {
+	// Resolved, since the handler reports paths with symlinks resolved.
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	origHome, origData := config.Home, config.Data
+	config.Home = filepath.Join(home, ".config", "exitbox")
+	config.Data = filepath.Join(home, ".local", "share", "exitbox")
+	defer func() { config.Home, config.Data = origHome, origData }()
+
+	write := func(rel, content string) string {
+		p := filepath.Join(home, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write(".ssh/id_ed25519", "key")
+	write(".config/exitbox/host.yaml", "notify: {}")
+	spec := write("Downloads/spec.pdf", "%PDF")
+	if err := os.Symlink(filepath.Join(home, ".ssh"), filepath.Join(home, "Downloads", "keys")); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := filepath.Join(t.TempDir(), "inbox")
+	var prompts []Prompt
+	answer := true
+	h := NewFetchHandler(FetchHandlerConfig{
+		InboxDir:          inbox,
+		ContainerInboxDir: "/run/exitbox/inbox",
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompts = append(prompts, p)
+			return answer, nil
+		},
+	})
+
+	refused := map[string]string{
+		"~/.ssh/id_ed25519":           "cannot be shared",
+		"~/Downloads/keys/id_ed25519": "cannot be shared",
+		"~/.config/exitbox/host.yaml": "cannot be shared",
+		"Downloads/spec.pdf":          "must be absolute",
+		"~/Downloads":                 "not a regular file",
+		"~/Downloads/missing.pdf":     "cannot read",
+	}
+	for path, want := range refused {
+		if resp := callFetch(t, h, path); resp.Approved || !strings.Contains(resp.Error, want) {
+			t.Errorf("%s: got %+v, want error containing %q", path, resp, want)
+		}
+	}
+	if len(prompts) != 0 {
+		t.Fatalf("refused paths were prompted: %+v", prompts)
+	}
+
+	resp := callFetch(t, h, "~/Downloads/spec.pdf")
+	if !resp.Approved || resp.Path != "/run/exitbox/inbox/spec.pdf" || resp.Size != 4 {
+		t.Fatalf("first fetch: got %+v", resp)
+	}
+	if f := prompts[0].Fields; f[0].Value != spec || f[1].Value != "4 B" {
+		t.Errorf("prompt fields = %+v", f)
+	}
+	if resp := callFetch(t, h, spec); resp.Path != "/run/exitbox/inbox/spec-2.pdf" {
+		t.Errorf("second fetch: got %+v, want a new name", resp)
+	}
+	if data, err := os.ReadFile(filepath.Join(inbox, "spec-2.pdf")); err != nil || string(data) != "%PDF" {
+		t.Errorf("inbox copy = %q, %v", data, err)
+	}
+
+	answer = false
+	if resp := callFetch(t, h, spec); resp.Approved || resp.Error != "" {
+		t.Errorf("declined fetch: got %+v", resp)
+	}
+	if entries, _ := os.ReadDir(inbox); len(entries) != 2 {
+		t.Errorf("inbox has %d files, want 2", len(entries))
+	}
+}
+
+func TestFetchHandlerSymlinkSwapDuringPrompt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	for _, d := range []string{".ssh", "Downloads"} {
+		if err := os.MkdirAll(filepath.Join(home, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := filepath.Join(home, ".ssh", "id_ed25519")
+	notes := filepath.Join(home, "Downloads", "notes.txt")
+	if err := os.WriteFile(key, []byte("private key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(notes, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inbox := filepath.Join(t.TempDir(), "inbox")
+	h := NewFetchHandler(FetchHandlerConfig{
+		InboxDir:          inbox,
+		ContainerInboxDir: "/run/exitbox/inbox",
+		PromptFunc: func(Prompt) (bool, error) {
+			// While the user looks at the prompt, the file becomes a
+			// link to a protected one.
+			if err := os.Remove(notes); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(key, notes); err != nil {
+				t.Fatal(err)
+			}
+			return true, nil
+		},
+	})
+	resp := callFetch(t, h, notes)
+	if !resp.Approved {
+		t.Fatalf("fetch: got %+v", resp)
+	}
+	if data, err := os.ReadFile(filepath.Join(inbox, "notes.txt")); err != nil || string(data) != "notes" {
+		t.Errorf("inbox copy = %q, %v; want the approved file", data, err)
+	}
+}
+
+func TestFetchHandlerInboxSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	notes := filepath.Join(home, "notes.txt")
+	if err := os.WriteFile(notes, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	autostart := filepath.Join(home, ".config", "autostart")
+	if err := os.MkdirAll(autostart, 0755); err != nil {
+		t.Fatal(err)
+	}
+	approve := func(Prompt) (bool, error) { return true, nil }
+
+	// A link planted before the handler opens the inbox is refused.
+	socketDir := t.TempDir()
+	inbox := filepath.Join(socketDir, "inbox")
+	if err := os.Symlink(autostart, inbox); err != nil {
+		t.Fatal(err)
+	}
+	h := NewFetchHandler(FetchHandlerConfig{InboxDir: inbox, ContainerInboxDir: "/run/exitbox/inbox", PromptFunc: approve})
+	if resp := callFetch(t, h, notes); resp.Approved || !strings.Contains(resp.Error, "inbox unavailable") {
+		t.Errorf("fetch into linked inbox: got %+v, want an error", resp)
+	}
+
+	// A link swapped in after it was opened is not followed either.
+	socketDir = t.TempDir()
+	inbox = filepath.Join(socketDir, "inbox")
+	h = NewFetchHandler(FetchHandlerConfig{InboxDir: inbox, ContainerInboxDir: "/run/exitbox/inbox", PromptFunc: approve})
+	moved := filepath.Join(socketDir, "moved")
+	if err := os.Rename(inbox, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(autostart, inbox); err != nil {
+		t.Fatal(err)
+	}
+	if resp := callFetch(t, h, notes); !resp.Approved {
+		t.Fatalf("fetch: got %+v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(moved, "notes.txt")); err != nil {
+		t.Errorf("copy not in the opened inbox: %v", err)
+	}
+
+	if entries, _ := os.ReadDir(autostart); len(entries) != 0 {
+		t.Errorf("files written through the link: %v", entries)
+	}
+}
+
+func TestOpenNoFollow(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "real", "file")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openNoFollow(file)
+	if err != nil {
+		t.Fatalf("openNoFollow: %v", err)
+	}
+	f.Close()
+
+	// A directory on the path is swapped for a link after resolution.
+	if err := os.Rename(filepath.Join(dir, "real"), filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "moved"), filepath.Join(dir, "real")); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := openNoFollow(file); err == nil {
+		f.Close()
+		t.Error("openNoFollow followed a symlinked directory")
+	}
+	if f, err := openNoFollow(filepath.Join(dir, "real")); err == nil {
+		f.Close()
+		t.Error("openNoFollow followed a symlink")
+	}
+}
@@ -0,0 +1,59 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build unix
+
+package ipc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// openNoFollow opens path, which must be absolute and free of symlinks,
+// one component at a time without following any link. A component swapped
+// for a symlink after path was resolved and checked makes it fail, so the
+// file opened is the one at path and nothing a link points to.
+func openNoFollow(path string) (*os.File, error) {
+	dir, err := unix.Open("/", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(strings.TrimPrefix(filepath.Clean(path), "/"), "/")
+	for i, part := range parts {
+		flags := unix.O_RDONLY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+		if i < len(parts)-1 {
+			flags |= unix.O_DIRECTORY
+		} else {
+			// Do not block on a FIFO; it is refused as not regular.
+			flags |= unix.O_NONBLOCK
+		}
+		fd, err := unix.Openat(dir, part, flags, 0)
+		unix.Close(dir)
+		if err == unix.ELOOP || err == unix.ENOTDIR {
+			return nil, fmt.Errorf("%s changed while it was being opened", path)
+		}
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		dir = fd
+	}
+	return os.NewFile(uintptr(dir), path), nil
+}
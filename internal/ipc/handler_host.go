@@ -0,0 +1,354 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/policy"
+	"github.com/cloud-exit/exitbox/internal/redactor"
+)
+
+const (
+	// hostActionPoll is the longest a host_action_output request waits for
+	// new output.
+	hostActionPoll = 5 * time.Second
+	// hostActionIdle is how long a run may go without its output being
+	// read before it is killed, e.g. after exitbox-host was interrupted.
+	hostActionIdle = 30 * time.Second
+	// maxHostActionBuffer bounds unread output; older output is dropped.
+	maxHostActionBuffer = 1 << 20
+	// maxHostActionLine is the longest partial line held back so secrets
+	// are redacted whole.
+	maxHostActionLine = 4096
+)
+
+// HostActionHandlerConfig holds dependencies for the host action handlers.
+type HostActionHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// Actions returns the configured actions, with Dir resolved to an
+	// absolute path. It is called for every request so edits apply
+	// without restarting the session.
+	Actions func() (map[string]config.HostAction, error)
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// Secrets returns vault values (value -> key) that are redacted from
+	// the output; nil redacts nothing.
+	Secrets redactor.SecretProvider
+	// PromptFunc overrides the approval prompt for testing.
+	PromptFunc func(p Prompt) (bool, error)
+}
+
+// HostActionState tracks the host actions running for a session.
+type HostActionState struct {
+	mu   sync.Mutex
+	runs map[string]*actionRun
+}
+
+// Stop kills every running action.
+func (s *HostActionState) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range s.runs {
+		r.cancel()
+		delete(s.runs, id)
+	}
+}
+
+func (s *HostActionState) add(r *actionRun) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runs == nil {
+		s.runs = make(map[string]*actionRun)
+	}
+	s.runs[id] = r
+	return id
+}
+
+func (s *HostActionState) get(id string) *actionRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[id]
+}
+
+func (s *HostActionState) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, id)
+}
+
+// actionRun is one running host action. Its combined output is buffered
+// until exitbox-host reads it.
+type actionRun struct {
+	cancel context.CancelFunc
+	filter func([]byte) []byte
+	wake   chan struct{}
+
+	mu       sync.Mutex
+	buf      []byte
+	dropped  bool
+	done     bool
+	exitCode int
+	lastRead time.Time
+}
+
+func (r *actionRun) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.buf = append(r.buf, p...)
+	if len(r.buf) > maxHostActionBuffer {
+		r.buf = r.buf[len(r.buf)-maxHostActionBuffer:]
+		r.dropped = true
+	}
+	r.mu.Unlock()
+	r.signal()
+	return len(p), nil
+}
+
+func (r *actionRun) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *actionRun) finish(code int) {
+	r.mu.Lock()
+	r.done, r.exitCode = true, code
+	r.mu.Unlock()
+	r.signal()
+}
+
+// take returns the output that is ready: complete lines, a partial line
+// grown past maxHostActionLine, or everything once the command is done.
+// ok is false when there is nothing to report yet.
+func (r *actionRun) take() (resp HostActionOutputResponse, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastRead = time.Now()
+
+	n := len(r.buf)
+	if !r.done && n <= maxHostActionLine {
+		n = bytes.LastIndexByte(r.buf, '\n') + 1
+	}
+	out := r.filter(r.buf[:n])
+	if r.dropped && n > 0 {
+		out = append([]byte("[exitbox: earlier output dropped]\n"), out...)
+		r.dropped = false
+	}
+	r.buf = append([]byte(nil), r.buf[n:]...)
+
+	resp = HostActionOutputResponse{Output: string(out), Done: r.done, ExitCode: r.exitCode}
+	return resp, n > 0 || r.done
+}
+
+func (r *actionRun) idle() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Since(r.lastRead) > hostActionIdle
+}
+
+// NewHostActionHandler returns a HandlerFunc for "host_action" requests.
+// The named action is shown for approval and, when approved, started on
+// the host; its output is read with host_action_output.
+func NewHostActionHandler(cfg HostActionHandlerConfig, state *HostActionState) HandlerFunc {
+	confirm := func(ctx context.Context, p Prompt) (bool, error) {
+		return approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName).Confirm(ctx, p)
+	}
+	if cfg.PromptFunc != nil {
+		confirm = func(_ context.Context, p Prompt) (bool, error) {
+			return cfg.PromptFunc(p)
+		}
+	}
+	filter := func(b []byte) []byte { return b }
+	if cfg.Secrets != nil {
+		filter = redactor.NewWithProvider(cfg.Secrets).Filter
+	}
+
+	return func(req *Request) (interface{}, error) {
+		var payload HostActionRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return HostActionResponse{Error: "invalid payload"}, nil
+		}
+		actions, err := cfg.Actions()
+		if err != nil {
+			return HostActionResponse{Error: fmt.Sprintf("cannot read host actions: %v", err)}, nil
+		}
+		name := payload.Action
+		action, ok := actions[name]
+		if !ok {
+			return HostActionResponse{Error: fmt.Sprintf("unknown host action %q (see exitbox-host -l)", name)}, nil
+		}
+		if len(action.Command) == 0 {
+			return HostActionResponse{Error: fmt.Sprintf("host action %s has no command", name)}, nil
+		}
+
+		approved, d, err := approve(req, cfg.Policy, name, func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, Prompt{
+				Header: "ExitBox",
+				Title:  "Run command on host?",
+				Fields: []Field{
+					{Label: "Action", Value: name},
+					{Label: "Command", Value: strings.Join(action.Command, " ")},
+					{Label: "Dir", Value: action.Dir},
+				},
+			})
+		})
+		if err != nil {
+			recordFailure(req, name, err)
+			return HostActionResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, name, approved, d)
+		if d.Action == policy.Deny {
+			return HostActionResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return HostActionResponse{Approved: false}, nil
+		}
+
+		id, err := startHostAction(state, action, filter, func(code int) {
+			ev := audit.Event{Type: req.Type, Target: name, Outcome: audit.OK, Detail: fmt.Sprintf("exit %d", code)}
+			if code != 0 {
+				ev.Outcome = audit.Failed
+			}
+			req.record(ev)
+		})
+		if err != nil {
+			recordFailure(req, name, err)
+			return HostActionResponse{Error: fmt.Sprintf("failed to start %s: %v", name, err)}, nil
+		}
+		return HostActionResponse{Approved: true, Run: id}, nil
+	}
+}
+
+// startHostAction starts action and registers its run in state. onExit is
+// called with the exit code once it has finished.
+func startHostAction(state *HostActionState, action config.HostAction, filter func([]byte) []byte, onExit func(code int)) (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &actionRun{cancel: cancel, filter: filter, wake: make(chan struct{}, 1), lastRead: time.Now()}
+
+	c := exec.CommandContext(ctx, action.Command[0], action.Command[1:]...)
+	c.Dir = action.Dir
+	c.Stdout = r
+	c.Stderr = r
+	// Children that keep the output open must not keep Wait blocked.
+	c.WaitDelay = 5 * time.Second
+	if err := c.Start(); err != nil {
+		cancel()
+		return "", err
+	}
+	id := state.add(r)
+
+	// Forget the run once its output goes unread, killing it if it is
+	// still going; this also drops finished runs nobody collected.
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if r.idle() {
+				cancel()
+				state.remove(id)
+				return
+			}
+		}
+	}()
+	go func() {
+		err := c.Wait()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		} else if err != nil {
+			code = -1
+		}
+		r.finish(code)
+		cancel()
+		onExit(code)
+	}()
+	return id, nil
+}
+
+// NewHostActionOutputHandler returns a HandlerFunc for "host_action_output"
+// requests. It waits up to hostActionPoll for output and returns it along
+// with the exit code once the action has finished.
+func NewHostActionOutputHandler(state *HostActionState) HandlerFunc {
+	return func(req *Request) (interface{}, error) {
+		var payload HostActionOutputRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return HostActionOutputResponse{Error: "invalid payload"}, nil
+		}
+		r := state.get(payload.Run)
+		if r == nil {
+			return HostActionOutputResponse{Error: "unknown or expired run"}, nil
+		}
+
+		timer := time.NewTimer(hostActionPoll)
+		defer timer.Stop()
+		for {
+			resp, ok := r.take()
+			if ok {
+				if resp.Done {
+					state.remove(payload.Run)
+				}
+				return resp, nil
+			}
+			select {
+			case <-r.wake:
+			case <-timer.C:
+				return resp, nil
+			case <-req.Context().Done():
+				return resp, nil
+			}
+		}
+	}
+}
+
+// NewHostActionListHandler returns a HandlerFunc for "host_action_list"
+// requests, which names the configured actions.
+func NewHostActionListHandler(cfg HostActionHandlerConfig) HandlerFunc {
+	return func(req *Request) (interface{}, error) {
+		actions, err := cfg.Actions()
+		if err != nil {
+			return HostActionListResponse{Error: fmt.Sprintf("cannot read host actions: %v", err)}, nil
+		}
+		list := make([]HostActionInfo, 0, len(actions))
+		for name, a := range actions {
+			list = append(list, HostActionInfo{Name: name, Description: a.Description})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		return HostActionListResponse{Actions: list}, nil
+	}
+}
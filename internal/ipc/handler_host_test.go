@@ -0,0 +1,134 @@
+package ipc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/policy"
+)
+
+func callHost(t *testing.T, h HandlerFunc, msgType string, payload interface{}) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: msgType, Payload: raw})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return out
+}
+
+func testHostActions() (map[string]config.HostAction, error) {
+	return map[string]config.HostAction{
+		"greet": {
+			Command:     []string{"sh", "-c", "echo hello; echo token=s3cr3t-value; exit 3"},
+			Dir:         "/",
+			Description: "Say hello",
+		},
+		"deploy": {Command: []string{"true"}, Dir: "/"},
+	}, nil
+}
+
+func TestHostActionHandler(t *testing.T) {
+	var state HostActionState
+	defer state.Stop()
+
+	var prompts []Prompt
+	cfg := HostActionHandlerConfig{
+		Actions: testHostActions,
+		Secrets: func() map[string]string { return map[string]string{"s3cr3t-value": "API_TOKEN"} },
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompts = append(prompts, p)
+			return true, nil
+		},
+	}
+	h := NewHostActionHandler(cfg, &state)
+	output := NewHostActionOutputHandler(&state)
+
+	var resp HostActionResponse
+	_ = json.Unmarshal(callHost(t, h, "host_action", HostActionRequest{Action: "rm -rf"}), &resp)
+	if resp.Approved || !strings.HasPrefix(resp.Error, "unknown host action") {
+		t.Errorf("unknown action: got %+v", resp)
+	}
+	if len(prompts) != 0 {
+		t.Fatalf("unknown action was prompted: %+v", prompts)
+	}
+
+	_ = json.Unmarshal(callHost(t, h, "host_action", HostActionRequest{Action: "greet"}), &resp)
+	if !resp.Approved || resp.Run == "" {
+		t.Fatalf("greet: got %+v", resp)
+	}
+	if f := prompts[0].Fields; f[0].Value != "greet" || !strings.HasPrefix(f[1].Value, "sh -c") {
+		t.Errorf("prompt fields = %+v", f)
+	}
+
+	var got strings.Builder
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var out HostActionOutputResponse
+		_ = json.Unmarshal(callHost(t, output, "host_action_output", HostActionOutputRequest{Run: resp.Run}), &out)
+		if out.Error != "" {
+			t.Fatalf("output: %s", out.Error)
+		}
+		got.WriteString(out.Output)
+		if out.Done {
+			if out.ExitCode != 3 {
+				t.Errorf("exit code = %d, want 3", out.ExitCode)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run did not finish")
+		}
+	}
+	if s := got.String(); !strings.Contains(s, "hello\n") || strings.Contains(s, "s3cr3t-value") || !strings.Contains(s, "<redacted>") {
+		t.Errorf("output = %q", s)
+	}
+
+	var out HostActionOutputResponse
+	_ = json.Unmarshal(callHost(t, output, "host_action_output", HostActionOutputRequest{Run: resp.Run}), &out)
+	if out.Error != "unknown or expired run" {
+		t.Errorf("collected run: got %+v", out)
+	}
+}
+
+func TestHostActionHandlerPolicy(t *testing.T) {
+	var state HostActionState
+	defer state.Stop()
+
+	h := NewHostActionHandler(HostActionHandlerConfig{
+		Actions: testHostActions,
+		Policy: stubPolicy{
+			"host_action deploy": {Action: policy.Deny, Rule: "no-deploy", Source: "global"},
+		},
+		PromptFunc: func(p Prompt) (bool, error) {
+			t.Error("prompted despite deny rule")
+			return true, nil
+		},
+	}, &state)
+
+	var resp HostActionResponse
+	_ = json.Unmarshal(callHost(t, h, "host_action", HostActionRequest{Action: "deploy"}), &resp)
+	if resp.Approved || resp.Run != "" || resp.Error != `denied by global policy rule "no-deploy"` {
+		t.Errorf("denied action: got %+v", resp)
+	}
+}
+
+func TestHostActionListHandler(t *testing.T) {
+	h := NewHostActionListHandler(HostActionHandlerConfig{Actions: testHostActions})
+
+	var resp HostActionListResponse
+	_ = json.Unmarshal(callHost(t, h, "host_action_list", struct{}{}), &resp)
+	if len(resp.Actions) != 2 || resp.Actions[0].Name != "deploy" || resp.Actions[1] != (HostActionInfo{Name: "greet", Description: "Say hello"}) {
+		t.Errorf("list = %+v", resp.Actions)
+	}
+}
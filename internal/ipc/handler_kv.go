@@ -21,7 +21,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/cloud-exit/exitbox/internal/audit"
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/kvstore"
 )
@@ -33,6 +35,10 @@ type KVHandlerConfig struct {
 	OpenFunc func(opts kvstore.Options) (*kvstore.Store, error)
 }
 
+// kvMu serializes KV requests. Badger takes a directory lock, so two
+// handlers running concurrently cannot both have the store open.
+var kvMu sync.Mutex
+
 // openKVForRequest opens the KV store for a single request.
 // The caller must close the returned store.
 func openKVForRequest(workspace string, openFn func(kvstore.Options) (*kvstore.Store, error)) (*kvstore.Store, error) {
@@ -60,6 +66,8 @@ func NewKVGetHandler(cfg KVHandlerConfig) HandlerFunc {
 			return KVGetResponse{Error: "empty key"}, nil
 		}
 
+		kvMu.Lock()
+		defer kvMu.Unlock()
 		store, err := openKVForRequest(cfg.WorkspaceName, cfg.OpenFunc)
 		if err != nil {
 			return KVGetResponse{Error: err.Error()}, nil
@@ -91,6 +99,8 @@ func NewKVSetHandler(cfg KVHandlerConfig) HandlerFunc {
 			return KVSetResponse{Error: "empty key"}, nil
 		}
 
+		kvMu.Lock()
+		defer kvMu.Unlock()
 		store, err := openKVForRequest(cfg.WorkspaceName, cfg.OpenFunc)
 		if err != nil {
 			return KVSetResponse{Error: err.Error()}, nil
@@ -98,8 +108,10 @@ func NewKVSetHandler(cfg KVHandlerConfig) HandlerFunc {
 		defer store.Close()
 
 		if err := store.Set([]byte(key), []byte(payload.Value)); err != nil {
+			recordFailure(req, key, err)
 			return KVSetResponse{Error: fmt.Sprintf("set: %v", err)}, nil
 		}
+		req.record(audit.Event{Type: req.Type, Target: key, Outcome: audit.OK})
 
 		return KVSetResponse{}, nil
 	}
@@ -118,6 +130,8 @@ func NewKVDeleteHandler(cfg KVHandlerConfig) HandlerFunc {
 			return KVDeleteResponse{Error: "empty key"}, nil
 		}
 
+		kvMu.Lock()
+		defer kvMu.Unlock()
 		store, err := openKVForRequest(cfg.WorkspaceName, cfg.OpenFunc)
 		if err != nil {
 			return KVDeleteResponse{Error: err.Error()}, nil
@@ -125,8 +139,10 @@ func NewKVDeleteHandler(cfg KVHandlerConfig) HandlerFunc {
 		defer store.Close()
 
 		if err := store.Delete([]byte(key)); err != nil {
+			recordFailure(req, key, err)
 			return KVDeleteResponse{Error: fmt.Sprintf("delete: %v", err)}, nil
 		}
+		req.record(audit.Event{Type: req.Type, Target: key, Outcome: audit.OK})
 
 		return KVDeleteResponse{}, nil
 	}
@@ -140,6 +156,8 @@ func NewKVListHandler(cfg KVHandlerConfig) HandlerFunc {
 			return KVListResponse{Error: "invalid payload"}, nil
 		}
 
+		kvMu.Lock()
+		defer kvMu.Unlock()
 		store, err := openKVForRequest(cfg.WorkspaceName, cfg.OpenFunc)
 		if err != nil {
 			return KVListResponse{Error: err.Error()}, nil
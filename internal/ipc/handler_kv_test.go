@@ -320,7 +320,7 @@ func sendKVGet(t *testing.T, srv *Server, key string) KVGetResponse {
 	if err != nil {
 		t.Fatalf("marshal payload: %v", err)
 	}
-	req := Request{Type: "kv_get", ID: "test", Payload: payload}
+	req := Request{Type: "kv_get", ID: "test", Payload: payload, Token: srv.Scope("test", "kv_get")}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -361,7 +361,7 @@ func sendKVSet(t *testing.T, srv *Server, key, value string) KVSetResponse {
 	if err != nil {
 		t.Fatalf("marshal payload: %v", err)
 	}
-	req := Request{Type: "kv_set", ID: "test", Payload: payload}
+	req := Request{Type: "kv_set", ID: "test", Payload: payload, Token: srv.Scope("test", "kv_set")}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -402,7 +402,7 @@ func sendKVDelete(t *testing.T, srv *Server, key string) KVDeleteResponse {
 	if err != nil {
 		t.Fatalf("marshal payload: %v", err)
 	}
-	req := Request{Type: "kv_delete", ID: "test", Payload: payload}
+	req := Request{Type: "kv_delete", ID: "test", Payload: payload, Token: srv.Scope("test", "kv_delete")}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -443,7 +443,7 @@ func sendKVList(t *testing.T, srv *Server, prefix string) KVListResponse {
 	if err != nil {
 		t.Fatalf("marshal payload: %v", err)
 	}
-	req := Request{Type: "kv_list", ID: "test", Payload: payload}
+	req := Request{Type: "kv_list", ID: "test", Payload: payload, Token: srv.Scope("test", "kv_list")}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -0,0 +1,253 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// Notification methods.
+const (
+	NotifyDesktop = "desktop"
+	NotifyBell    = "bell"
+	NotifyHook    = "hook"
+)
+
+// DefaultNotifyInterval is the minimum time between two notifications
+// from one session.
+const DefaultNotifyInterval = 10 * time.Second
+
+// hookTimeout bounds how long a notify hook may run.
+const hookTimeout = 30 * time.Second
+
+const (
+	maxNotifyTitle   = 80
+	maxNotifyMessage = 300
+)
+
+// Notification is what gets shown on the host.
+type Notification struct {
+	Title   string
+	Message string
+	Agent   string
+	Project string // project directory name
+	Session string // container name
+}
+
+// NotifyHandlerConfig holds dependencies for the notify handler.
+type NotifyHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	Agent         string
+	ProjectDir    string
+	// Methods lists how to notify: desktop, bell and/or hook. Empty means
+	// desktop and bell.
+	Methods []string
+	// Hook is the command run by the hook method.
+	Hook []string
+	// Interval overrides DefaultNotifyInterval.
+	Interval time.Duration
+	// NotifyFunc overrides delivery for testing.
+	NotifyFunc func(n Notification) error
+}
+
+// NewNotifyHandler returns a HandlerFunc for "notify" requests. Requests
+// arriving sooner than Interval after the last delivered one are refused.
+func NewNotifyHandler(cfg NotifyHandlerConfig) HandlerFunc {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultNotifyInterval
+	}
+	notifyFn := cfg.NotifyFunc
+	if notifyFn == nil {
+		notifyFn = func(n Notification) error {
+			return deliverNotification(cfg, n)
+		}
+	}
+
+	var mu sync.Mutex
+	var last time.Time
+
+	return func(req *Request) (interface{}, error) {
+		var payload NotifyRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return NotifyResponse{Error: "invalid payload"}, nil
+		}
+		msg := cleanNotifyText(payload.Message, maxNotifyMessage)
+		if msg == "" {
+			return NotifyResponse{Error: "empty message"}, nil
+		}
+
+		mu.Lock()
+		if wait := interval - time.Since(last); !last.IsZero() && wait > 0 {
+			mu.Unlock()
+			return NotifyResponse{Error: fmt.Sprintf("rate limited; try again in %s", wait.Round(time.Second))}, nil
+		}
+		last = time.Now()
+		mu.Unlock()
+
+		n := Notification{
+			Title:   cleanNotifyText(payload.Title, maxNotifyTitle),
+			Message: msg,
+			Agent:   cfg.Agent,
+			Project: filepath.Base(cfg.ProjectDir),
+			Session: cfg.ContainerName,
+		}
+		if n.Title == "" {
+			n.Title = n.Agent + " in " + n.Project
+		}
+		if err := notifyFn(n); err != nil {
+			return NotifyResponse{Error: fmt.Sprintf("notification failed: %v", err)}, nil
+		}
+		return NotifyResponse{Delivered: true}, nil
+	}
+}
+
+// deliverNotification shows n with every configured method. It fails only
+// if no method worked.
+func deliverNotification(cfg NotifyHandlerConfig, n Notification) error {
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = []string{NotifyDesktop, NotifyBell}
+	}
+
+	var errs []string
+	delivered := false
+	for _, m := range methods {
+		var err error
+		switch m {
+		case NotifyDesktop:
+			err = notifyDesktop(n)
+		case NotifyBell:
+			err = notifyBell(cfg.Runtime, cfg.ContainerName, n)
+		case NotifyHook:
+			err = notifyHook(cfg.Hook, n)
+		default:
+			err = fmt.Errorf("unknown method %q", m)
+		}
+		if err != nil {
+			errs = append(errs, m+": "+err.Error())
+			continue
+		}
+		delivered = true
+	}
+	if !delivered {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// notifyDesktop shows a desktop notification that does not wait for the
+// user.
+func notifyDesktop(n Notification) error {
+	title := "ExitBox: " + n.Title
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := lookPath("osascript"); err != nil {
+			return err
+		}
+		script := fmt.Sprintf("display notification %s with title %s subtitle %s",
+			appleScriptString(n.Message), appleScriptString(title), appleScriptString(n.Agent+" · "+n.Project))
+		return exec.Command("osascript", "-e", script).Run()
+	default:
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			return fmt.Errorf("no desktop session")
+		}
+		if _, err := lookPath("notify-send"); err != nil {
+			return err
+		}
+		body := notifyMarkupEscape(n.Message + "\n" + n.Agent + " · " + n.Project + " · " + n.Session)
+		return exec.Command("notify-send", "--app-name=ExitBox", title, body).Run()
+	}
+}
+
+// notifyBell rings the host terminal's bell and flashes the message in the
+// session's tmux status line.
+func notifyBell(rt container.Runtime, containerName string, n Notification) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = tty.Write([]byte("\a"))
+	tty.Close()
+	if err != nil {
+		return err
+	}
+	if rt == nil {
+		return nil
+	}
+	// display-message expands formats; double '#' so the text is literal.
+	text := strings.ReplaceAll(n.Title+": "+n.Message, "#", "##")
+	_ = exec.Command(container.Cmd(rt), "exec", containerName,
+		"tmux", "display-message", "-d", "5000", text).Run()
+	return nil
+}
+
+// notifyHook runs the user's hook command with the notification in its
+// environment.
+func notifyHook(hook []string, n Notification) error {
+	if len(hook) == 0 {
+		return fmt.Errorf("no hook command configured")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, hook[0], hook[1:]...)
+	c.Env = append(os.Environ(),
+		"EXITBOX_NOTIFY_TITLE="+n.Title,
+		"EXITBOX_NOTIFY_MESSAGE="+n.Message,
+		"EXITBOX_AGENT="+n.Agent,
+		"EXITBOX_PROJECT="+n.Project,
+		"EXITBOX_SESSION="+n.Session,
+	)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// cleanNotifyText turns s into a single line of at most max printable
+// characters.
+func cleanNotifyText(s string, max int) string {
+	var b strings.Builder
+	count := 0
+	for _, r := range strings.TrimSpace(s) {
+		if count == max {
+			break
+		}
+		switch {
+		case r == '\n' || r == '\t':
+			r = ' '
+		case !unicode.IsPrint(r):
+			continue
+		}
+		b.WriteRune(r)
+		count++
+	}
+	return strings.TrimSpace(b.String())
+}
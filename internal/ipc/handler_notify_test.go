@@ -0,0 +1,84 @@
+package ipc
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func callNotify(t *testing.T, h HandlerFunc, title, message string) NotifyResponse {
+	t.Helper()
+	payload, err := json.Marshal(NotifyRequest{Title: title, Message: message})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: "notify", Payload: payload})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return resp.(NotifyResponse)
+}
+
+func TestNotifyHandler(t *testing.T) {
+	var got []Notification
+	h := NewNotifyHandler(NotifyHandlerConfig{
+		ContainerName: "exitbox-1000-claude-api_1234abcd-cafe0123",
+		Agent:         "claude",
+		ProjectDir:    "/home/me/api",
+		Interval:      time.Hour,
+		NotifyFunc: func(n Notification) error {
+			got = append(got, n)
+			return nil
+		},
+	})
+
+	if resp := callNotify(t, h, "", "  "); resp.Error != "empty message" {
+		t.Errorf("blank message: got %+v", resp)
+	}
+	if resp := callNotify(t, h, "", "Build\x1b[31m done\n"+strings.Repeat("x", 400)); !resp.Delivered {
+		t.Fatalf("first notification: got %+v", resp)
+	}
+	resp := callNotify(t, h, "", "again")
+	if resp.Delivered || !strings.HasPrefix(resp.Error, "rate limited") {
+		t.Errorf("second notification: got %+v, want rate limited", resp)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("delivered %d notifications, want 1", len(got))
+	}
+	n := got[0]
+	if n.Title != "claude in api" || n.Agent != "claude" || n.Project != "api" || n.Session == "" {
+		t.Errorf("notification = %+v", n)
+	}
+	if strings.ContainsAny(n.Message, "\x1b\n") || !strings.HasPrefix(n.Message, "Build[31m done x") {
+		t.Errorf("message not cleaned: %q", n.Message)
+	}
+	if len([]rune(n.Message)) != maxNotifyMessage {
+		t.Errorf("message length = %d, want %d", len([]rune(n.Message)), maxNotifyMessage)
+	}
+}
+
+func TestDeliverNotificationHook(t *testing.T) {
+	out := t.TempDir() + "/out"
+	err := deliverNotification(NotifyHandlerConfig{
+		Methods: []string{NotifyHook},
+		Hook:    []string{"sh", "-c", `printf '%s|%s|%s' "$EXITBOX_NOTIFY_TITLE" "$EXITBOX_NOTIFY_MESSAGE" "$EXITBOX_AGENT" > "$0"`, out},
+	}, Notification{Title: "Done", Message: "all good", Agent: "codex"})
+	if err != nil {
+		t.Fatalf("deliverNotification: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Done|all good|codex" {
+		t.Errorf("hook saw %q", data)
+	}
+
+	err = deliverNotification(NotifyHandlerConfig{Methods: []string{NotifyHook}}, Notification{Message: "x"})
+	if err == nil || !strings.Contains(err.Error(), "no hook command") {
+		t.Errorf("missing hook: err = %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/policy"
+)
+
+// OpenURLHandlerConfig holds dependencies for the open_url handler.
+type OpenURLHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// Denylist holds entries that are refused without prompting.
+	Denylist []string
+	// Allowed returns the domains the session may reach; a URL whose host
+	// is not among them is refused. nil allows any host.
+	Allowed func() []string
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// RelayFunc relays a host loopback port into the container for an
+	// OAuth redirect; nil disables the callback relay.
+	RelayFunc func(port int) error
+	// PromptFunc overrides the approval prompt for testing.
+	PromptFunc func(p Prompt) (bool, error)
+	// OpenFunc overrides opening the URL on the host for testing.
+	OpenFunc func(rawURL string) error
+}
+
+// NewOpenURLHandler returns a HandlerFunc for "open_url" requests. The
+// URL's host must be allowed for the session; after approval the URL is
+// opened in the host browser, and a loopback OAuth redirect in it is
+// relayed back into the container.
+func NewOpenURLHandler(cfg OpenURLHandlerConfig) HandlerFunc {
+	confirm := func(ctx context.Context, p Prompt) (bool, error) {
+		return approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName).Confirm(ctx, p)
+	}
+	if cfg.PromptFunc != nil {
+		confirm = func(_ context.Context, p Prompt) (bool, error) {
+			return cfg.PromptFunc(p)
+		}
+	}
+	openFn := cfg.OpenFunc
+	if openFn == nil {
+		openFn = hostOpen
+	}
+
+	return func(req *Request) (interface{}, error) {
+		var payload OpenURLRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return OpenURLResponse{Error: "invalid payload"}, nil
+		}
+
+		u, err := url.Parse(strings.TrimSpace(payload.URL))
+		if err != nil {
+			return OpenURLResponse{Error: fmt.Sprintf("invalid URL: %v", err)}, nil
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return OpenURLResponse{Error: "only http and https URLs can be opened"}, nil
+		}
+		host := u.Hostname()
+		if host == "" {
+			return OpenURLResponse{Error: "URL has no host"}, nil
+		}
+		if isLoopback(host) {
+			return OpenURLResponse{Error: "localhost URLs would open the host's services, not the sandbox's"}, nil
+		}
+		if network.IsDenied(host, cfg.Denylist) {
+			req.record(audit.Event{Type: req.Type, Target: host, Outcome: audit.Denied, Detail: "denylist"})
+			return OpenURLResponse{Error: fmt.Sprintf("domain %s is on the denylist", host)}, nil
+		}
+		if cfg.Allowed != nil && !network.IsAllowed(host, cfg.Allowed()) {
+			return OpenURLResponse{Error: fmt.Sprintf("domain %s is not on the allowlist; request it with exitbox-allow first", host)}, nil
+		}
+
+		callback := 0
+		if cfg.RelayFunc != nil {
+			callback = callbackPort(u)
+		}
+
+		fields := []Field{{Label: "Host", Value: host}}
+		if callback != 0 {
+			fields = append(fields, Field{Label: "Callback", Value: fmt.Sprintf("localhost:%d to sandbox", callback)})
+		}
+		approved, d, err := approve(req, cfg.Policy, host, func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, Prompt{Header: "ExitBox", Title: "Open URL in host browser?", Fields: fields})
+		})
+		if err != nil {
+			recordFailure(req, host, err)
+			return OpenURLResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, host, approved, d)
+		if d.Action == policy.Deny {
+			return OpenURLResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return OpenURLResponse{Approved: false}, nil
+		}
+
+		if callback != 0 {
+			if err := cfg.RelayFunc(callback); err != nil {
+				return OpenURLResponse{Error: fmt.Sprintf("callback relay on port %d failed: %v", callback, err)}, nil
+			}
+		}
+		if err := openFn(u.String()); err != nil {
+			return OpenURLResponse{Error: fmt.Sprintf("failed to open browser: %v", err)}, nil
+		}
+		return OpenURLResponse{Approved: true, CallbackPort: callback}, nil
+	}
+}
+
+// callbackPort returns the port of a loopback OAuth redirect_uri in u, or 0
+// if there is none.
+func callbackPort(u *url.URL) int {
+	q := u.Query()
+	for _, name := range []string{"redirect_uri", "redirect_url"} {
+		r, err := url.Parse(q.Get(name))
+		if err != nil || r.Scheme != "http" || !isLoopback(r.Hostname()) {
+			continue
+		}
+		if port, err := strconv.Atoi(r.Port()); err == nil && port > 0 && port < 65536 {
+			return port
+		}
+	}
+	return 0
+}
+
+// isLoopback reports whether host names the local machine.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hostOpen opens rawURL in the host's default browser.
+func hostOpen(rawURL string) error {
+	name := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		name = "open"
+	}
+	if _, err := lookPath(name); err != nil {
+		return fmt.Errorf("%s not found", name)
+	}
+	// Started, not waited for: xdg-open may block until the browser exits.
+	c := exec.Command(name, rawURL)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	go func() { _ = c.Wait() }()
+	return nil
+}
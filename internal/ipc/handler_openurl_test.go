@@ -0,0 +1,96 @@
+package ipc
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func callOpenURL(t *testing.T, h HandlerFunc, rawURL string) OpenURLResponse {
+	t.Helper()
+	payload, err := json.Marshal(OpenURLRequest{URL: rawURL})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: "open_url", Payload: payload})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return resp.(OpenURLResponse)
+}
+
+func TestOpenURLHandler(t *testing.T) {
+	var prompts []Prompt
+	var opened []string
+	var relayed []int
+	h := NewOpenURLHandler(OpenURLHandlerConfig{
+		Denylist: []string{"gist.github.com"},
+		Allowed:  func() []string { return []string{"github.com", "docs.python.org"} },
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompts = append(prompts, p)
+			return true, nil
+		},
+		OpenFunc: func(rawURL string) error {
+			opened = append(opened, rawURL)
+			return nil
+		},
+		RelayFunc: func(port int) error {
+			relayed = append(relayed, port)
+			return nil
+		},
+	})
+
+	refused := map[string]string{
+		"file:///etc/passwd":                   "only http and https",
+		"http://localhost:3000/":               "localhost URLs",
+		"http://127.0.0.1:8080/":               "localhost URLs",
+		"https://gist.github.com/x":            "is on the denylist",
+		"https://evil.example.com/?d=exfil":    "is not on the allowlist",
+		"https://github.com.evil.example.com/": "is not on the allowlist",
+	}
+	for rawURL, want := range refused {
+		if resp := callOpenURL(t, h, rawURL); resp.Approved || !strings.Contains(resp.Error, want) {
+			t.Errorf("%s: got %+v, want error containing %q", rawURL, resp, want)
+		}
+	}
+	if len(prompts) != 0 || len(opened) != 0 {
+		t.Fatalf("refused URLs were prompted %d times and opened %v", len(prompts), opened)
+	}
+
+	if resp := callOpenURL(t, h, "https://docs.python.org/3/"); !resp.Approved || resp.CallbackPort != 0 {
+		t.Errorf("docs link: got %+v", resp)
+	}
+	login := "https://github.com/login/oauth/authorize?client_id=abc&redirect_uri=http%3A%2F%2Flocalhost%3A1455%2Fcallback"
+	if resp := callOpenURL(t, h, login); !resp.Approved || resp.CallbackPort != 1455 {
+		t.Errorf("oauth login: got %+v", resp)
+	}
+	if len(opened) != 2 || opened[1] != login {
+		t.Errorf("opened %v", opened)
+	}
+	if len(relayed) != 1 || relayed[0] != 1455 {
+		t.Errorf("relayed %v, want [1455]", relayed)
+	}
+	if f := prompts[1].Fields; len(f) != 2 || f[0].Value != "github.com" || f[1].Label != "Callback" {
+		t.Errorf("oauth prompt fields = %+v", f)
+	}
+}
+
+func TestCallbackPort(t *testing.T) {
+	tests := map[string]int{
+		"https://a.example.com/auth?redirect_uri=http://127.0.0.1:8085/cb":   8085,
+		"https://a.example.com/auth?redirect_url=http://localhost:9000":      9000,
+		"https://a.example.com/auth?redirect_uri=https://app.example.com/cb": 0,
+		"https://a.example.com/auth?redirect_uri=http://localhost/cb":        0,
+		"https://a.example.com/docs":                                         0,
+	}
+	for rawURL, want := range tests {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := callbackPort(u); got != want {
+			t.Errorf("callbackPort(%s) = %d, want %d", rawURL, got, want)
+		}
+	}
+}
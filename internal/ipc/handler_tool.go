@@ -0,0 +1,158 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/apk"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/policy"
+	"github.com/cloud-exit/exitbox/internal/profile"
+)
+
+// apkNamePattern matches valid Alpine package names.
+var apkNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9+._-]{0,99}$`)
+
+// ToolHandlerConfig holds dependencies for the tool_request handler.
+type ToolHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// WorkspaceName is the workspace whose packages approved tools are
+	// added to.
+	WorkspaceName string
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// OnQueued is called after a package has been added, e.g. to show that
+	// a rebuild is pending.
+	OnQueued func()
+	// PromptFunc overrides the approval prompt for testing.
+	PromptFunc func(p Prompt) (bool, error)
+	// LookupFunc overrides the Alpine index lookup for testing.
+	LookupFunc func(name string) (apk.Package, bool, error)
+	// AddFunc overrides adding the package to the workspace for testing.
+	// It reports whether the package was new.
+	AddFunc func(workspace, pkg string) (bool, error)
+}
+
+// NewToolHandler returns a HandlerFunc for "tool_request" requests. A
+// package found in the Alpine index is, once approved, appended to the
+// workspace's packages and installed when the image is next rebuilt.
+func NewToolHandler(cfg ToolHandlerConfig) HandlerFunc {
+	confirm := func(ctx context.Context, p Prompt) (bool, error) {
+		return approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName).Confirm(ctx, p)
+	}
+	if cfg.PromptFunc != nil {
+		confirm = func(_ context.Context, p Prompt) (bool, error) {
+			return cfg.PromptFunc(p)
+		}
+	}
+	lookup := cfg.LookupFunc
+	if lookup == nil {
+		lookup = lookupPackage
+	}
+	add := cfg.AddFunc
+	if add == nil {
+		add = addWorkspacePackage
+	}
+
+	return func(req *Request) (interface{}, error) {
+		var payload ToolRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return ToolResponse{Error: "invalid payload"}, nil
+		}
+		name := strings.TrimSpace(payload.Package)
+		if !apkNamePattern.MatchString(name) {
+			return ToolResponse{Error: fmt.Sprintf("invalid package name %q", name)}, nil
+		}
+		pkg, ok, err := lookup(name)
+		if err != nil {
+			return ToolResponse{Error: fmt.Sprintf("cannot read the Alpine package index: %v", err)}, nil
+		}
+		if !ok {
+			return ToolResponse{Error: fmt.Sprintf("package %s is not in the Alpine index (search with 'apk search' or https://pkgs.alpinelinux.org)", name)}, nil
+		}
+
+		approved, d, err := approve(req, cfg.Policy, name, func(ctx context.Context, _ string) (bool, error) {
+			return confirm(ctx, Prompt{
+				Header: "ExitBox",
+				Title:  "Add package to workspace image?",
+				Fields: []Field{
+					{Label: "Package", Value: pkg.Name},
+					{Label: "About", Value: pkg.Description},
+					{Label: "Workspace", Value: cfg.WorkspaceName},
+				},
+			})
+		})
+		if err != nil {
+			recordFailure(req, name, err)
+			return ToolResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+		}
+		recordDecision(req, name, approved, d)
+		if d.Action == policy.Deny {
+			return ToolResponse{Error: deniedByPolicy(d)}, nil
+		}
+		if !approved {
+			return ToolResponse{Approved: false}, nil
+		}
+
+		queued, err := add(cfg.WorkspaceName, name)
+		if err != nil {
+			return ToolResponse{Error: fmt.Sprintf("failed to update workspace: %v", err)}, nil
+		}
+		if queued && cfg.OnQueued != nil {
+			cfg.OnQueued()
+		}
+		return ToolResponse{Approved: true, Workspace: cfg.WorkspaceName, Queued: queued}, nil
+	}
+}
+
+// lookupPackage finds name in the cached Alpine index.
+func lookupPackage(name string) (apk.Package, bool, error) {
+	index, err := apk.LoadIndex()
+	if err != nil {
+		return apk.Package{}, false, err
+	}
+	pkg, ok := apk.Find(index, name)
+	return pkg, ok, nil
+}
+
+// addWorkspacePackage appends pkg to the workspace's packages in
+// config.yaml, unless it is already there.
+func addWorkspacePackage(workspace, pkg string) (bool, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false, err
+	}
+	w := profile.FindWorkspace(cfg, workspace)
+	if w == nil {
+		return false, fmt.Errorf("unknown workspace: %s", workspace)
+	}
+	if slices.Contains(w.Packages, pkg) {
+		return false, nil
+	}
+	w.Packages = append(w.Packages, pkg)
+	return true, config.SaveConfig(cfg)
+}
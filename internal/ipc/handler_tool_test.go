@@ -0,0 +1,91 @@
+package ipc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/apk"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/profile"
+)
+
+func callTool(t *testing.T, h HandlerFunc, pkg string) ToolResponse {
+	t.Helper()
+	payload, err := json.Marshal(ToolRequest{Package: pkg})
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	resp, err := h(&Request{Type: "tool_request", Payload: payload})
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return resp.(ToolResponse)
+}
+
+func TestToolHandler(t *testing.T) {
+	origHome := config.Home
+	config.Home = t.TempDir()
+	defer func() { config.Home = origHome }()
+	cfg := config.DefaultConfig()
+	cfg.Workspaces.Items = []config.Workspace{{Name: "work", Packages: []string{"jq"}}}
+	if err := os.MkdirAll(filepath.Dir(config.ConfigFile()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	index := []apk.Package{
+		{Name: "jq", Description: "A lightweight JSON processor"},
+		{Name: "protobuf", Description: "Library for extensible, efficient structure packing"},
+	}
+	var prompts []Prompt
+	queued := 0
+	h := NewToolHandler(ToolHandlerConfig{
+		WorkspaceName: "work",
+		OnQueued:      func() { queued++ },
+		PromptFunc: func(p Prompt) (bool, error) {
+			prompts = append(prompts, p)
+			return true, nil
+		},
+		LookupFunc: func(name string) (apk.Package, bool, error) {
+			p, ok := apk.Find(index, name)
+			return p, ok, nil
+		},
+	})
+
+	if resp := callTool(t, h, "jq; rm -rf /"); !strings.HasPrefix(resp.Error, "invalid package name") {
+		t.Errorf("bad name: got %+v", resp)
+	}
+	if resp := callTool(t, h, "graphviz"); !strings.Contains(resp.Error, "not in the Alpine index") {
+		t.Errorf("unknown package: got %+v", resp)
+	}
+	if len(prompts) != 0 {
+		t.Fatalf("invalid requests were prompted: %+v", prompts)
+	}
+
+	resp := callTool(t, h, "protobuf")
+	if !resp.Approved || !resp.Queued || resp.Workspace != "work" {
+		t.Fatalf("new package: got %+v", resp)
+	}
+	if f := prompts[0].Fields; f[0].Value != "protobuf" || f[2].Value != "work" {
+		t.Errorf("prompt fields = %+v", f)
+	}
+	if resp := callTool(t, h, "jq"); !resp.Approved || resp.Queued {
+		t.Errorf("existing package: got %+v", resp)
+	}
+	if queued != 1 {
+		t.Errorf("OnQueued called %d times, want 1", queued)
+	}
+
+	saved, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := profile.FindWorkspace(saved, "work"); w == nil || strings.Join(w.Packages, ",") != "jq,protobuf" {
+		t.Errorf("workspace packages = %+v", w)
+	}
+}
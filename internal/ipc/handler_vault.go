@@ -17,15 +17,14 @@
 package ipc
 
 import (
-	"bytes"
-	cryptoRand "crypto/rand"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 	"sync"
 
 	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/policy"
 	"github.com/cloud-exit/exitbox/internal/vault"
 )
 
@@ -34,11 +33,15 @@ type VaultHandlerConfig struct {
 	Runtime       container.Runtime
 	ContainerName string
 	WorkspaceName string
-	// PromptPasswordFunc overrides the tmux popup password prompt for testing.
+	// Approver asks the user; nil means a tmux popup in the container.
+	Approver Approver
+	// Policy decides requests before prompting; nil always prompts.
+	Policy Policy
+	// PromptPasswordFunc overrides the password prompt for testing.
 	PromptPasswordFunc func() (string, error)
-	// PromptApproveFunc overrides the tmux popup approval prompt for testing.
+	// PromptApproveFunc overrides the approval prompt for testing.
 	PromptApproveFunc func(key string) (bool, error)
-	// PromptApproveSetFunc overrides the tmux popup approval prompt for vault set.
+	// PromptApproveSetFunc overrides the approval prompt for vault set.
 	PromptApproveSetFunc func(key string) (bool, error)
 	// OpenFunc overrides vault.Open for testing.
 	OpenFunc func(workspace, password string) (map[string]string, error)
@@ -88,19 +91,39 @@ func (vs *VaultState) GetRetrievedSecrets() map[string]string {
 	return cp
 }
 
+// KnownSecrets returns the retrieved secrets plus, once the vault has been
+// unlocked, every value in it. It is meant for data crossing into or out of
+// the container outside of vault requests, such as the clipboard.
+func (vs *VaultState) KnownSecrets() map[string]string {
+	secrets := vs.GetRetrievedSecrets()
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	for k, v := range vs.store {
+		secrets[v] = k
+	}
+	return secrets
+}
+
 // NewVaultGetHandler returns a HandlerFunc for "vault_get" requests.
 func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
-	promptApprove := cfg.PromptApproveFunc
-	if promptApprove == nil {
-		promptApprove = func(key string) (bool, error) {
-			return promptVaultApproval(cfg.Runtime, cfg.ContainerName, key)
+	promptApprove := func(ctx context.Context, key string) (bool, error) {
+		return vaultApprover(cfg).Confirm(ctx, vaultPrompt("Allow secret read?", key))
+	}
+	if cfg.PromptApproveFunc != nil {
+		promptApprove = func(_ context.Context, key string) (bool, error) {
+			return cfg.PromptApproveFunc(key)
 		}
 	}
 
-	promptPassword := cfg.PromptPasswordFunc
-	if promptPassword == nil {
-		promptPassword = func() (string, error) {
-			return promptVaultPassword(cfg.Runtime, cfg.ContainerName)
+	promptPassword := func(ctx context.Context) (string, error) {
+		return vaultApprover(cfg).Password(ctx, Prompt{Header: "ExitBox Vault", Title: "Enter vault password:"})
+	}
+	if cfg.PromptPasswordFunc != nil {
+		promptPassword = func(context.Context) (string, error) {
+			return cfg.PromptPasswordFunc()
 		}
 	}
 
@@ -120,18 +143,24 @@ func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 			return VaultGetResponse{Error: "empty key"}, nil
 		}
 
-		// Prompt user for approval.
-		approved, err := promptApprove(key)
+		// Check the policy, then prompt user for approval.
+		approved, d, err := approve(req, cfg.Policy, key, promptApprove)
 		if err != nil {
+			recordFailure(req, key, err)
 			return VaultGetResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
 		}
+		recordDecision(req, key, approved, d)
+		if d.Action == policy.Deny {
+			return VaultGetResponse{Error: deniedByPolicy(d)}, nil
+		}
 		if !approved {
 			return VaultGetResponse{Approved: false}, nil
 		}
 
 		// Ensure vault is unlocked.
-		store, err := ensureUnlocked(state, cfg.WorkspaceName, promptPassword, openFn)
+		store, err := ensureUnlocked(req, state, cfg.WorkspaceName, promptPassword, openFn)
 		if err != nil {
+			recordFailure(req, key, err)
 			return VaultGetResponse{Error: fmt.Sprintf("vault unlock failed: %v", err)}, nil
 		}
 
@@ -154,17 +183,21 @@ func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 
 // NewVaultListHandler returns a HandlerFunc for "vault_list" requests.
 func NewVaultListHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
-	promptApprove := cfg.PromptApproveFunc
-	if promptApprove == nil {
-		promptApprove = func(_ string) (bool, error) {
-			return promptVaultApproval(cfg.Runtime, cfg.ContainerName, "list keys")
+	promptApprove := func(ctx context.Context, _ string) (bool, error) {
+		return vaultApprover(cfg).Confirm(ctx, vaultPrompt("Allow secret read?", "list keys"))
+	}
+	if cfg.PromptApproveFunc != nil {
+		promptApprove = func(_ context.Context, key string) (bool, error) {
+			return cfg.PromptApproveFunc(key)
 		}
 	}
 
-	promptPassword := cfg.PromptPasswordFunc
-	if promptPassword == nil {
-		promptPassword = func() (string, error) {
-			return promptVaultPassword(cfg.Runtime, cfg.ContainerName)
+	promptPassword := func(ctx context.Context) (string, error) {
+		return vaultApprover(cfg).Password(ctx, Prompt{Header: "ExitBox Vault", Title: "Enter vault password:"})
+	}
+	if cfg.PromptPasswordFunc != nil {
+		promptPassword = func(context.Context) (string, error) {
+			return cfg.PromptPasswordFunc()
 		}
 	}
 
@@ -174,18 +207,24 @@ func NewVaultListHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc
 	}
 
 	return func(req *Request) (interface{}, error) {
-		// Prompt user for approval.
-		approved, err := promptApprove("list keys")
+		// Check the policy, then prompt user for approval.
+		approved, d, err := approve(req, cfg.Policy, "", promptApprove)
 		if err != nil {
+			recordFailure(req, "", err)
 			return VaultListResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
 		}
+		recordDecision(req, "", approved, d)
+		if d.Action == policy.Deny {
+			return VaultListResponse{Error: deniedByPolicy(d)}, nil
+		}
 		if !approved {
 			return VaultListResponse{Approved: false}, nil
 		}
 
 		// Ensure vault is unlocked.
-		store, err := ensureUnlocked(state, cfg.WorkspaceName, promptPassword, openFn)
+		store, err := ensureUnlocked(req, state, cfg.WorkspaceName, promptPassword, openFn)
 		if err != nil {
+			recordFailure(req, "", err)
 			return VaultListResponse{Error: fmt.Sprintf("vault unlock failed: %v", err)}, nil
 		}
 
@@ -224,17 +263,21 @@ func quickSetVault(workspace, password, key, value string) error {
 
 // NewVaultSetHandler returns a HandlerFunc for "vault_set" requests.
 func NewVaultSetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
-	promptApprove := cfg.PromptApproveSetFunc
-	if promptApprove == nil {
-		promptApprove = func(key string) (bool, error) {
-			return promptVaultApprovalSet(cfg.Runtime, cfg.ContainerName, key)
+	promptApprove := func(ctx context.Context, key string) (bool, error) {
+		return vaultApprover(cfg).Confirm(ctx, vaultPrompt("Allow secret write?", key))
+	}
+	if cfg.PromptApproveSetFunc != nil {
+		promptApprove = func(_ context.Context, key string) (bool, error) {
+			return cfg.PromptApproveSetFunc(key)
 		}
 	}
 
-	promptPassword := cfg.PromptPasswordFunc
-	if promptPassword == nil {
-		promptPassword = func() (string, error) {
-			return promptVaultPassword(cfg.Runtime, cfg.ContainerName)
+	promptPassword := func(ctx context.Context) (string, error) {
+		return vaultApprover(cfg).Password(ctx, Prompt{Header: "ExitBox Vault", Title: "Enter vault password:"})
+	}
+	if cfg.PromptPasswordFunc != nil {
+		promptPassword = func(context.Context) (string, error) {
+			return cfg.PromptPasswordFunc()
 		}
 	}
 
@@ -264,18 +307,24 @@ func NewVaultSetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 			return VaultSetResponse{Error: "empty value"}, nil
 		}
 
-		// Prompt user for approval.
-		approved, err := promptApprove(key)
+		// Check the policy, then prompt user for approval.
+		approved, d, err := approve(req, cfg.Policy, key, promptApprove)
 		if err != nil {
+			recordFailure(req, key, err)
 			return VaultSetResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
 		}
+		recordDecision(req, key, approved, d)
+		if d.Action == policy.Deny {
+			return VaultSetResponse{Error: deniedByPolicy(d)}, nil
+		}
 		if !approved {
 			return VaultSetResponse{Approved: false}, nil
 		}
 
 		// Ensure vault is unlocked.
-		_, err = ensureUnlocked(state, cfg.WorkspaceName, promptPassword, openFn)
+		_, err = ensureUnlocked(req, state, cfg.WorkspaceName, promptPassword, openFn)
 		if err != nil {
+			recordFailure(req, key, err)
 			return VaultSetResponse{Error: fmt.Sprintf("vault unlock failed: %v", err)}, nil
 		}
 
@@ -285,6 +334,7 @@ func NewVaultSetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 		state.mu.Unlock()
 
 		if err := setFn(cfg.WorkspaceName, password, key, value); err != nil {
+			recordFailure(req, key, err)
 			return VaultSetResponse{Error: fmt.Sprintf("vault write failed: %v", err)}, nil
 		}
 
@@ -299,11 +349,23 @@ func NewVaultSetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 
 // ensureUnlocked returns the decrypted store, prompting for password if needed.
 func ensureUnlocked(
+	req *Request,
 	state *VaultState,
 	workspace string,
-	promptPassword func() (string, error),
+	promptPassword func(context.Context) (string, error),
 	openFn func(string, string) (map[string]string, error),
 ) (map[string]string, error) {
+	state.mu.Lock()
+	unlocked := state.store != nil
+	state.mu.Unlock()
+	// The prompt turn is taken before state.mu, as a request already
+	// holding the turn takes state.mu after it.
+	if !unlocked {
+		if err := req.waitTurn(); err != nil {
+			return nil, fmt.Errorf("password prompt failed: %v", err)
+		}
+	}
+
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
@@ -311,7 +373,7 @@ func ensureUnlocked(
 		return state.store, nil
 	}
 
-	password, err := promptPassword()
+	password, err := promptPassword(req.Context())
 	if err != nil {
 		return nil, fmt.Errorf("password prompt failed: %v", err)
 	}
@@ -336,128 +398,16 @@ func openVaultAsMap(workspace, password string) (map[string]string, error) {
 	return s.All()
 }
 
-// promptVaultPassword shows a tmux popup that captures a password.
-// Since tmux display-popup runs the script inside the popup's terminal
-// (stdout goes to the popup, not back through docker exec), we use a
-// temp file inside the container to pass the password back to the host.
-func promptVaultPassword(rt container.Runtime, containerName string) (string, error) {
-	cmd := container.Cmd(rt)
-
-	// Generate a random temp file path inside the container.
-	randomBytes := make([]byte, 8)
-	if _, err := cryptoRand.Read(randomBytes); err != nil {
-		return "", fmt.Errorf("generating random path: %w", err)
-	}
-	tmpFile := fmt.Sprintf("/tmp/.exitbox-vault-pw-%x", randomBytes)
-
-	// Popup script: prompt for password, write to temp file.
-	// The script exits 0 only if a non-empty password was entered.
-	script := `printf '\n  \033[1;33m[ExitBox Vault]\033[0m Enter vault password:\n\n  Password: '; ` +
-		`stty -echo 2>/dev/null; read pw; stty echo 2>/dev/null; printf '\n'; ` +
-		`echo "$pw" > ` + tmpFile + `; [ -n "$pw" ]`
-
-	// Run the popup (blocks until user submits or dismisses).
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "55", "-h", "7",
-		"sh", "-c", script,
-	)
-	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	popupErr := c.Run()
-
-	// Read the password from the temp file.
-	readCmd := exec.Command(cmd, "exec", containerName, "cat", tmpFile)
-	var stdout bytes.Buffer
-	readCmd.Stdout = &stdout
-	readErr := readCmd.Run()
-
-	// Clean up the temp file regardless of outcome.
-	cleanupCmd := exec.Command(cmd, "exec", containerName, "rm", "-f", tmpFile)
-	_ = cleanupCmd.Run()
-
-	if popupErr != nil {
-		if exitErr, ok := popupErr.(*exec.ExitError); ok {
-			if stderr.Len() == 0 {
-				return "", fmt.Errorf("password entry cancelled")
-			}
-			return "", fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
-		}
-		return "", fmt.Errorf("popup exec failed: %w", popupErr)
-	}
-
-	if readErr != nil {
-		return "", fmt.Errorf("failed to read password: %w", readErr)
-	}
-
-	password := strings.TrimRight(stdout.String(), "\n\r")
-	if password == "" {
-		return "", fmt.Errorf("empty password")
-	}
-	return password, nil
-}
-
-// promptVaultApproval shows a tmux popup for approving secret access.
-func promptVaultApproval(rt container.Runtime, containerName, key string) (bool, error) {
-	cmd := container.Cmd(rt)
-
-	safeKey := sanitizeForShell(key)
-
-	script := `printf '\n  \033[1;33m[ExitBox Vault]\033[0m Allow secret read?\n\n  Key: \033[1m` +
-		safeKey +
-		`\033[0m\n\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
-
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "55", "-h", "9",
-		"sh", "-c", script,
-	)
-
-	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	err := c.Run()
-
-	if err == nil {
-		return true, nil
-	}
-
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if stderr.Len() == 0 {
-			return false, nil
-		}
-		return false, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
-	}
-
-	return false, fmt.Errorf("popup exec failed: %w", err)
+// vaultApprover returns the approver configured for vault prompts.
+func vaultApprover(cfg VaultHandlerConfig) Approver {
+	return approverOrTmux(cfg.Approver, cfg.Runtime, cfg.ContainerName)
 }
 
-// promptVaultApprovalSet shows a tmux popup for approving secret write.
-func promptVaultApprovalSet(rt container.Runtime, containerName, key string) (bool, error) {
-	cmd := container.Cmd(rt)
-
-	safeKey := sanitizeForShell(key)
-
-	script := `printf '\n  \033[1;33m[ExitBox Vault]\033[0m Allow secret write?\n\n  Key: \033[1m` +
-		safeKey +
-		`\033[0m\n\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
-
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "55", "-h", "9",
-		"sh", "-c", script,
-	)
-
-	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	err := c.Run()
-
-	if err == nil {
-		return true, nil
+// vaultPrompt asks about access to a vault key.
+func vaultPrompt(title, key string) Prompt {
+	return Prompt{
+		Header: "ExitBox Vault",
+		Title:  title,
+		Fields: []Field{{Label: "Key", Value: key}},
 	}
-
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if stderr.Len() == 0 {
-			return false, nil
-		}
-		return false, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
-	}
-
-	return false, fmt.Errorf("popup exec failed: %w", err)
 }
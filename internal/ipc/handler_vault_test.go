@@ -283,6 +283,7 @@ func sendVaultGet(t *testing.T, srv *Server, key string) VaultGetResponse {
 		Type:    "vault_get",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.Scope("test", "vault_get"),
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -330,6 +331,7 @@ func sendVaultList(t *testing.T, srv *Server) VaultListResponse {
 		Type:    "vault_list",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.Scope("test", "vault_list"),
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -526,6 +528,7 @@ func sendVaultSet(t *testing.T, srv *Server, key, value string) VaultSetResponse
 		Type:    "vault_set",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.Scope("test", "vault_set"),
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -0,0 +1,96 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/policy"
+)
+
+// Policy decides requests before the user is prompted. *policy.Engine
+// implements it.
+type Policy interface {
+	Decide(msgType, target string) (policy.Decision, error)
+}
+
+// decide applies p to a request for target. A nil Policy, or one that
+// fails to load, prompts. Decisions made by a rule are logged.
+func decide(req *Request, p Policy, target string) policy.Decision {
+	if p == nil {
+		return policy.Decision{Action: policy.Prompt}
+	}
+	d, err := p.Decide(req.Type, target)
+	if err != nil {
+		req.logf("policy: %v; prompting instead", err)
+		return policy.Decision{Action: policy.Prompt}
+	}
+	if d.Rule != "" {
+		req.logf("%s %q: %s", req.Type, target, d)
+	}
+	return d
+}
+
+// decidedBy says who decided a request: the user or a policy rule.
+func decidedBy(d policy.Decision) string {
+	if d.Action == policy.Prompt {
+		return "user"
+	}
+	return fmt.Sprintf("%s policy rule %q", d.Source, d.Rule)
+}
+
+// deniedByPolicy is the error returned to the client for a denied request.
+func deniedByPolicy(d policy.Decision) string {
+	return "denied by " + decidedBy(d)
+}
+
+// approve decides a request for target by policy, prompting when no rule
+// allows or denies it. d.Action is Prompt when the user answered.
+func approve(
+	req *Request,
+	p Policy,
+	target string,
+	prompt func(context.Context, string) (bool, error),
+) (approved bool, d policy.Decision, err error) {
+	switch d = decide(req, p, target); d.Action {
+	case policy.Allow:
+		return true, d, nil
+	case policy.Deny:
+		return false, d, nil
+	}
+	if err := req.waitTurn(); err != nil {
+		return false, d, err
+	}
+	approved, err = prompt(req.Context(), target)
+	return approved, d, err
+}
+
+// recordDecision adds the outcome of an approval to the audit log.
+func recordDecision(req *Request, target string, approved bool, d policy.Decision) {
+	outcome := audit.Denied
+	if approved {
+		outcome = audit.Approved
+	}
+	req.record(audit.Event{Type: req.Type, Target: target, Outcome: outcome, Detail: decidedBy(d)})
+}
+
+// recordFailure adds a request that failed to the audit log.
+func recordFailure(req *Request, target string, err error) {
+	req.record(audit.Event{Type: req.Type, Target: target, Outcome: audit.Failed, Detail: err.Error()})
+}
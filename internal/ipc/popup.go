@@ -0,0 +1,99 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// errPopupDismissed means the popup ran and the user declined or closed it.
+var errPopupDismissed = errors.New("popup dismissed")
+
+// popupCloseGrace is how long runPopup waits for the exec to exit after
+// closing the popup before killing it.
+const popupCloseGrace = 5 * time.Second
+
+// runPopup shows script in a tmux display-popup inside the agent container.
+// The host execs into the container's tmux to present an interactive popup
+// overlaying the agent session. This avoids competing with tmux for /dev/tty.
+//
+// tmux display-popup -E returns the script's exit code: nil means exit 0,
+// errPopupDismissed a non-zero exit with nothing on stderr. If ctx ends
+// first the popup is closed and ctx.Err() is returned.
+func runPopup(ctx context.Context, rt container.Runtime, containerName string, width, height int, script string) error {
+	cmd := container.Cmd(rt)
+	c := exec.Command(cmd, "exec", containerName,
+		"tmux", "display-popup", "-E", "-w", strconv.Itoa(width), "-h", strconv.Itoa(height),
+		"sh", "-c", script,
+	)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("popup exec failed: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Killing the local exec would leave the popup on screen, so close
+		// it inside the container and let the exec return on its own.
+		_ = exec.Command(cmd, "exec", containerName, "tmux", "display-popup", "-C").Run()
+		select {
+		case <-done:
+		case <-time.After(popupCloseGrace):
+			_ = c.Process.Kill()
+			<-done
+		}
+		return ctx.Err()
+	}
+
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if stderr.Len() == 0 {
+			return errPopupDismissed
+		}
+		return fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
+	}
+	return fmt.Errorf("popup exec failed: %w", err)
+}
+
+// confirmPopup runs a y/N popup script and reports whether the user approved.
+func confirmPopup(ctx context.Context, rt container.Runtime, containerName string, width, height int, script string) (bool, error) {
+	err := runPopup(ctx, rt, containerName, width, height, script)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, errPopupDismissed) {
+		return false, nil
+	}
+	return false, err
+}
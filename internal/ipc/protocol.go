@@ -16,13 +16,73 @@
 
 package ipc
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+)
+
+// ProtocolVersion is the IPC protocol version the server speaks. Version 1
+// clients send one request per connection without a hello; version 2
+// clients open with hello and may send further requests on the same
+// connection. Both are accepted.
+const ProtocolVersion = 2
 
 // Request is a message sent from the container to the host.
 type Request struct {
 	Type    string          `json:"type"`
 	ID      string          `json:"id"`
 	Payload json.RawMessage `json:"payload"`
+	// Token authenticates the sender; see Server.Scope.
+	Token string `json:"token,omitempty"`
+
+	ctx     context.Context
+	scope   *scope
+	logger  *log.Logger
+	audit   *audit.Log
+	prompts *promptQueue // nil unless the handler is interactive
+	release func()       // hands over the prompt turn, once taken
+}
+
+// Context returns the request's context. It is cancelled when the client
+// disconnects, the server stops, or an interactive request times out.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// waitTurn blocks until the request may prompt the user, so prompts from
+// concurrent requests are shown one at a time. The turn is held until the
+// handler returns. Requests decided without prompting never take it, and
+// so never wait behind someone else's prompt.
+func (r *Request) waitTurn() error {
+	if r.prompts == nil || r.release != nil {
+		return nil
+	}
+	release, err := r.prompts.acquire(r.Context())
+	if err != nil {
+		return promptError(err)
+	}
+	r.release = release
+	return nil
+}
+
+// logf writes to the server's log, if the request came through one.
+func (r *Request) logf(format string, args ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+	}
+}
+
+// record appends ev to the session's audit log, if there is one.
+func (r *Request) record(ev audit.Event) {
+	if err := r.audit.Record(ev); err != nil {
+		r.logf("audit: %v", err)
+	}
 }
 
 // Response is a message sent from the host back to the container.
@@ -32,6 +92,19 @@ type Response struct {
 	Payload interface{} `json:"payload"`
 }
 
+// HelloRequest is the payload for "hello" requests, sent by version 2
+// clients as the first message on a connection.
+type HelloRequest struct {
+	Version int    `json:"version"`
+	Client  string `json:"client"`
+}
+
+// HelloResponse is the payload for "hello" responses.
+type HelloResponse struct {
+	Version int      `json:"version"`
+	Types   []string `json:"types"` // message types the server handles
+}
+
 // AllowDomainRequest is the payload for "allow_domain" requests.
 type AllowDomainRequest struct {
 	Domain string `json:"domain"`
@@ -126,3 +199,130 @@ type KVListResponse struct {
 	Entries []KVListEntry `json:"entries,omitempty"`
 	Error   string        `json:"error,omitempty"`
 }
+
+// NotifyRequest is the payload for "notify" requests.
+type NotifyRequest struct {
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// NotifyResponse is the payload for "notify" responses.
+type NotifyResponse struct {
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ClipCopyRequest is the payload for "clip_copy" requests.
+type ClipCopyRequest struct {
+	Text string `json:"text"`
+}
+
+// ClipCopyResponse is the payload for "clip_copy" responses.
+type ClipCopyResponse struct {
+	Approved bool   `json:"approved"`
+	Redacted bool   `json:"redacted,omitempty"` // vault secrets were replaced
+	Error    string `json:"error,omitempty"`
+}
+
+// ClipPasteRequest is the payload for "clip_paste" requests.
+type ClipPasteRequest struct{}
+
+// ClipPasteResponse is the payload for "clip_paste" responses.
+type ClipPasteResponse struct {
+	Text     string `json:"text,omitempty"`
+	Approved bool   `json:"approved"`
+	Redacted bool   `json:"redacted,omitempty"` // vault secrets were replaced
+	Error    string `json:"error,omitempty"`
+}
+
+// OpenURLRequest is the payload for "open_url" requests.
+type OpenURLRequest struct {
+	URL string `json:"url"`
+}
+
+// OpenURLResponse is the payload for "open_url" responses.
+type OpenURLResponse struct {
+	Approved bool `json:"approved"`
+	// CallbackPort is the loopback port relayed into the container for the
+	// URL's OAuth redirect, if any.
+	CallbackPort int    `json:"callback_port,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// FetchRequest is the payload for "request_file" requests.
+type FetchRequest struct {
+	Path string `json:"path"` // host path, absolute or starting with ~/
+}
+
+// FetchResponse is the payload for "request_file" responses.
+type FetchResponse struct {
+	Approved bool   `json:"approved"`
+	Path     string `json:"path,omitempty"` // copy inside the container
+	Size     int64  `json:"size,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ToolRequest is the payload for "tool_request" requests.
+type ToolRequest struct {
+	Package string `json:"package"`
+}
+
+// ToolResponse is the payload for "tool_request" responses.
+type ToolResponse struct {
+	Approved  bool   `json:"approved"`
+	Workspace string `json:"workspace,omitempty"`
+	// Queued is false when the package was already in the workspace.
+	Queued bool   `json:"queued,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HostActionRequest is the payload for "host_action" requests.
+type HostActionRequest struct {
+	Action string `json:"action"`
+}
+
+// HostActionResponse is the payload for "host_action" responses. Output is
+// then read with host_action_output requests for Run.
+type HostActionResponse struct {
+	Approved bool   `json:"approved"`
+	Run      string `json:"run,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HostActionOutputRequest is the payload for "host_action_output" requests.
+type HostActionOutputRequest struct {
+	Run string `json:"run"`
+}
+
+// HostActionOutputResponse is the payload for "host_action_output"
+// responses: output since the previous request and, once the command has
+// finished, its exit code.
+type HostActionOutputResponse struct {
+	Output   string `json:"output,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HostActionListRequest is the payload for "host_action_list" requests.
+type HostActionListRequest struct{}
+
+// HostActionListResponse is the payload for "host_action_list" responses.
+type HostActionListResponse struct {
+	Actions []HostActionInfo `json:"actions,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// HostActionInfo describes a host action to the container.
+type HostActionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// StatusRequest is the payload for "status" requests.
+type StatusRequest struct{}
+
+// StatusResponse is the payload for "status" responses.
+type StatusResponse struct {
+	Pending int `json:"pending"` // prompts showing or waiting to show
+}
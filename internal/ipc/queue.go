@@ -0,0 +1,84 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"context"
+	"sync"
+)
+
+// promptQueue lets interactive requests take turns in arrival order. The
+// head of the queue holds the prompt; everyone behind it waits.
+type promptQueue struct {
+	mu       sync.Mutex
+	waiting  []chan struct{}
+	onChange func(n int) // must not block
+}
+
+// acquire joins the queue and blocks until it is this caller's turn or ctx
+// ends. On success the returned func must be called to hand over the turn.
+func (q *promptQueue) acquire(ctx context.Context) (func(), error) {
+	turn := make(chan struct{})
+	q.mu.Lock()
+	q.waiting = append(q.waiting, turn)
+	if len(q.waiting) == 1 {
+		close(turn)
+	}
+	q.notify()
+	q.mu.Unlock()
+
+	select {
+	case <-turn:
+		return func() { q.remove(turn) }, nil
+	case <-ctx.Done():
+		q.remove(turn)
+		return nil, ctx.Err()
+	}
+}
+
+// remove drops turn from the queue, passing the prompt to the next waiter
+// if turn was at the head.
+func (q *promptQueue) remove(turn chan struct{}) {
+	q.mu.Lock()
+	for i, t := range q.waiting {
+		if t != turn {
+			continue
+		}
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+		if i == 0 && len(q.waiting) > 0 {
+			close(q.waiting[0])
+		}
+		break
+	}
+	q.notify()
+	q.mu.Unlock()
+}
+
+// len returns the number of requests prompting or waiting to prompt.
+func (q *promptQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiting)
+}
+
+// notify reports the queue length. Called with q.mu held so updates arrive
+// in order.
+func (q *promptQueue) notify() {
+	if q.onChange != nil {
+		q.onChange(len(q.waiting))
+	}
+}
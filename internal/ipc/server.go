@@ -18,29 +18,56 @@ package ipc
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
 )
 
 // maxRequestSize is the maximum allowed size of a single IPC request line.
 const maxRequestSize = 64 * 1024 // 64 KB
 
+// DefaultPromptTimeout bounds how long an interactive request may wait in
+// the prompt queue plus the time the user takes to answer.
+const DefaultPromptTimeout = 5 * time.Minute
+
 // HandlerFunc processes an IPC request and returns a response payload.
 type HandlerFunc func(req *Request) (interface{}, error)
 
+type handler struct {
+	fn          HandlerFunc
+	interactive bool
+}
+
 // Server listens on a Unix domain socket and dispatches JSON-lines messages.
+// Non-interactive handlers run concurrently; interactive ones take turns
+// through a FIFO queue so only one prompt is on screen at a time.
 type Server struct {
 	socketDir  string
 	socketPath string
 	listener   net.Listener
-	handlers   map[string]HandlerFunc
-	mu         sync.Mutex // serializes handler calls (one prompt at a time)
+	handlers   map[string]handler
+	scopes     []*scope
+	mu         sync.Mutex // guards handlers and scopes
+	logger     *log.Logger
+	audit      *audit.Log
+	prompts    promptQueue
+	ctx        context.Context
+	cancel     context.CancelFunc
 	done       chan struct{}
 	wg         sync.WaitGroup
+
+	// PromptTimeout overrides DefaultPromptTimeout. Must be set before Start.
+	PromptTimeout time.Duration
 }
 
 // NewServer creates a new IPC server with a temporary socket directory.
@@ -57,28 +84,64 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
-	// Allow non-root container user to connect.
+	// Allow non-root container user to connect. Requests are authenticated
+	// with per-helper tokens, see Scope.
 	if err := os.Chmod(socketPath, 0666); err != nil {
 		listener.Close()
 		os.RemoveAll(dir)
 		return nil, err
 	}
 
-	return &Server{
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
 		socketDir:  dir,
 		socketPath: socketPath,
 		listener:   listener,
-		handlers:   make(map[string]HandlerFunc),
+		handlers:   make(map[string]handler),
+		logger:     log.New(os.Stderr, "ipc: ", log.LstdFlags),
+		ctx:        ctx,
+		cancel:     cancel,
 		done:       make(chan struct{}),
-	}, nil
+	}
+	s.Handle("hello", func(req *Request) (interface{}, error) {
+		return HelloResponse{Version: ProtocolVersion, Types: s.types(req.scope)}, nil
+	})
+	s.Handle("status", func(*Request) (interface{}, error) {
+		return StatusResponse{Pending: s.QueueLength()}, nil
+	})
+	return s, nil
 }
 
-// Handle registers a handler for a message type.
-// Must be called before Start.
+// Handle registers a non-interactive handler for a message type. It may run
+// concurrently with any other handler. Must be called before Start.
 func (s *Server) Handle(msgType string, h HandlerFunc) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.handlers[msgType] = h
+	s.handlers[msgType] = handler{fn: h}
+}
+
+// HandleInteractive registers a handler that prompts the user. Interactive
+// requests are queued and run one at a time in arrival order. Must be
+// called before Start.
+func (s *Server) HandleInteractive(msgType string, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[msgType] = handler{fn: h, interactive: true}
+}
+
+// OnQueueChange registers fn to be called with the number of interactive
+// requests prompting or waiting whenever it changes. fn must not block.
+// Must be called before Start.
+func (s *Server) OnQueueChange(fn func(n int)) {
+	s.prompts.mu.Lock()
+	defer s.prompts.mu.Unlock()
+	s.prompts.onChange = fn
+}
+
+// QueueLength returns the number of interactive requests prompting or
+// waiting to prompt.
+func (s *Server) QueueLength() int {
+	return s.prompts.len()
 }
 
 // Start begins accepting connections in a background goroutine.
@@ -108,11 +171,29 @@ func (s *Server) Start() {
 // Stop closes the listener, waits for goroutines, and removes the socket dir.
 func (s *Server) Stop() {
 	close(s.done)
+	s.cancel()
 	_ = s.listener.Close()
 	s.wg.Wait()
 	_ = os.RemoveAll(s.socketDir)
 }
 
+// Done returns a channel that is closed when Stop is called.
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetLogOutput sets where rejected requests and other server errors are
+// logged. The default is stderr.
+func (s *Server) SetLogOutput(w io.Writer) {
+	s.logger.SetOutput(w)
+}
+
+// SetAuditLog sets where handlers record approvals and other security
+// events. Without one nothing is recorded. Must be called before Start.
+func (s *Server) SetAuditLog(l *audit.Log) {
+	s.audit = l
+}
+
 // SocketDir returns the directory containing the socket (for container mount).
 func (s *Server) SocketDir() string {
 	return s.socketDir
@@ -126,49 +207,94 @@ type ErrorResponse struct {
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 0, maxRequestSize), maxRequestSize)
-	if !scanner.Scan() {
-		return
-	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	// Unblock the reader when the server stops.
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
 
-	var req Request
-	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-		resp := Response{Type: "error", Payload: ErrorResponse{Error: "invalid request"}}
-		data, merr := json.Marshal(resp)
-		if merr != nil {
-			log.Printf("ipc: failed to marshal error response: %v", merr)
+	// Read on a separate goroutine so a client hanging up is noticed while
+	// a handler is still running: anything ending the read side cancels
+	// the connection's requests. Requests are answered in order.
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		defer cancel()
+		scanner := bufio.NewScanner(conn)
+		scanner.Buffer(make([]byte, 0, maxRequestSize), maxRequestSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for line := range lines {
+		resp, ok := s.dispatch(ctx, line)
+		data, err := json.Marshal(resp)
+		if err != nil {
+			s.logger.Printf("failed to marshal response: %v", err)
 			return
 		}
-		_, _ = conn.Write(append(data, '\n'))
-		return
+		if _, err := conn.Write(append(data, '\n')); err != nil || !ok {
+			return
+		}
+	}
+}
+
+// dispatch runs the handler for one request line. ok is false if the line
+// was not a valid request, after which the connection is closed.
+func (s *Server) dispatch(ctx context.Context, line []byte) (resp Response, ok bool) {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return Response{Type: "error", Payload: ErrorResponse{Error: "invalid request"}}, false
+	}
+
+	sc := s.authenticate(req.Token)
+	if sc == nil {
+		s.reject(&req, nil, "missing or invalid token")
+		return Response{Type: req.Type, ID: req.ID, Payload: ErrorResponse{Error: "unauthorized"}}, false
+	}
+	if !sc.allows(req.Type) {
+		s.reject(&req, sc, "message type not permitted")
+		return Response{
+			Type:    req.Type,
+			ID:      req.ID,
+			Payload: ErrorResponse{Error: "message type not permitted: " + req.Type},
+		}, true
 	}
 
 	s.mu.Lock()
-	handler, ok := s.handlers[req.Type]
-	if !ok {
-		s.mu.Unlock()
-		resp := Response{
+	h, found := s.handlers[req.Type]
+	s.mu.Unlock()
+	if !found {
+		return Response{
 			Type: req.Type,
 			ID:   req.ID,
 			Payload: ErrorResponse{
 				Error: "unknown message type: " + req.Type,
 			},
-		}
-		data, merr := json.Marshal(resp)
-		if merr != nil {
-			log.Printf("ipc: failed to marshal error response: %v", merr)
-			return
-		}
-		_, _ = conn.Write(append(data, '\n'))
-		return
+		}, true
 	}
 
-	// Serialize handler calls so only one TTY prompt runs at a time.
-	payload, err := handler(&req)
-	s.mu.Unlock()
+	req.ctx = ctx
+	req.scope = sc
+	req.logger = s.logger
+	req.audit = s.audit
+	var payload interface{}
+	var err error
+	if h.interactive {
+		payload, err = s.runInteractive(&req, h.fn)
+	} else {
+		payload, err = h.fn(&req)
+	}
 
-	resp := Response{
+	resp = Response{
 		Type: req.Type,
 		ID:   req.ID,
 	}
@@ -177,11 +303,52 @@ func (s *Server) handleConnection(conn net.Conn) {
 	} else {
 		resp.Payload = payload
 	}
+	return resp, true
+}
+
+// types returns the registered message types sc may send, sorted.
+func (s *Server) types(sc *scope) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types := make([]string, 0, len(s.handlers))
+	for t := range s.handlers {
+		if sc.allows(t) {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// runInteractive runs fn within the prompt timeout. fn joins the prompt
+// queue (Request.waitTurn) only once it is about to prompt, after any
+// policy rule had its say.
+func (s *Server) runInteractive(req *Request, fn HandlerFunc) (interface{}, error) {
+	timeout := s.PromptTimeout
+	if timeout <= 0 {
+		timeout = DefaultPromptTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.ctx, timeout)
+	defer cancel()
+	req.ctx = ctx
+	req.prompts = &s.prompts
+	defer func() {
+		if req.release != nil {
+			req.release()
+		}
+	}()
+
+	payload, err := fn(req)
+	if err != nil && ctx.Err() != nil {
+		return nil, promptError(ctx.Err())
+	}
+	return payload, err
+}
 
-	data, merr := json.Marshal(resp)
-	if merr != nil {
-		log.Printf("ipc: failed to marshal response: %v", merr)
-		return
+// promptError turns a context error into the message the client sees.
+func promptError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("timed out waiting for approval")
 	}
-	_, _ = conn.Write(append(data, '\n'))
+	return errors.New("request cancelled")
 }
@@ -2,9 +2,15 @@ package ipc
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/policy"
 )
 
 func TestServerRoundTrip(t *testing.T) {
@@ -31,8 +37,9 @@ func TestServerRoundTrip(t *testing.T) {
 	defer conn.Close()
 
 	req := Request{
-		Type: "echo",
-		ID:   "test-1",
+		Type:  "echo",
+		ID:    "test-1",
+		Token: srv.Scope("test", "echo"),
 	}
 	payloadBytes, err := json.Marshal(map[string]string{"msg": "hello"})
 	if err != nil {
@@ -92,7 +99,7 @@ func TestServerUnknownType(t *testing.T) {
 	}
 	defer conn.Close()
 
-	req := Request{Type: "nonexistent", ID: "test-2"}
+	req := Request{Type: "nonexistent", ID: "test-2", Token: srv.Scope("test", "nonexistent")}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -124,3 +131,347 @@ func TestServerUnknownType(t *testing.T) {
 		t.Error("expected error for unknown type")
 	}
 }
+
+// dialRequest connects to srv and sends a request of the given type.
+func dialRequest(t *testing.T, srv *Server, token, msgType string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("unix", srv.socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	data, err := json.Marshal(Request{Type: msgType, ID: msgType, Token: token})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	return conn
+}
+
+// readResponse reads one response line and decodes its payload into v.
+func readResponse(t *testing.T, conn net.Conn, v interface{}) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		t.Fatalf("no response: %v", scanner.Err())
+	}
+	var resp struct {
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if err := json.Unmarshal(resp.Payload, v); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+}
+
+// waitQueue waits until srv has n interactive requests queued.
+func waitQueue(t *testing.T, srv *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.QueueLength() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue length = %d, want %d", srv.QueueLength(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerNonInteractiveNotBlockedByPrompt(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+	token := srv.Scope("test", "prompt", "kv")
+
+	release := make(chan struct{})
+	srv.HandleInteractive("prompt", func(req *Request) (interface{}, error) {
+		if err := req.waitTurn(); err != nil {
+			return nil, err
+		}
+		<-release
+		return map[string]bool{"approved": true}, nil
+	})
+	srv.Handle("kv", func(req *Request) (interface{}, error) {
+		return map[string]string{"value": "v"}, nil
+	})
+	srv.Start()
+	defer close(release)
+
+	dialRequest(t, srv, token, "prompt")
+	waitQueue(t, srv, 1)
+
+	var got map[string]string
+	readResponse(t, dialRequest(t, srv, token, "kv"), &got)
+	if got["value"] != "v" {
+		t.Errorf("kv payload = %v", got)
+	}
+
+	var status StatusResponse
+	readResponse(t, dialRequest(t, srv, token, "status"), &status)
+	if status.Pending != 1 {
+		t.Errorf("status pending = %d, want 1", status.Pending)
+	}
+}
+
+func TestServerPolicyDecisionSkipsPromptQueue(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+	token := srv.Scope("test", "prompt", "allow_domain")
+
+	release := make(chan struct{})
+	srv.HandleInteractive("prompt", func(req *Request) (interface{}, error) {
+		if err := req.waitTurn(); err != nil {
+			return nil, err
+		}
+		<-release
+		return map[string]bool{"approved": true}, nil
+	})
+	p := stubPolicy{"allow_domain ": {Action: policy.Allow, Rule: "all", Source: "global"}}
+	srv.HandleInteractive("allow_domain", func(req *Request) (interface{}, error) {
+		approved, _, err := approve(req, p, "", func(context.Context, string) (bool, error) {
+			t.Error("policy-allowed request prompted")
+			return false, nil
+		})
+		return map[string]bool{"approved": approved}, err
+	})
+	srv.Start()
+	defer close(release)
+
+	dialRequest(t, srv, token, "prompt")
+	waitQueue(t, srv, 1)
+
+	// Decided by a rule, so it is answered while the prompt is still open.
+	var got map[string]bool
+	readResponse(t, dialRequest(t, srv, token, "allow_domain"), &got)
+	if !got["approved"] {
+		t.Errorf("allow_domain = %v, want approved by policy", got)
+	}
+	if n := srv.QueueLength(); n != 1 {
+		t.Errorf("queue length = %d, want 1", n)
+	}
+}
+
+func TestServerPromptsRunInOrder(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+	token := srv.Scope("test", "prompt", "kv")
+
+	var (
+		mu      sync.Mutex
+		order   []string
+		running int
+		overlap bool
+	)
+	release := make(chan struct{})
+	srv.HandleInteractive("prompt", func(req *Request) (interface{}, error) {
+		if err := req.waitTurn(); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		running++
+		overlap = overlap || running > 1
+		order = append(order, req.ID)
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return map[string]bool{"approved": true}, nil
+	})
+
+	var lengths []int
+	srv.OnQueueChange(func(n int) { lengths = append(lengths, n) })
+	srv.Start()
+
+	ids := []string{"a", "b", "c"}
+	conns := make([]net.Conn, len(ids))
+	for i, id := range ids {
+		conn, err := net.Dial("unix", srv.socketPath)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		defer conn.Close()
+		data, _ := json.Marshal(Request{Type: "prompt", ID: id, Token: token})
+		if _, err := conn.Write(append(data, '\n')); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		conns[i] = conn
+		waitQueue(t, srv, i+1)
+	}
+
+	for _, conn := range conns {
+		release <- struct{}{}
+		var got map[string]bool
+		readResponse(t, conn, &got)
+	}
+	waitQueue(t, srv, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlap {
+		t.Error("interactive handlers overlapped")
+	}
+	if len(order) != 3 || order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Errorf("order = %v, want [a b c]", order)
+	}
+	if len(lengths) == 0 || lengths[len(lengths)-1] != 0 {
+		t.Errorf("queue changes = %v, want to end at 0", lengths)
+	}
+}
+
+func TestServerPromptTimeout(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+	token := srv.Scope("test", "prompt", "kv")
+	srv.PromptTimeout = 50 * time.Millisecond
+
+	srv.HandleInteractive("prompt", func(req *Request) (interface{}, error) {
+		if err := req.waitTurn(); err != nil {
+			return nil, err
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+	srv.Start()
+
+	var got ErrorResponse
+	readResponse(t, dialRequest(t, srv, token, "prompt"), &got)
+	if got.Error != "timed out waiting for approval" {
+		t.Errorf("error = %q", got.Error)
+	}
+	waitQueue(t, srv, 0)
+}
+
+func TestServerPromptCancelledOnDisconnect(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+	token := srv.Scope("test", "prompt", "kv")
+
+	cancelled := make(chan struct{})
+	srv.HandleInteractive("prompt", func(req *Request) (interface{}, error) {
+		if err := req.waitTurn(); err != nil {
+			return nil, err
+		}
+		<-req.Context().Done()
+		close(cancelled)
+		return nil, req.Context().Err()
+	})
+	srv.Start()
+
+	conn := dialRequest(t, srv, token, "prompt")
+	waitQueue(t, srv, 1)
+	// A second client queued behind the first gives up too.
+	queued := dialRequest(t, srv, token, "prompt")
+	waitQueue(t, srv, 2)
+
+	queued.Close()
+	waitQueue(t, srv, 1)
+	conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prompt was not cancelled after the client disconnected")
+	}
+	waitQueue(t, srv, 0)
+}
+
+func TestServerRejectsUnauthorized(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	var logged strings.Builder
+	var logMu sync.Mutex
+	srv.SetLogOutput(writerFunc(func(p []byte) (int, error) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		return logged.Write(p)
+	}))
+
+	called := false
+	srv.Handle("vault_get", func(req *Request) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	srv.Handle("kv_get", func(req *Request) (interface{}, error) {
+		return map[string]string{}, nil
+	})
+	kvToken := srv.Scope("exitbox-kv", "kv_get")
+	srv.Start()
+
+	for _, tc := range []struct {
+		token, msgType, want string
+	}{
+		{"", "vault_get", "unauthorized"},
+		{"bogus", "vault_get", "unauthorized"},
+		{kvToken, "vault_get", "message type not permitted: vault_get"},
+	} {
+		var got ErrorResponse
+		readResponse(t, dialRequest(t, srv, tc.token, tc.msgType), &got)
+		if got.Error != tc.want {
+			t.Errorf("token %q: error = %q, want %q", tc.token, got.Error, tc.want)
+		}
+	}
+	if called {
+		t.Error("vault_get handler ran for a rejected request")
+	}
+
+	var hello HelloResponse
+	readResponse(t, dialRequest(t, srv, kvToken, "hello"), &hello)
+	if strings.Join(hello.Types, ",") != "hello,kv_get,status" {
+		t.Errorf("hello types = %v, want only the kv scope", hello.Types)
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if n := strings.Count(logged.String(), "rejected \"vault_get\""); n != 3 {
+		t.Errorf("logged %d rejections, want 3:\n%s", n, logged.String())
+	}
+	if !strings.Contains(logged.String(), "from exitbox-kv") {
+		t.Errorf("log does not name the helper:\n%s", logged.String())
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestServerDoneClosedOnStop(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	srv.Start()
+	select {
+	case <-srv.Done():
+		t.Fatal("Done closed before Stop")
+	default:
+	}
+	srv.Stop()
+	select {
+	case <-srv.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed by Stop")
+	}
+}
@@ -0,0 +1,276 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+var networkNameUnsafe = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// AgentNetwork returns the internal network an agent container joins. By
+// default every session gets its own network so agents cannot reach each
+// other; with settings.network_isolation: workspace, sessions of the same
+// workspace share one.
+func AgentNetwork(containerName, workspace string) string {
+	if config.LoadOrDefault().Settings.NetworkIsolation == config.NetworkPerWorkspace {
+		if workspace == "" {
+			workspace = "default"
+		}
+		name := strings.Trim(networkNameUnsafe.ReplaceAllString(strings.ToLower(workspace), "-"), "-")
+		return InternalNetwork() + "-ws-" + name
+	}
+	return InternalNetwork() + "-" + strings.TrimPrefix(containerName, config.Namespace()+"-")
+}
+
+const (
+	// defaultAgentSubnetPool is where agent network subnets come from
+	// unless settings.agent_subnet_pool says otherwise. Runtime default
+	// pools run out after a few dozen networks.
+	defaultAgentSubnetPool = "10.213.0.0/16"
+	// agentSubnetBits sizes each agent network: 61 usable addresses, enough
+	// for the proxies and a shared workspace network.
+	agentSubnetBits = 26
+	// maxCreateAttempts bounds retries when the runtime rejects a subnet
+	// that overlaps something it knows about and we don't.
+	maxCreateAttempts = 8
+)
+
+// agentSubnetPool returns the configured pool for agent networks.
+func agentSubnetPool(cfg *config.Config) (*net.IPNet, error) {
+	pool := cfg.Settings.AgentSubnetPool
+	if pool == "" {
+		pool = defaultAgentSubnetPool
+	}
+	_, ipnet, err := net.ParseCIDR(pool)
+	if err != nil || ipnet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid settings.agent_subnet_pool %q: want an IPv4 CIDR", pool)
+	}
+	if ones, _ := ipnet.Mask.Size(); ones > agentSubnetBits {
+		return nil, fmt.Errorf("settings.agent_subnet_pool %q is smaller than one /%d agent network", pool, agentSubnetBits)
+	}
+	return ipnet, nil
+}
+
+// nextAgentSubnet returns the first /agentSubnetBits subnet of pool that
+// overlaps none of used, or nil when the pool is exhausted.
+func nextAgentSubnet(pool *net.IPNet, used []*net.IPNet) *net.IPNet {
+	ones, _ := pool.Mask.Size()
+	base := binary.BigEndian.Uint32(pool.IP.To4())
+	size := uint32(1) << (32 - agentSubnetBits)
+	count := uint32(1) << (agentSubnetBits - ones)
+	mask := net.CIDRMask(agentSubnetBits, 32)
+	for i := uint32(0); i < count; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+i*size)
+		candidate := &net.IPNet{IP: ip, Mask: mask}
+		free := true
+		for _, u := range used {
+			if u.Contains(candidate.IP) || candidate.Contains(u.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// usedSubnets returns the subnets of all networks the runtime knows.
+func usedSubnets(rt container.Runtime) []*net.IPNet {
+	names, err := rt.NetworkList()
+	if err != nil {
+		return nil
+	}
+	var used []*net.IPNet
+	for _, n := range names {
+		subnet, err := GetNetworkSubnet(rt, n)
+		if err != nil {
+			continue
+		}
+		if _, ipnet, err := net.ParseCIDR(subnet); err == nil {
+			used = append(used, ipnet)
+		}
+	}
+	return used
+}
+
+// createAgentNetwork creates an internal agent network with a subnet from
+// the agent pool. The subnet is set explicitly so containers can be given
+// fixed addresses (--ip) and so sessions do not use up the runtime's own
+// address pools. The caller holds the firewall lock.
+func createAgentNetwork(rt container.Runtime, name string) error {
+	pool, err := agentSubnetPool(config.LoadOrDefault())
+	if err != nil {
+		return err
+	}
+	used := usedSubnets(rt)
+	var lastErr error
+	for i := 0; i < maxCreateAttempts; i++ {
+		subnet := nextAgentSubnet(pool, used)
+		if subnet == nil {
+			networks, _ := agentNetworks(rt)
+			return fmt.Errorf("no free subnet left for network %s: all /%d subnets of %s are in use (%d agent networks exist); "+
+				"end some sessions, run 'exitbox clean containers', set network_isolation: workspace or widen settings.agent_subnet_pool",
+				name, agentSubnetBits, pool, len(networks))
+		}
+		if lastErr = rt.NetworkCreate(name, true, subnet.String()); lastErr == nil {
+			return nil
+		}
+		if rt.NetworkExists(name) {
+			return nil // created concurrently by another process
+		}
+		used = append(used, subnet)
+	}
+	return fmt.Errorf("failed to create internal network %s: %w", name, lastErr)
+}
+
+// isAgentNetwork reports whether name is one of this user's internal agent
+// networks, whose names start with base (see InternalNetwork).
+func isAgentNetwork(name, base string) bool {
+	return name == base || strings.HasPrefix(name, base+"-")
+}
+
+// agentNetworks lists the internal agent networks that currently exist.
+func agentNetworks(rt container.Runtime) ([]string, error) {
+	names, err := rt.NetworkList()
+	if err != nil {
+		return nil, err
+	}
+	base := InternalNetwork()
+	var result []string
+	for _, n := range names {
+		if isAgentNetwork(n, base) {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
+
+// agentSubnets returns the subnets of all agent networks, i.e. the clients
+// the proxy accepts.
+func agentSubnets(rt container.Runtime) ([]string, error) {
+	networks, err := agentNetworks(rt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	var subnets []string
+	for _, n := range networks {
+		subnet, err := GetNetworkSubnet(rt, n)
+		if err != nil {
+			ui.Warnf("Skipping network %s: %v", n, err)
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+	if len(subnets) == 0 {
+		return nil, fmt.Errorf("could not detect any internal network subnet")
+	}
+	return subnets, nil
+}
+
+// containerNetworks returns the networks a container is attached to.
+func containerNetworks(rt container.Runtime, name string) map[string]bool {
+	out, err := exec.Command(container.Cmd(rt), "inspect", name,
+		"--format", `{{range $k, $v := .NetworkSettings.Networks}}{{$k}} {{end}}`).Output()
+	if err != nil {
+		return nil
+	}
+	result := make(map[string]bool)
+	for _, n := range strings.Fields(string(out)) {
+		result[n] = true
+	}
+	return result
+}
+
+// attachProxy connects a proxy container to every agent network it is not
+// on yet.
+func attachProxy(rt container.Runtime, proxyName string) error {
+	networks, err := agentNetworks(rt)
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+	attached := containerNetworks(rt, proxyName)
+	for _, n := range networks {
+		if attached[n] {
+			continue
+		}
+		if err := rt.NetworkConnect(n, proxyName); err != nil {
+			return fmt.Errorf("failed to connect %s to %s: %w", proxyName, n, err)
+		}
+	}
+	return nil
+}
+
+// removeIdleAgentNetworks removes agent networks that no agent container
+// is attached to, detaching the given proxies first. Networks in keep
+// belong to sessions whose container may not have started yet. It returns
+// the number of networks removed. Networks still being torn down by the
+// runtime are left for the next cleanup.
+func removeIdleAgentNetworks(rt container.Runtime, proxies, keep []string) int {
+	networks, err := agentNetworks(rt)
+	if err != nil {
+		return 0
+	}
+	isProxy := make(map[string]bool)
+	for _, p := range proxies {
+		isProxy[p] = true
+	}
+	kept := make(map[string]bool)
+	for _, n := range keep {
+		kept[n] = true
+	}
+
+	removed := 0
+	for _, n := range networks {
+		if kept[n] {
+			continue
+		}
+		members, err := rt.PS("network="+n, "{{.Names}}")
+		if err != nil {
+			continue
+		}
+		idle := true
+		for _, m := range members {
+			if !isProxy[m] {
+				idle = false
+				break
+			}
+		}
+		if !idle {
+			continue
+		}
+		for _, m := range members {
+			_ = rt.NetworkDisconnect(n, m)
+		}
+		if rt.NetworkRemove(n) == nil {
+			removed++
+		}
+	}
+	return removed
+}
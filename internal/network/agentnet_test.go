@@ -0,0 +1,189 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// netRuntime fakes the network parts of container.Runtime.
+type netRuntime struct {
+	container.Runtime
+	networks     []string
+	members      map[string][]string // network -> running containers
+	removed      []string
+	disconnected []string
+}
+
+func (r *netRuntime) NetworkList() ([]string, error) { return r.networks, nil }
+
+func (r *netRuntime) PS(filter, _ string) ([]string, error) {
+	return r.members[strings.TrimPrefix(filter, "network=")], nil
+}
+
+func (r *netRuntime) NetworkDisconnect(network, ctr string) error {
+	r.disconnected = append(r.disconnected, network+"/"+ctr)
+	return nil
+}
+
+func (r *netRuntime) NetworkRemove(name string) error {
+	r.removed = append(r.removed, name)
+	return nil
+}
+
+// useNamespace points config.Home at a temp dir whose config sets
+// settings.resource_prefix.
+func useNamespace(t *testing.T, prefix string) *config.Config {
+	t.Helper()
+	origHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = origHome })
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.ResourcePrefix = prefix
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestAgentNetwork(t *testing.T) {
+	origHome := config.Home
+	config.Home = t.TempDir()
+	defer func() { config.Home = origHome }()
+
+	ns := config.Namespace()
+	if got := AgentNetwork(ns+"-claude-app-1a2b3c4d", "work"); got != ns+"-int-claude-app-1a2b3c4d" {
+		t.Errorf("per-session network = %q", got)
+	}
+
+	cfg := useNamespace(t, "ci")
+	cfg.Settings.NetworkIsolation = config.NetworkPerWorkspace
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := AgentNetwork("ci-claude-app-1a2b3c4d", "My Work"); got != "ci-int-ws-my-work" {
+		t.Errorf("per-workspace network = %q", got)
+	}
+	if got := AgentNetwork("ci-claude-app-1a2b3c4d", ""); got != "ci-int-ws-default" {
+		t.Errorf("per-workspace network without workspace = %q", got)
+	}
+}
+
+func TestIsAgentNetwork(t *testing.T) {
+	for name, want := range map[string]bool{
+		"exitbox-1000-int":                     true,
+		"exitbox-1000-int-claude-app-1a2b3c4d": true,
+		"exitbox-1000-int-ws-work":             true,
+		"exitbox-1000-egress":                  false,
+		"exitbox-1000-intranet":                false,
+		"exitbox-1001-int-claude-app-1a2b3c4d": false,
+		"exitbox-int":                          false,
+		"bridge":                               false,
+	} {
+		if got := isAgentNetwork(name, "exitbox-1000-int"); got != want {
+			t.Errorf("isAgentNetwork(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRemoveIdleAgentNetworks(t *testing.T) {
+	useNamespace(t, "ci")
+	rt := &netRuntime{
+		networks: []string{"ci-egress", "ci-int-a", "ci-int-b", "ci-int-c"},
+		members: map[string][]string{
+			"ci-egress": {SquidContainer()},
+			"ci-int-a":  {SquidContainer(), "ci-claude-a"},
+			"ci-int-b":  {SquidContainer()},
+		},
+	}
+
+	if n := removeIdleAgentNetworks(rt, []string{SquidContainer()}, nil); n != 2 {
+		t.Errorf("removed %d networks, want 2", n)
+	}
+	if strings.Join(rt.removed, ",") != "ci-int-b,ci-int-c" {
+		t.Errorf("removed = %v, want only idle agent networks", rt.removed)
+	}
+	if strings.Join(rt.disconnected, ",") != "ci-int-b/"+SquidContainer() {
+		t.Errorf("disconnected = %v", rt.disconnected)
+	}
+}
+
+func TestRemoveIdleAgentNetworks_KeepsActiveSessions(t *testing.T) {
+	useNamespace(t, "ci")
+	rt := &netRuntime{
+		networks: []string{"ci-int-a", "ci-int-b"},
+		members:  map[string][]string{"ci-int-a": {SquidContainer()}},
+	}
+
+	// ci-int-a belongs to a session whose container has not started.
+	if n := removeIdleAgentNetworks(rt, []string{SquidContainer()}, []string{"ci-int-a"}); n != 1 {
+		t.Errorf("removed %d networks, want 1", n)
+	}
+	if strings.Join(rt.removed, ",") != "ci-int-b" {
+		t.Errorf("removed = %v, want only ci-int-b", rt.removed)
+	}
+}
+
+func TestAgentSubnetPool(t *testing.T) {
+	for _, tc := range []struct {
+		pool string
+		want string
+		ok   bool
+	}{
+		{"", defaultAgentSubnetPool, true},
+		{"10.50.0.0/20", "10.50.0.0/20", true},
+		{"10.50.0.1/24", "10.50.0.0/24", true},
+		{"10.50.0.0/27", "", false},
+		{"fd00::/64", "", false},
+		{"bogus", "", false},
+	} {
+		cfg := &config.Config{Settings: config.SettingsConfig{AgentSubnetPool: tc.pool}}
+		got, err := agentSubnetPool(cfg)
+		if (err == nil) != tc.ok {
+			t.Errorf("agentSubnetPool(%q) error = %v, want ok=%v", tc.pool, err, tc.ok)
+			continue
+		}
+		if tc.ok && got.String() != tc.want {
+			t.Errorf("agentSubnetPool(%q) = %s, want %s", tc.pool, got, tc.want)
+		}
+	}
+}
+
+func TestNextAgentSubnet(t *testing.T) {
+	cidr := func(s string) *net.IPNet {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	pool := cidr("10.213.0.0/24")
+
+	if got := nextAgentSubnet(pool, nil); got.String() != "10.213.0.0/26" {
+		t.Errorf("empty pool: got %s", got)
+	}
+	used := []*net.IPNet{cidr("10.213.0.0/26"), cidr("10.213.0.64/28"), cidr("172.17.0.0/16")}
+	if got := nextAgentSubnet(pool, used); got.String() != "10.213.0.128/26" {
+		t.Errorf("partly used: got %s", got)
+	}
+	// A wider network covering the whole pool leaves nothing.
+	if got := nextAgentSubnet(pool, []*net.IPNet{cidr("10.0.0.0/8")}); got != nil {
+		t.Errorf("covered pool: got %s, want nil", got)
+	}
+	used = append(used, cidr("10.213.0.128/25"))
+	if got := nextAgentSubnet(pool, used); got != nil {
+		t.Errorf("exhausted pool: got %s, want nil", got)
+	}
+}
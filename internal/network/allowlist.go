@@ -95,3 +95,70 @@ func NormalizeAllowlistEntry(value string) (string, error) {
 	// Squid dstdomain with leading dot
 	return "." + strings.ToLower(value), nil
 }
+
+// NormalizeDenylistEntry normalizes a denylist entry for a Squid dstdomain
+// ACL. Unlike allowlist entries, a bare hostname matches only that host;
+// "*.example.com" or ".example.com" match the domain and its subdomains.
+func NormalizeDenylistEntry(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	wildcard := strings.HasPrefix(value, "*.") || strings.HasPrefix(value, ".")
+
+	normalized, err := NormalizeAllowlistEntry(value)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(normalized, ".") || wildcard {
+		return normalized, nil
+	}
+	return strings.TrimPrefix(normalized, "."), nil
+}
+
+// IsDenied reports whether host is blocked by any of the denylist entries.
+// host may be given in any form accepted by NormalizeAllowlistEntry; a
+// wildcard request such as "*.example.com" is checked against its base
+// domain.
+func IsDenied(host string, deny []string) bool {
+	normalized, err := NormalizeAllowlistEntry(host)
+	if err != nil {
+		return false
+	}
+	name := strings.TrimPrefix(normalized, ".")
+	for _, entry := range deny {
+		d, err := NormalizeDenylistEntry(entry)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(d, ".") {
+			base := d[1:]
+			if name == base || strings.HasSuffix(name, d) {
+				return true
+			}
+			continue
+		}
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowed reports whether host may be reached under the allowlist
+// entries. Entries follow the allowlist rules: a domain also covers its
+// subdomains, while IP addresses and localhost match exactly.
+func IsAllowed(host string, allow []string) bool {
+	normalized, err := NormalizeAllowlistEntry(host)
+	if err != nil {
+		return false
+	}
+	name := strings.TrimPrefix(normalized, ".")
+	for _, entry := range allow {
+		a, err := NormalizeAllowlistEntry(entry)
+		if err != nil {
+			continue
+		}
+		if a == normalized || (strings.HasPrefix(a, ".") && (name == a[1:] || strings.HasSuffix(name, a))) {
+			return true
+		}
+	}
+	return false
+}
@@ -79,3 +79,92 @@ func TestNormalizeAllowlistEntry(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalizeDenylistEntry(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		// Bare hostnames match exactly
+		{"gist.github.com", "gist.github.com", false},
+		{"https://Gist.GitHub.com/path", "gist.github.com", false},
+
+		// Wildcards match the domain and its subdomains
+		{"*.amazonaws.com", ".amazonaws.com", false},
+		{".amazonaws.com", ".amazonaws.com", false},
+
+		// Addresses are passed through
+		{"1.2.3.4", "1.2.3.4", false},
+		{"localhost", "localhost", false},
+
+		// Invalid entries
+		{"", "", true},
+		{"*.not valid!", "", true},
+	}
+
+	for _, tc := range tests {
+		got, err := NormalizeDenylistEntry(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("NormalizeDenylistEntry(%q) = %q, want error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeDenylistEntry(%q) error = %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("NormalizeDenylistEntry(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestIsDenied(t *testing.T) {
+	deny := []string{"gist.github.com", "*.amazonaws.com", "not valid!"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"gist.github.com", true},
+		{"https://gist.github.com/foo", true},
+		{"api.gist.github.com", false},
+		{"github.com", false},
+		{"amazonaws.com", true},
+		{"s3.amazonaws.com", true},
+		{"*.amazonaws.com", true},
+		{"notamazonaws.com", false},
+		{"not valid!", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsDenied(tc.host, deny); got != tc.want {
+			t.Errorf("IsDenied(%q) = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	allow := []string{"github.com", "*.googleapis.com", "10.0.0.5", "not valid!"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"github.com", true},
+		{"https://github.com/login/oauth", true},
+		{"api.github.com", true},
+		{"notgithub.com", false},
+		{"oauth2.googleapis.com", true},
+		{"10.0.0.5", true},
+		{"10.0.0.6", false},
+		{"example.com", false},
+		{"not valid!", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsAllowed(tc.host, allow); got != tc.want {
+			t.Errorf("IsAllowed(%q) = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
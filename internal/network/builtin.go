@@ -0,0 +1,189 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/proxy"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+const proxyImage = "exitbox-proxy"
+
+// ProxyContainer is the name of this user's builtin exitbox-proxy container.
+func ProxyContainer() string { return config.Namespace() + "-proxy" }
+
+// builtinBackend reports whether settings.firewall_backend selects the
+// builtin proxy instead of Squid.
+func builtinBackend() bool {
+	return config.LoadOrDefault().Settings.FirewallBackend == config.FirewallBuiltin
+}
+
+// proxyACLFile is the ACL the builtin proxy loads at start. Updates are
+// pushed over its control socket as well, so the file only matters when the
+// proxy container (re)starts.
+func proxyACLFile() string {
+	return filepath.Join(config.Cache, "proxy-acl.json")
+}
+
+// BuildProxyACL builds the builtin proxy ACL from the same inputs as
+// GenerateSquidConfig, with entries normalized the same way.
+func BuildProxyACL(subnets []string, domains []string, extraURLs []string, denied []string, grants []AgentGrant) proxy.ACL {
+	acl := proxy.ACL{
+		Sources: subnets,
+		Allow:   normalizeAllowlist(domains, extraURLs),
+		Deny:    normalizeDenylist(denied),
+	}
+	for _, g := range normalizeGrants(grants) {
+		acl.Grants = append(acl.Grants, proxy.Grant{Source: g.Source, Allow: g.Domains})
+	}
+	if len(acl.Allow) == 0 {
+		ui.Warn("Allowlist is empty or invalid. Blocking all outbound destinations.")
+	}
+	return acl
+}
+
+// writeProxyACL generates the ACL for all sessions and writes it to disk.
+func writeProxyACL(rt container.Runtime, extraURLs []string) (proxy.ACL, error) {
+	subnets, err := agentSubnets(rt)
+	if err != nil {
+		return proxy.ACL{}, err
+	}
+
+	al := config.LoadAllowlistOrDefault()
+	domains, grants := firewallDomains(config.LoadOrDefault(), al)
+	denied := append(append([]string{}, al.Deny...), collectAllSessionDeny()...)
+	acl := BuildProxyACL(subnets, domains, extraURLs, denied, grants)
+
+	data, err := json.MarshalIndent(acl, "", "  ")
+	if err != nil {
+		return proxy.ACL{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(proxyACLFile()), 0755); err != nil {
+		return proxy.ACL{}, fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return acl, os.WriteFile(proxyACLFile(), data, 0644)
+}
+
+// pushProxyACL sends the ACL to the running proxy over its control socket.
+func pushProxyACL(rt container.Runtime, acl proxy.ACL) error {
+	data, err := json.Marshal(acl)
+	if err != nil {
+		return err
+	}
+	c := exec.Command(container.Cmd(rt), "exec", "-i", ProxyContainer(), "/exitbox-proxy", "set-acl")
+	c.Stdin = bytes.NewReader(data)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update proxy ACL: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// reloadBuiltinProxy regenerates the ACL and applies it to the running proxy.
+func reloadBuiltinProxy(rt container.Runtime, extraURLs []string) error {
+	acl, err := writeProxyACL(rt, extraURLs)
+	if err != nil {
+		return err
+	}
+	if isContainerRunning(rt, ProxyContainer()) {
+		return pushProxyACL(rt, acl)
+	}
+	return nil
+}
+
+// startBuiltinProxy starts the exitbox-proxy container, or updates its ACL
+// if it is already running.
+func startBuiltinProxy(rt container.Runtime, containerName string, extraURLs []string) error {
+	if err := checkBuiltinSettings(config.LoadOrDefault().Settings); err != nil {
+		return err
+	}
+
+	if len(extraURLs) > 0 {
+		if err := RegisterSessionURLs(containerName, extraURLs); err != nil {
+			ui.Warnf("Failed to register session URLs: %v", err)
+		}
+	}
+	allExtraURLs := collectAllSessionURLs()
+
+	if isContainerRunning(rt, ProxyContainer()) {
+		if err := attachProxy(rt, ProxyContainer()); err != nil {
+			return err
+		}
+		return reloadBuiltinProxy(rt, allExtraURLs)
+	}
+
+	// Remove if stopped
+	_ = rt.Remove(ProxyContainer())
+
+	EnsureNetworks(rt, "")
+
+	if _, err := writeProxyACL(rt, allExtraURLs); err != nil {
+		return err
+	}
+
+	runArgs := []string{
+		"run", "-d",
+		"--name", ProxyContainer(),
+		"--network", EgressNetwork(),
+		"-v", proxyACLFile() + ":/etc/exitbox-proxy/acl.json:ro",
+		"--restart=unless-stopped",
+		"--add-host=host.docker.internal:host-gateway",
+	}
+	for _, dns := range getSquidDNSServers() {
+		runArgs = append(runArgs, "--dns", dns)
+	}
+	runArgs = append(runArgs, proxyImage)
+
+	ui.Info("Starting built-in proxy...")
+	cmd := container.Cmd(rt)
+	if out, err := exec.Command(cmd, runArgs...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start built-in proxy: %w: %s", err, string(out))
+	}
+
+	if err := attachProxy(rt, ProxyContainer()); err != nil {
+		_ = rt.Remove(ProxyContainer())
+		return err
+	}
+	return nil
+}
+
+// checkBuiltinSettings refuses security settings the builtin proxy cannot
+// enforce. Starting anyway would silently send egress direct or skip the
+// SNI check the user asked for.
+func checkBuiltinSettings(s config.SettingsConfig) error {
+	var squidOnly []string
+	if s.UpstreamProxy.URL != "" {
+		squidOnly = append(squidOnly, "settings.upstream_proxy")
+	}
+	if s.SNIEnforcement {
+		squidOnly = append(squidOnly, "settings.sni_enforcement")
+	}
+	if len(squidOnly) > 0 {
+		return fmt.Errorf("%s requires the squid firewall backend; remove it or set settings.firewall_backend to squid",
+			strings.Join(squidOnly, " and "))
+	}
+	return nil
+}
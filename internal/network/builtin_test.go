@@ -0,0 +1,49 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestCheckBuiltinSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings config.SettingsConfig
+		wantErr  string
+	}{
+		{"defaults", config.SettingsConfig{}, ""},
+		{"proxy cache", config.SettingsConfig{ProxyCache: config.ProxyCache{Enabled: true}}, ""},
+		{"upstream proxy", config.SettingsConfig{UpstreamProxy: config.UpstreamProxy{URL: "http://proxy.corp:8080"}}, "settings.upstream_proxy"},
+		{"sni", config.SettingsConfig{SNIEnforcement: true}, "settings.sni_enforcement"},
+	}
+	for _, tc := range tests {
+		err := checkBuiltinSettings(tc.settings)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error = %v, want mention of %s", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/proxy"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+const (
+	mirrorCacheDir   = "/var/cache/exitbox-mirror"
+	mirrorCADir      = "/etc/exitbox-proxy/ca"
+	mirrorPort       = "3129"
+	mirrorCacheLabel = "exitbox.cache"
+
+	defaultCacheSizeMB = 10240
+)
+
+// MirrorContainer is the name of this user's package registry mirror. It
+// runs the exitbox-proxy image on the agent networks only and fetches
+// everything through the firewall proxy.
+func MirrorContainer() string { return config.Namespace() + "-mirror" }
+
+// CacheVolume persists the mirror's cache across restarts.
+func CacheVolume() string { return config.Namespace() + "-cache" }
+
+// cacheSizeMB returns the configured cache size, or 0 when caching is off.
+func cacheSizeMB(cfg *config.Config) int {
+	pc := cfg.Settings.ProxyCache
+	if !pc.Enabled {
+		return 0
+	}
+	if pc.SizeMB > 0 {
+		return pc.SizeMB
+	}
+	return defaultCacheSizeMB
+}
+
+// runningCacheSizeMB returns the cache size recorded on the running mirror,
+// or 0 if it is not running.
+func runningCacheSizeMB(rt container.Runtime) int {
+	out, err := exec.Command(container.Cmd(rt), "inspect", MirrorContainer(),
+		"--format", fmt.Sprintf(`{{index .Config.Labels %q}}`, mirrorCacheLabel)).Output()
+	if err != nil {
+		return 0
+	}
+	size, _ := strconv.Atoi(strings.TrimSpace(string(out)))
+	return size
+}
+
+// startMirror starts the registry mirror when settings.proxy_cache is
+// enabled, or attaches the running one to new agent networks. Its upstream
+// is the firewall proxy, so the allowlist, denylist and upstream proxy
+// settings apply to everything it fetches.
+func startMirror(rt container.Runtime, cfg *config.Config) error {
+	size := cacheSizeMB(cfg)
+	if isContainerRunning(rt, MirrorContainer()) {
+		if size != runningCacheSizeMB(rt) {
+			ui.Warnf("Proxy cache settings change once all running sessions have ended")
+		}
+		return attachProxy(rt, MirrorContainer())
+	}
+	if size == 0 {
+		return nil
+	}
+
+	// Remove if stopped
+	_ = rt.Remove(MirrorContainer())
+
+	networks, err := agentNetworks(rt)
+	if err != nil || len(networks) == 0 {
+		return fmt.Errorf("no agent network to attach the registry mirror to")
+	}
+	upstream := SquidContainer()
+	if cfg.Settings.FirewallBackend == config.FirewallBuiltin {
+		upstream = ProxyContainer()
+	}
+
+	runArgs := []string{
+		"run", "-d",
+		"--name", MirrorContainer(),
+		"--network", networks[0],
+		"-v", CacheVolume() + ":" + mirrorCacheDir,
+		"--label", fmt.Sprintf("%s=%d", mirrorCacheLabel, size),
+		"--restart=unless-stopped",
+	}
+	for i, ca := range cfg.Settings.CACertificates {
+		runArgs = append(runArgs, "-v", fmt.Sprintf("%s:%s/%d.pem:ro", mirrorCAPath(ca), mirrorCADir, i))
+	}
+	runArgs = append(runArgs, proxyImage, "mirror",
+		"-upstream", "http://"+upstream+":3128",
+		"-max-mb", strconv.Itoa(size))
+
+	ui.Info("Starting package registry mirror...")
+	if out, err := exec.Command(container.Cmd(rt), runArgs...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start registry mirror: %w: %s", err, string(out))
+	}
+	if err := attachProxy(rt, MirrorContainer()); err != nil {
+		_ = rt.Remove(MirrorContainer())
+		return err
+	}
+	return nil
+}
+
+// mirrorCAPath resolves a settings.ca_certificates entry for a bind mount.
+func mirrorCAPath(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[2:])
+		}
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// mirrorEnv points package managers at the mirror listening on addr.
+// Registries the firewall would block are skipped, so those clients fail
+// the same way they would without the cache.
+func mirrorEnv(addr string, allowed, denied []string) []string {
+	reachable := func(name string) bool {
+		for _, r := range proxy.Registries {
+			if r.Name != name {
+				continue
+			}
+			u, err := url.Parse(r.Origin)
+			if err != nil {
+				return false
+			}
+			return IsAllowed(u.Hostname(), allowed) && !IsDenied(u.Hostname(), denied)
+		}
+		return false
+	}
+
+	base := "http://" + addr
+	var env []string
+	if reachable("npm") {
+		env = append(env, "NPM_CONFIG_REGISTRY="+base+"/npm/")
+	}
+	// pip and uv fetch files from files.pythonhosted.org through links in
+	// the index pages, which the mirror rewrites to itself.
+	if reachable("pypi") && reachable("pypi-files") {
+		host, _, _ := net.SplitHostPort(addr)
+		env = append(env,
+			"PIP_INDEX_URL="+base+"/pypi/simple/",
+			"PIP_TRUSTED_HOST="+host,
+			"UV_DEFAULT_INDEX="+base+"/pypi/simple/",
+		)
+	}
+	if reachable("go") {
+		env = append(env, "GOPROXY="+base+"/go,direct")
+	}
+	return env
+}
+
+// CacheStats returns the registry mirror's cache statistics. The mirror
+// must be running.
+func CacheStats(rt container.Runtime) (string, error) {
+	if !isContainerRunning(rt, MirrorContainer()) {
+		return "", fmt.Errorf("the registry mirror is not running (start an agent session first)")
+	}
+	out, err := exec.Command(container.Cmd(rt), "exec", MirrorContainer(),
+		"/exitbox-proxy", "mirror-stats").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query registry mirror: %w", err)
+	}
+	var s proxy.MirrorStats
+	if err := json.Unmarshal(out, &s); err != nil {
+		return "", fmt.Errorf("invalid statistics from registry mirror: %w", err)
+	}
+	return formatCacheStats(s), nil
+}
+
+func formatCacheStats(s proxy.MirrorStats) string {
+	const mb = 1 << 20
+	hitRate := 0.0
+	if total := s.Hits + s.Misses; total > 0 {
+		hitRate = 100 * float64(s.Hits) / float64(total)
+	}
+	lines := []string{
+		fmt.Sprintf("Requests:     %d (%d hits, %d misses)", s.Hits+s.Misses, s.Hits, s.Misses),
+		fmt.Sprintf("Hit rate:     %.1f%%", hitRate),
+		fmt.Sprintf("Served:       %d MB from cache, %d MB from registries", s.HitBytes/mb, s.MissBytes/mb),
+		fmt.Sprintf("Cache size:   %d MB of %d MB (%d objects)", s.SizeBytes/mb, s.MaxBytes/mb, s.Entries),
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ClearCache removes the cache volume. The mirror must be stopped first
+// because it keeps its size accounting in memory.
+func ClearCache(rt container.Runtime) error {
+	if isContainerRunning(rt, MirrorContainer()) {
+		return fmt.Errorf("the registry mirror is running; end all agent sessions (or run 'exitbox clean containers') first")
+	}
+	cmd := container.Cmd(rt)
+	_ = exec.Command(cmd, "rm", "-f", MirrorContainer()).Run()
+	if err := exec.Command(cmd, "volume", "inspect", CacheVolume()).Run(); err != nil {
+		return nil // nothing cached yet
+	}
+	if out, err := exec.Command(cmd, "volume", "rm", CacheVolume()).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove cache volume: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestCacheSizeMB(t *testing.T) {
+	tests := []struct {
+		cache config.ProxyCache
+		want  int
+	}{
+		{config.ProxyCache{}, 0},
+		{config.ProxyCache{SizeMB: 512}, 0},
+		{config.ProxyCache{Enabled: true}, defaultCacheSizeMB},
+		{config.ProxyCache{Enabled: true, SizeMB: 512}, 512},
+	}
+	for _, tc := range tests {
+		cfg := &config.Config{Settings: config.SettingsConfig{ProxyCache: tc.cache}}
+		if got := cacheSizeMB(cfg); got != tc.want {
+			t.Errorf("cacheSizeMB(%+v) = %d, want %d", tc.cache, got, tc.want)
+		}
+	}
+}
+
+func TestMirrorEnv(t *testing.T) {
+	npm := []string{"NPM_CONFIG_REGISTRY=http://10.89.0.5:3129/npm/"}
+	pypi := []string{
+		"PIP_INDEX_URL=http://10.89.0.5:3129/pypi/simple/",
+		"PIP_TRUSTED_HOST=10.89.0.5",
+		"UV_DEFAULT_INDEX=http://10.89.0.5:3129/pypi/simple/",
+	}
+	goproxy := []string{"GOPROXY=http://10.89.0.5:3129/go,direct"}
+	cat := func(parts ...[]string) []string {
+		var out []string
+		for _, p := range parts {
+			out = append(out, p...)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		want    []string
+	}{
+		{"nothing allowed", []string{"github.com"}, nil, nil},
+		{"all allowed", []string{"registry.npmjs.org", "pypi.org", "files.pythonhosted.org", "proxy.golang.org"}, nil,
+			cat(npm, pypi, goproxy)},
+		{"wildcard", []string{".npmjs.org", ".golang.org"}, nil, cat(npm, goproxy)},
+		{"pypi needs both hosts", []string{"pypi.org"}, nil, nil},
+		{"denied", []string{"registry.npmjs.org", "proxy.golang.org"}, []string{".golang.org"}, npm},
+	}
+	for _, tc := range tests {
+		got := mirrorEnv("10.89.0.5:3129", tc.allowed, tc.denied)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: mirrorEnv = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/daemon"
+	"github.com/cloud-exit/exitbox/internal/flock"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// daemonEnabled reports whether firewall operations go through exitboxd.
+// It is off with settings.no_daemon or EXITBOX_NO_DAEMON set.
+func daemonEnabled() bool {
+	if os.Getenv("EXITBOX_NO_DAEMON") != "" {
+		return false
+	}
+	return !config.LoadOrDefault().Settings.NoDaemon
+}
+
+// coordinate sends req to exitboxd. Without the daemon, local runs the
+// operation in this process while holding the firewall lock.
+func coordinate(req daemon.Request, local func() error) error {
+	if daemonEnabled() {
+		_, err := daemon.Call(req)
+		if !errors.Is(err, daemon.ErrUnavailable) {
+			return err
+		}
+		ui.Warnf("Firewall daemon unavailable, continuing without it: %v", err)
+	}
+	return withFirewallLock(local)
+}
+
+// withFirewallLock runs fn while holding an exclusive lock on
+// firewall.lock, serialising proxy and config changes between processes.
+func withFirewallLock(fn func() error) error {
+	if err := os.MkdirAll(config.Cache, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(config.Cache, "firewall.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := flock.Lock(f); err != nil {
+		return err
+	}
+	defer func() { _ = flock.Unlock(f) }()
+	return fn()
+}
+
+// coordinator is the daemon side of the firewall operations. It also takes
+// the firewall lock so that sessions running without the daemon are
+// serialised with it.
+type coordinator struct {
+	rt container.Runtime
+}
+
+// NewCoordinator returns the firewall coordinator used by exitboxd.
+func NewCoordinator(rt container.Runtime) daemon.Coordinator {
+	return &coordinator{rt: rt}
+}
+
+func (c *coordinator) Acquire(containerName, agentNetwork string, urls []string, upstreamPassword string) error {
+	// The daemon outlives the exitbox run that unlocked the password and
+	// needs it for every Squid reconfigure.
+	if upstreamPassword != "" {
+		SetUpstreamProxyPassword(upstreamPassword)
+	}
+	return withFirewallLock(func() error {
+		return acquireSession(c.rt, containerName, agentNetwork, urls)
+	})
+}
+
+func (c *coordinator) Release(containerName string) error {
+	return withFirewallLock(func() error {
+		return releaseSession(c.rt, containerName)
+	})
+}
+
+func (c *coordinator) Allow(containerName, domain string) error {
+	return withFirewallLock(func() error {
+		return addSessionURL(c.rt, containerName, domain)
+	})
+}
+
+func (c *coordinator) Cleanup(active []string) error {
+	return withFirewallLock(func() error {
+		cleanupProxy(c.rt, active)
+		return nil
+	})
+}
+
+// Running errs on the side of keeping a session when the runtime cannot be
+// queried.
+func (c *coordinator) Running(containerName string) bool {
+	names, err := c.rt.PS("", "{{.Names}}")
+	if err != nil {
+		return true
+	}
+	for _, n := range names {
+		if n == containerName {
+			return true
+		}
+	}
+	return false
+}
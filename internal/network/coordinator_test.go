@@ -0,0 +1,127 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/daemon"
+	"github.com/cloud-exit/exitbox/internal/flock"
+)
+
+func TestCoordinate_LockFallback(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+	t.Setenv("EXITBOX_NO_DAEMON", "1")
+
+	ran := false
+	err := coordinate(daemon.Request{Op: daemon.OpCleanup}, func() error {
+		ran = true
+		f, err := os.Open(filepath.Join(config.Cache, "firewall.lock"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := flock.TryLock(f); !errors.Is(err, flock.ErrLocked) {
+			t.Errorf("firewall lock not held during operation: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("local operation did not run")
+	}
+	if _, err := os.Stat(daemon.SocketPath()); err == nil {
+		t.Error("daemon started although disabled")
+	}
+}
+
+// recordingCoordinator is a daemon.Coordinator that records Acquire calls.
+type recordingCoordinator struct {
+	acquired []string
+	password string
+}
+
+func (r *recordingCoordinator) Acquire(container, network string, urls []string, upstreamPassword string) error {
+	r.acquired = append(r.acquired, container+" "+network)
+	r.password = upstreamPassword
+	return nil
+}
+func (r *recordingCoordinator) Release(string) error       { return nil }
+func (r *recordingCoordinator) Allow(string, string) error { return nil }
+func (r *recordingCoordinator) Cleanup([]string) error     { return nil }
+func (r *recordingCoordinator) Running(string) bool        { return true }
+
+func TestStartProxy_DaemonGetsUpstreamPassword(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+	t.Setenv("EXITBOX_NO_DAEMON", "")
+
+	l, closeListener, err := daemon.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListener()
+	coord := &recordingCoordinator{}
+	go func() { _ = daemon.NewServer(coord).Serve(l) }()
+
+	SetUpstreamProxyPassword("s3cret")
+	defer SetUpstreamProxyPassword("")
+
+	if err := StartProxy(nil, "exitbox-a", "exitbox-int-a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"exitbox-a exitbox-int-a"}; !reflect.DeepEqual(coord.acquired, want) {
+		t.Errorf("acquired = %v, want %v", coord.acquired, want)
+	}
+	if coord.password != "s3cret" {
+		t.Errorf("daemon got upstream password %q, want the unlocked one", coord.password)
+	}
+}
+
+func TestRegisteredNetworks(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+
+	for name, network := range map[string]string{
+		"exitbox-new":     "exitbox-int-new",
+		"exitbox-running": "exitbox-int-running",
+		"exitbox-gone":    "exitbox-int-gone",
+	} {
+		if err := registerSessionNetwork(name, network); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * sessionStartupGrace)
+	for _, name := range []string{"exitbox-running", "exitbox-gone"} {
+		if err := os.Chtimes(filepath.Join(sessionDir(), name+".network"), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := registeredNetworks(map[string]bool{"exitbox-running": true}, time.Now())
+	want := []string{"exitbox-int-new", "exitbox-int-running"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("registeredNetworks = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir(), "exitbox-gone.network")); !os.IsNotExist(err) {
+		t.Error("registration of a session that never started was kept")
+	}
+}
@@ -19,42 +19,54 @@ package network
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/daemon"
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
-const (
-	InternalNetwork = "exitbox-int"
-	EgressNetwork   = "exitbox-egress"
-	SquidContainer  = "exitbox-squid"
-)
+// squidImage is the Squid image. Images are shared by all users of a
+// runtime; only running resources are namespaced.
+const squidImage = "exitbox-squid"
+
+// InternalNetwork is the prefix of this user's internal agent networks.
+func InternalNetwork() string { return config.Namespace() + "-int" }
+
+// EgressNetwork is the network through which this user's proxy reaches the
+// internet.
+func EgressNetwork() string { return config.Namespace() + "-egress" }
 
-// EnsureNetworks creates the shared networks if they don't exist.
-func EnsureNetworks(rt container.Runtime) {
-	if !rt.NetworkExists(InternalNetwork) {
-		ui.Infof("Creating internal network %s...", InternalNetwork)
-		if err := rt.NetworkCreate(InternalNetwork, true); err != nil {
-			ui.Warnf("Failed to create internal network: %v", err)
+// SquidContainer is the name of this user's Squid proxy container.
+func SquidContainer() string { return config.Namespace() + "-squid" }
+
+// EnsureNetworks creates the egress network and, if agentNetwork is not
+// empty, that internal agent network (see AgentNetwork). Only a failure to
+// create the agent network is returned: the session cannot start without it.
+func EnsureNetworks(rt container.Runtime, agentNetwork string) error {
+	if agentNetwork != "" && !rt.NetworkExists(agentNetwork) {
+		ui.Infof("Creating internal network %s...", agentNetwork)
+		if err := createAgentNetwork(rt, agentNetwork); err != nil {
+			return err
 		}
 	}
-	if !rt.NetworkExists(EgressNetwork) {
-		ui.Infof("Creating egress network %s...", EgressNetwork)
-		if err := rt.NetworkCreate(EgressNetwork, false); err != nil {
+	if !rt.NetworkExists(EgressNetwork()) {
+		ui.Infof("Creating egress network %s...", EgressNetwork())
+		if err := rt.NetworkCreate(EgressNetwork(), false, ""); err != nil {
 			ui.Warnf("Failed to create egress network: %v", err)
 		}
 	}
+	return nil
 }
 
 // GetNetworkSubnet returns the subnet for a network.
 func GetNetworkSubnet(rt container.Runtime, networkName string) (string, error) {
-	EnsureNetworks(rt)
-
 	out, err := rt.NetworkInspect(networkName, "")
 	if err != nil {
 		return "", err
@@ -103,37 +115,103 @@ func RegisterSessionURLs(containerName string, urls []string) error {
 	return os.WriteFile(filepath.Join(dir, containerName+".urls"), []byte(content), 0644)
 }
 
-// RemoveSessionURLs removes a container's session file and regenerates squid config.
+// RegisterSessionDeny writes a session file for a container's workspace
+// denylist. Squid has a single config shared by all sessions, so these
+// entries are enforced for every agent while the session is running.
+func RegisterSessionDeny(containerName string, deny []string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content := strings.Join(deny, "\n") + "\n"
+	return os.WriteFile(filepath.Join(dir, containerName+".deny"), []byte(content), 0644)
+}
+
+// RemoveSessionURLs ends a container's session: its session files are
+// removed and the firewall config regenerated.
 func RemoveSessionURLs(rt container.Runtime, containerName string) {
+	err := coordinate(daemon.Request{Op: daemon.OpRelease, Container: containerName}, func() error {
+		return releaseSession(rt, containerName)
+	})
+	if err != nil {
+		ui.Warnf("Failed to regenerate firewall config: %v", err)
+	}
+}
+
+func releaseSession(rt container.Runtime, containerName string) error {
 	dir := sessionDir()
-	_ = os.Remove(filepath.Join(dir, containerName+".urls"))
+	for _, suffix := range []string{".urls", ".deny", ".providers", ".ip", ".network"} {
+		_ = os.Remove(filepath.Join(dir, containerName+suffix))
+	}
 
 	// Collect remaining URLs from all sessions and regenerate config
-	remaining := collectAllSessionURLs()
-	if err := writeSquidConfig(rt, remaining); err != nil {
-		ui.Warnf("Failed to regenerate squid config: %v", err)
-		return
+	return reloadFirewall(rt, collectAllSessionURLs())
+}
+
+// sessionStartupGrace is how long a registered session may exist before its
+// container is expected to be running, as in exitboxd.
+const sessionStartupGrace = 2 * time.Minute
+
+// registerSessionNetwork records a session's agent network. Cleanup keeps
+// the network while the file exists, so another session ending cannot
+// remove it before this session's container has started.
+func registerSessionNetwork(containerName, agentNetwork string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
+	return os.WriteFile(filepath.Join(dir, containerName+".network"), []byte(agentNetwork+"\n"), 0644)
+}
 
-	// Reconfigure squid if running
-	cmd := container.Cmd(rt)
-	names, err := rt.PS("", "{{.Names}}")
+// registeredNetworks returns the networks of registered sessions whose
+// container is running or still within its startup grace. Registrations of
+// sessions that never started are dropped.
+func registeredNetworks(running map[string]bool, now time.Time) []string {
+	dir := sessionDir()
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		ui.Warnf("Failed to list containers: %v", err)
-		return
+		return nil
 	}
-	for _, n := range names {
-		if n == SquidContainer {
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
-			break
+	var networks []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".network")
+		if e.IsDir() || !ok {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if !running[name] && now.Sub(info.ModTime()) >= sessionStartupGrace {
+			_ = os.Remove(path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if n := strings.TrimSpace(string(data)); n != "" {
+			networks = append(networks, n)
 		}
 	}
+	return networks
 }
 
 // collectAllSessionURLs reads all session files and returns deduplicated URLs.
 func collectAllSessionURLs() []string {
+	return collectSessionEntries(".urls")
+}
+
+// collectAllSessionDeny reads all session denylist files and returns
+// deduplicated entries.
+func collectAllSessionDeny() []string {
+	return collectSessionEntries(".deny")
+}
+
+// collectSessionEntries reads every session file with the given suffix and
+// returns the deduplicated, non-empty lines.
+func collectSessionEntries(suffix string) []string {
 	dir := sessionDir()
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -142,7 +220,7 @@ func collectAllSessionURLs() []string {
 	seen := make(map[string]bool)
 	var urls []string
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".urls") {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), suffix) {
 			continue
 		}
 		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
@@ -160,6 +238,80 @@ func collectAllSessionURLs() []string {
 	return urls
 }
 
+// SessionAllowed returns the domains a container may reach right now: the
+// shared allowlist, its AI provider grant and the URLs added for the session
+// with --allow-urls or exitbox-allow.
+func SessionAllowed(containerName string) []string {
+	domains, _ := firewallDomains(config.LoadOrDefault(), config.LoadAllowlistOrDefault())
+	allowed := append([]string{}, domains...)
+	for _, suffix := range []string{".providers", ".urls"} {
+		data, err := os.ReadFile(filepath.Join(sessionDir(), containerName+suffix))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				allowed = append(allowed, line)
+			}
+		}
+	}
+	return allowed
+}
+
+// StartProxy registers a session on agentNetwork and starts the firewall
+// proxy selected by settings.firewall_backend.
+func StartProxy(rt container.Runtime, containerName, agentNetwork string, extraURLs []string) error {
+	req := daemon.Request{
+		Op:               daemon.OpAcquire,
+		Container:        containerName,
+		Network:          agentNetwork,
+		URLs:             extraURLs,
+		UpstreamPassword: getUpstreamProxyPassword(),
+	}
+	return coordinate(req, func() error {
+		return acquireSession(rt, containerName, agentNetwork, extraURLs)
+	})
+}
+
+// acquireSession registers a starting session, makes sure its network and
+// the proxies exist and assigns the session's address (see SessionIP). The
+// caller holds the firewall lock.
+func acquireSession(rt container.Runtime, containerName, agentNetwork string, extraURLs []string) error {
+	if agentNetwork == "" {
+		return startProxy(rt, containerName, extraURLs)
+	}
+	if err := EnsureNetworks(rt, agentNetwork); err != nil {
+		return err
+	}
+	if err := registerSessionNetwork(containerName, agentNetwork); err != nil {
+		return fmt.Errorf("failed to register session: %w", err)
+	}
+	if err := startProxy(rt, containerName, extraURLs); err != nil {
+		return err
+	}
+	if err := assignSessionIP(rt, containerName, agentNetwork); err != nil {
+		return fmt.Errorf("failed to assign session address: %w", err)
+	}
+	return nil
+}
+
+func startProxy(rt container.Runtime, containerName string, extraURLs []string) error {
+	var err error
+	if builtinBackend() {
+		err = startBuiltinProxy(rt, containerName, extraURLs)
+	} else {
+		err = StartSquidProxy(rt, containerName, extraURLs)
+	}
+	if err != nil {
+		return err
+	}
+	// The cache is an optimisation: sessions work without it.
+	if err := startMirror(rt, config.LoadOrDefault()); err != nil {
+		ui.Warnf("Proxy cache unavailable: %v", err)
+	}
+	return nil
+}
+
 // StartSquidProxy starts the Squid proxy container.
 func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []string) error {
 	cmd := container.Cmd(rt)
@@ -180,23 +332,24 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 		ui.Warnf("Failed to list containers: %v", err)
 	}
 	for _, n := range names {
-		if n == SquidContainer {
+		if n == SquidContainer() {
+			if err := attachProxy(rt, SquidContainer()); err != nil {
+				return err
+			}
 			// Regenerate config with all session URLs and reload
 			if err := writeSquidConfig(rt, allExtraURLs); err != nil {
 				return err
 			}
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
+			reconfigureSquid(rt)
 			return nil
 		}
 	}
 
 	// Remove if stopped
-	_ = rt.Remove(SquidContainer)
+	_ = rt.Remove(SquidContainer())
 
 	// Ensure networks
-	EnsureNetworks(rt)
+	EnsureNetworks(rt, "")
 
 	// Generate config
 	if err := writeSquidConfig(rt, allExtraURLs); err != nil {
@@ -207,11 +360,12 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 
 	runArgs := []string{
 		"run", "-d",
-		"--name", SquidContainer,
-		"--network", EgressNetwork,
+		"--name", SquidContainer(),
+		"--network", EgressNetwork(),
 		"-v", configFile + ":/etc/squid/squid.conf",
 		"--restart=unless-stopped",
 		"--add-host=host.docker.internal:host-gateway",
+		"--tmpfs", squidSecretsDir + ":mode=0750",
 	}
 
 	// DNS flags
@@ -220,7 +374,7 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 		runArgs = append(runArgs, "--dns", dns)
 	}
 
-	runArgs = append(runArgs, "exitbox-squid")
+	runArgs = append(runArgs, squidImage)
 
 	ui.Info("Starting Squid proxy...")
 	c := exec.Command(cmd, runArgs...)
@@ -228,75 +382,135 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 		return fmt.Errorf("failed to start Squid proxy: %w: %s", err, string(out))
 	}
 
-	// Connect to internal network
-	if err := rt.NetworkConnect(InternalNetwork, SquidContainer); err != nil {
-		_ = rt.Remove(SquidContainer)
-		return fmt.Errorf("failed to connect Squid to internal network: %w", err)
+	// Connect to the agent networks
+	if err := attachProxy(rt, SquidContainer()); err != nil {
+		_ = rt.Remove(SquidContainer())
+		return err
+	}
+
+	// A fresh container starts with an empty upstream peer file; push the
+	// credentials and reload once Squid is up.
+	if up := upstreamProxyFromConfig(); up != nil && up.NeedsPassword {
+		waitForSquid(rt)
+		reconfigureSquid(rt)
 	}
 
 	return nil
 }
 
 // GetProxyEnvVars returns proxy environment variable flags for container run.
-func GetProxyEnvVars(rt container.Runtime) []string {
-	cmd := container.Cmd(rt)
-
-	proxyHost := SquidContainer
+func GetProxyEnvVars(rt container.Runtime, agentNetwork string) []string {
+	proxyHost := SquidContainer()
+	if builtinBackend() {
+		proxyHost = ProxyContainer()
+	}
 	// Try to get IP
-	out, err := exec.Command(cmd, "inspect", SquidContainer,
-		"--format", fmt.Sprintf(`{{with index .NetworkSettings.Networks "%s"}}{{.IPAddress}}{{end}}`, InternalNetwork)).Output()
-	if err == nil && strings.TrimSpace(string(out)) != "" {
-		proxyHost = strings.TrimSpace(string(out))
+	if ip := containerIP(rt, proxyHost, agentNetwork); ip != "" {
+		proxyHost = ip
 	}
 
 	proxyURL := fmt.Sprintf("http://%s:3128", proxyHost)
-	return []string{
+	noProxy := "localhost,127.0.0.1,.local"
+
+	// Package managers talk to the registry mirror directly.
+	var mirror []string
+	if ip := containerIP(rt, MirrorContainer(), agentNetwork); ip != "" {
+		noProxy += "," + ip
+		al := config.LoadAllowlistOrDefault()
+		domains, _ := firewallDomains(config.LoadOrDefault(), al)
+		denied := append(append([]string{}, al.Deny...), collectAllSessionDeny()...)
+		mirror = mirrorEnv(net.JoinHostPort(ip, mirrorPort), domains, denied)
+	}
+
+	args := []string{
 		"-e", "http_proxy=" + proxyURL,
 		"-e", "https_proxy=" + proxyURL,
 		"-e", "HTTP_PROXY=" + proxyURL,
 		"-e", "HTTPS_PROXY=" + proxyURL,
-		"-e", "no_proxy=localhost,127.0.0.1,.local",
-		"-e", "NO_PROXY=localhost,127.0.0.1,.local",
+		"-e", "no_proxy=" + noProxy,
+		"-e", "NO_PROXY=" + noProxy,
+	}
+	for _, e := range mirror {
+		args = append(args, "-e", e)
 	}
+	return args
 }
 
-// CleanupSquidIfUnused stops squid if no agent containers are running.
+// CleanupSquidIfUnused stops the firewall proxy (Squid or builtin) if no
+// agent containers are running.
 func CleanupSquidIfUnused(rt container.Runtime) {
+	err := coordinate(daemon.Request{Op: daemon.OpCleanup}, func() error {
+		cleanupProxy(rt, nil)
+		return nil
+	})
+	if err != nil {
+		ui.Warnf("Failed to clean up firewall: %v", err)
+	}
+}
+
+// cleanupProxy stops the proxies once no agent container is running and no
+// session is starting on one of the active networks. Otherwise it removes
+// the networks of ended sessions.
+func cleanupProxy(rt container.Runtime, active []string) {
 	cmd := container.Cmd(rt)
 	names, err := rt.PS("", "{{.Names}}")
 	if err != nil {
 		ui.Warnf("Failed to list containers: %v", err)
 		return
 	}
+	// Only this user's containers count; other users on the same runtime
+	// have their own proxy.
+	ns := config.Namespace() + "-"
+	squid, builtin, mirror := SquidContainer(), ProxyContainer(), MirrorContainer()
 	running := 0
-	squidRunning := false
+	var proxies []string
+	isRunning := make(map[string]bool)
 	for _, n := range names {
-		if n == SquidContainer {
-			squidRunning = true
+		if n == squid || n == builtin || n == mirror {
+			proxies = append(proxies, n)
 			continue
 		}
-		if strings.HasPrefix(n, "exitbox-") {
+		if strings.HasPrefix(n, ns) {
 			running++
+			isRunning[n] = true
 		}
 	}
-	if running == 0 && squidRunning {
-		ui.Info("Stopping Squid proxy (no running agents)...")
-		// Stop first (handles restart policy), then remove.
-		_ = exec.Command(cmd, "stop", SquidContainer).Run()
-		if rmErr := exec.Command(cmd, "rm", "-f", SquidContainer).Run(); rmErr != nil {
-			ui.Warnf("Failed to remove Squid proxy: %v", rmErr)
+	// Sessions registered under the lock count as active until their
+	// container runs, whether or not exitboxd is in use.
+	active = append(append([]string{}, active...), registeredNetworks(isRunning, time.Now())...)
+	if running == 0 && len(active) == 0 {
+		for _, p := range proxies {
+			ui.Infof("Stopping %s (no running agents)...", p)
+			// Stop first (handles restart policy), then remove.
+			_ = exec.Command(cmd, "stop", p).Run()
+			if rmErr := exec.Command(cmd, "rm", "-f", p).Run(); rmErr != nil {
+				ui.Warnf("Failed to remove %s: %v", p, rmErr)
+			}
 		}
-	}
-	if running == 0 {
+		removeIdleAgentNetworks(rt, nil, nil)
 		// Clean stale session files
 		_ = os.RemoveAll(sessionDir())
+		return
+	}
+
+	// Other sessions are still running: drop the networks of ended ones and
+	// stop accepting clients from their subnets.
+	if removeIdleAgentNetworks(rt, proxies, active) > 0 {
+		if err := reloadFirewall(rt, collectAllSessionURLs()); err != nil {
+			ui.Warnf("Failed to regenerate firewall config: %v", err)
+		}
 	}
 }
 
 // AddSessionURLAndReload adds a domain to a container's session URLs and
-// hot-reloads Squid so the change takes effect immediately.
+// hot-reloads the proxy so the change takes effect immediately.
 func AddSessionURLAndReload(rt container.Runtime, containerName string, domain string) error {
-	cmd := container.Cmd(rt)
+	return coordinate(daemon.Request{Op: daemon.OpAllow, Container: containerName, Domain: domain}, func() error {
+		return addSessionURL(rt, containerName, domain)
+	})
+}
+
+func addSessionURL(rt container.Runtime, containerName string, domain string) error {
 	dir := sessionDir()
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
@@ -327,39 +541,46 @@ func AddSessionURLAndReload(rt container.Runtime, containerName string, domain s
 		return err
 	}
 
-	allURLs := collectAllSessionURLs()
-	if err := writeSquidConfig(rt, allURLs); err != nil {
-		return err
-	}
+	return reloadFirewall(rt, collectAllSessionURLs())
+}
 
-	// Hot-reload Squid.
-	names, psErr := rt.PS("", "{{.Names}}")
-	if psErr != nil {
-		ui.Warnf("Failed to list containers for squid reload: %v", psErr)
-		return nil
+// reloadFirewall regenerates the config of the selected firewall backend and
+// hot-reloads the proxy if it is running.
+func reloadFirewall(rt container.Runtime, extraURLs []string) error {
+	if builtinBackend() {
+		return reloadBuiltinProxy(rt, extraURLs)
 	}
-	for _, n := range names {
-		if n == SquidContainer {
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
-			break
-		}
+	if err := writeSquidConfig(rt, extraURLs); err != nil {
+		return err
+	}
+	if isContainerRunning(rt, SquidContainer()) {
+		reconfigureSquid(rt)
 	}
-
 	return nil
 }
 
 func writeSquidConfig(rt container.Runtime, extraURLs []string) error {
-	subnet, err := GetNetworkSubnet(rt, InternalNetwork)
+	subnets, err := agentSubnets(rt)
 	if err != nil {
-		return fmt.Errorf("could not detect internal network subnet: %w", err)
+		return err
 	}
 
+	cfg := config.LoadOrDefault()
 	al := config.LoadAllowlistOrDefault()
-	domains := al.AllDomains()
+	domains, grants := firewallDomains(cfg, al)
+	denied := append(append([]string{}, al.Deny...), collectAllSessionDeny()...)
+
+	upstream, err := ParseUpstreamProxy(cfg.Settings.UpstreamProxy)
+	if err != nil {
+		return err
+	}
+	opts := SquidOptions{
+		SNIEnforcement: cfg.Settings.SNIEnforcement,
+		UpstreamProxy:  upstream,
+		AgentGrants:    grants,
+	}
 
-	content := GenerateSquidConfig(subnet, domains, extraURLs)
+	content := GenerateSquidConfig(subnets, domains, extraURLs, denied, opts)
 	configFile := filepath.Join(config.Cache, "squid.conf")
 	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
@@ -367,6 +588,57 @@ func writeSquidConfig(rt container.Runtime, extraURLs []string) error {
 	return os.WriteFile(configFile, []byte(content), 0644)
 }
 
+// reconfigureSquid pushes upstream proxy credentials, if any, into the
+// running Squid container and hot-reloads its config.
+func reconfigureSquid(rt container.Runtime) {
+	if up := upstreamProxyFromConfig(); up != nil && up.NeedsPassword {
+		if err := pushUpstreamCredentials(rt, up); err != nil {
+			ui.Warnf("%v", err)
+		}
+	}
+	cmd := container.Cmd(rt)
+	if err := exec.Command(cmd, "exec", SquidContainer(), "squid", "-k", "reconfigure").Run(); err != nil {
+		ui.Warnf("Failed to reconfigure squid: %v", err)
+	}
+}
+
+// upstreamProxyFromConfig returns the configured upstream proxy, or nil if
+// none is set or the settings are invalid (writeSquidConfig reports those).
+func upstreamProxyFromConfig() *UpstreamProxy {
+	up, err := ParseUpstreamProxy(config.LoadOrDefault().Settings.UpstreamProxy)
+	if err != nil {
+		return nil
+	}
+	return up
+}
+
+// waitForSquid polls until Squid inside the container accepts control
+// commands, giving up after a few seconds.
+func waitForSquid(rt container.Runtime) {
+	cmd := container.Cmd(rt)
+	for i := 0; i < 20; i++ {
+		if exec.Command(cmd, "exec", SquidContainer(), "squid", "-k", "check").Run() == nil {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	ui.Warnf("Squid did not become ready in time")
+}
+
+// isContainerRunning reports whether the named container is running.
+func isContainerRunning(rt container.Runtime, name string) bool {
+	names, err := rt.PS("", "{{.Names}}")
+	if err != nil {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 func getSquidDNSServers() []string {
 	v := os.Getenv("EXITBOX_SQUID_DNS")
 	if v == "" {
@@ -0,0 +1,218 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// AgentGrant allows one agent container to reach its own AI provider
+// domains. Source is the container's address on the internal network.
+type AgentGrant struct {
+	Container string
+	Source    string
+	Domains   []string
+}
+
+// RegisterSessionProviders records the AI provider domains a container may
+// reach. They take effect once assignSessionIP has recorded its address.
+func RegisterSessionProviders(containerName string, domains []string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content := strings.Join(domains, "\n") + "\n"
+	return os.WriteFile(filepath.Join(dir, containerName+".providers"), []byte(content), 0644)
+}
+
+// SessionIP returns the address assigned to a session when its proxy was
+// acquired. The container must be started with it (--ip).
+func SessionIP(containerName string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(sessionDir(), containerName+".ip"))
+	if err != nil {
+		return "", fmt.Errorf("no address assigned to %s: %w", containerName, err)
+	}
+	ip := strings.TrimSpace(string(data))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid address assigned to %s: %q", containerName, ip)
+	}
+	return ip, nil
+}
+
+// assignSessionIP picks a free address on the session's agent network and
+// records it, so the provider grant is in the firewall before the container
+// starts. It runs after the proxies have joined the network. The caller
+// holds the firewall lock.
+func assignSessionIP(rt container.Runtime, containerName, agentNetwork string) error {
+	subnet, err := GetNetworkSubnet(rt, agentNetwork)
+	if err != nil {
+		return err
+	}
+	_, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %q for %s: %w", subnet, agentNetwork, err)
+	}
+
+	taken := make(map[string]bool)
+	members, err := rt.PS("network="+agentNetwork, "{{.Names}}")
+	if err != nil {
+		return fmt.Errorf("failed to list containers on %s: %w", agentNetwork, err)
+	}
+	for _, m := range members {
+		if ip := containerIP(rt, m, agentNetwork); ip != "" {
+			taken[ip] = true
+		}
+	}
+	// Sessions on a shared workspace network may hold an address whose
+	// container has not started yet.
+	for _, ip := range reservedIPs(agentNetwork) {
+		taken[ip] = true
+	}
+
+	ip := freeAddress(ipnet, taken)
+	if ip == nil {
+		return fmt.Errorf("no free address left on %s (%s)", agentNetwork, subnet)
+	}
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, containerName+".ip"), []byte(ip.String()+"\n"), 0644); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, containerName+".providers")); err == nil {
+		return reloadFirewall(rt, collectAllSessionURLs())
+	}
+	return nil
+}
+
+// reservedIPs returns the addresses recorded for sessions on agentNetwork.
+func reservedIPs(agentNetwork string) []string {
+	dir := sessionDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var ips []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".network")
+		if e.IsDir() || !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil || strings.TrimSpace(string(data)) != agentNetwork {
+			continue
+		}
+		if ip, err := os.ReadFile(filepath.Join(dir, name+".ip")); err == nil {
+			ips = append(ips, strings.TrimSpace(string(ip)))
+		}
+	}
+	return ips
+}
+
+// freeAddress returns the highest host address in subnet that is not
+// taken. The network address, the broadcast address and the first host
+// (the gateway) are never used. Counting down keeps clear of the runtime,
+// which hands out addresses from the bottom of the range.
+func freeAddress(subnet *net.IPNet, taken map[string]bool) net.IP {
+	base4 := subnet.IP.To4()
+	if base4 == nil {
+		return nil
+	}
+	ones, bits := subnet.Mask.Size()
+	size := uint32(1) << (bits - ones)
+	if size < 4 {
+		return nil
+	}
+	base := binary.BigEndian.Uint32(base4)
+	for off := size - 2; off >= 2; off-- {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+off)
+		if !taken[ip.String()] {
+			return ip
+		}
+	}
+	return nil
+}
+
+// containerIP returns a container's address on the given network, or "".
+func containerIP(rt container.Runtime, name, networkName string) string {
+	out, err := exec.Command(container.Cmd(rt), "inspect", name,
+		"--format", fmt.Sprintf(`{{with index .NetworkSettings.Networks "%s"}}{{.IPAddress}}{{end}}`, networkName)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// collectAgentGrants returns the grants of all sessions whose address is
+// known, sorted by container name.
+func collectAgentGrants() []AgentGrant {
+	dir := sessionDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var grants []AgentGrant
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".providers")
+		if e.IsDir() || !ok {
+			continue
+		}
+		ipData, err := os.ReadFile(filepath.Join(dir, name+".ip"))
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(string(ipData)))
+		if ip == nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		g := AgentGrant{Container: name, Source: ip.String()}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				g.Domains = append(g.Domains, line)
+			}
+		}
+		grants = append(grants, g)
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].Container < grants[j].Container })
+	return grants
+}
+
+// firewallDomains returns the allowlist domains shared by all agents and
+// the per-agent provider grants. With settings.shared_ai_providers every
+// agent gets the whole allowlist and there are no grants.
+func firewallDomains(cfg *config.Config, al *config.Allowlist) ([]string, []AgentGrant) {
+	if cfg.Settings.SharedAIProviders {
+		return al.AllDomains(), nil
+	}
+	return al.SharedDomains(), collectAgentGrants()
+}
@@ -9,6 +9,7 @@
 package network
 
 import (
+	"net"
 	"os"
 	"path/filepath"
 	"sort"
@@ -57,3 +58,136 @@ func TestSessionURLLifecycle(t *testing.T) {
 		t.Errorf("expected 0 URLs after cleanup, got %d", len(empty))
 	}
 }
+
+func TestSessionDenyLifecycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	origCache := config.Cache
+	config.Cache = tmpDir
+	defer func() { config.Cache = origCache }()
+
+	if err := RegisterSessionURLs("container-a", []string{"example.com"}); err != nil {
+		t.Fatalf("RegisterSessionURLs: %v", err)
+	}
+	if err := RegisterSessionDeny("container-a", []string{"gist.github.com"}); err != nil {
+		t.Fatalf("RegisterSessionDeny: %v", err)
+	}
+
+	deny := collectAllSessionDeny()
+	if len(deny) != 1 || deny[0] != "gist.github.com" {
+		t.Errorf("collectAllSessionDeny() = %v, want [gist.github.com]", deny)
+	}
+	// Deny files must not leak into the allowed session URLs.
+	urls := collectAllSessionURLs()
+	if len(urls) != 1 || urls[0] != "example.com" {
+		t.Errorf("collectAllSessionURLs() = %v, want [example.com]", urls)
+	}
+}
+
+func TestAgentGrants(t *testing.T) {
+	tmpDir := t.TempDir()
+	origCache := config.Cache
+	config.Cache = tmpDir
+	defer func() { config.Cache = origCache }()
+
+	if err := RegisterSessionProviders("exitbox-codex-app", []string{"openai.com", "chatgpt.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSessionProviders("exitbox-claude-app", []string{"anthropic.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// No address recorded yet: no grants, so provider access fails closed.
+	if grants := collectAgentGrants(); len(grants) != 0 {
+		t.Fatalf("expected no grants before addresses are known, got %+v", grants)
+	}
+
+	for name, ip := range map[string]string{"exitbox-codex-app": "10.89.0.6", "exitbox-claude-app": "10.89.0.5"} {
+		if err := os.WriteFile(filepath.Join(sessionDir(), name+".ip"), []byte(ip+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grants := collectAgentGrants()
+	if len(grants) != 2 {
+		t.Fatalf("expected 2 grants, got %+v", grants)
+	}
+	if grants[0].Container != "exitbox-claude-app" || grants[0].Source != "10.89.0.5" || grants[0].Domains[0] != "anthropic.com" {
+		t.Errorf("unexpected first grant: %+v", grants[0])
+	}
+	if grants[1].Source != "10.89.0.6" || len(grants[1].Domains) != 2 {
+		t.Errorf("unexpected second grant: %+v", grants[1])
+	}
+}
+
+func TestFirewallDomains(t *testing.T) {
+	tmpDir := t.TempDir()
+	origCache := config.Cache
+	config.Cache = tmpDir
+	defer func() { config.Cache = origCache }()
+
+	al := &config.Allowlist{
+		AIProviders:   []string{"anthropic.com", "openai.com", "googleapis.com"},
+		Development:   []string{"github.com"},
+		CloudServices: []string{"googleapis.com"},
+	}
+	cfg := config.DefaultConfig()
+
+	domains, _ := firewallDomains(cfg, al)
+	sort.Strings(domains)
+	if len(domains) != 2 || domains[0] != "github.com" || domains[1] != "googleapis.com" {
+		t.Errorf("restricted: got %v, want [github.com googleapis.com]", domains)
+	}
+
+	cfg.Settings.SharedAIProviders = true
+	domains, grants := firewallDomains(cfg, al)
+	if len(domains) != 4 || grants != nil {
+		t.Errorf("shared: got %v %v, want all 4 domains and no grants", domains, grants)
+	}
+}
+
+func TestFreeAddress(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.213.0.0/29")
+
+	if got := freeAddress(subnet, nil); got.String() != "10.213.0.6" {
+		t.Errorf("empty subnet: got %s, want the highest host", got)
+	}
+	taken := map[string]bool{"10.213.0.6": true, "10.213.0.5": true}
+	if got := freeAddress(subnet, taken); got.String() != "10.213.0.4" {
+		t.Errorf("got %s, want 10.213.0.4", got)
+	}
+	// .0 is the network, .1 the gateway and .7 the broadcast address.
+	for _, ip := range []string{"10.213.0.2", "10.213.0.3", "10.213.0.4"} {
+		taken[ip] = true
+	}
+	if got := freeAddress(subnet, taken); got != nil {
+		t.Errorf("full subnet: got %s, want nil", got)
+	}
+}
+
+func TestReservedIPsAndSessionIP(t *testing.T) {
+	origCache := config.Cache
+	config.Cache = t.TempDir()
+	defer func() { config.Cache = origCache }()
+
+	for name, network := range map[string]string{"exitbox-a": "ws", "exitbox-b": "ws", "exitbox-c": "other"} {
+		if err := registerSessionNetwork(name, network); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(sessionDir(), name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("exitbox-a.ip", "10.213.0.62\n")
+	write("exitbox-c.ip", "10.213.0.126\n")
+
+	if got := reservedIPs("ws"); len(got) != 1 || got[0] != "10.213.0.62" {
+		t.Errorf("reservedIPs(ws) = %v, want [10.213.0.62]", got)
+	}
+	if ip, err := SessionIP("exitbox-a"); err != nil || ip != "10.213.0.62" {
+		t.Errorf("SessionIP(exitbox-a) = %q, %v", ip, err)
+	}
+	if _, err := SessionIP("exitbox-b"); err == nil {
+		t.Error("SessionIP should fail for a session without an address")
+	}
+}
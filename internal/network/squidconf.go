@@ -18,18 +18,42 @@ package network
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
-// GenerateSquidConfig generates the squid.conf content.
-func GenerateSquidConfig(subnet string, domains []string, extraURLs []string) string {
+// SNICertFile is the certificate Squid needs on an ssl-bump port. It is
+// generated when the squid image is built and is never used to decrypt
+// traffic: connections are only peeked at and spliced.
+const SNICertFile = "/etc/squid/ssl/exitbox-sni.pem"
+
+// SquidOptions holds optional proxy features for GenerateSquidConfig.
+type SquidOptions struct {
+	// SNIEnforcement peeks at the TLS ClientHello of every CONNECT tunnel
+	// and only splices connections whose SNI matches the allowlist.
+	SNIEnforcement bool
+	// UpstreamProxy, when set, forwards all egress through a parent proxy.
+	UpstreamProxy *UpstreamProxy
+	// AgentGrants lets individual agent containers reach domains, usually
+	// their AI provider, that are not on the shared allowlist.
+	AgentGrants []AgentGrant
+}
+
+// GenerateSquidConfig generates the squid.conf content. Clients are accepted
+// from the agent network subnets; entries in denied are rejected before any
+// allow rule is evaluated.
+func GenerateSquidConfig(subnets []string, domains []string, extraURLs []string, denied []string, opts SquidOptions) string {
 	var b strings.Builder
 
-	b.WriteString(`# Squid Configuration for Agentbox
-http_port 3128
-shutdown_lifetime 1 seconds
+	b.WriteString("# Squid Configuration for Agentbox\n")
+	if opts.SNIEnforcement {
+		fmt.Fprintf(&b, "http_port 3128 ssl-bump cert=%s generate-host-certificates=off\n", SNICertFile)
+	} else {
+		b.WriteString("http_port 3128\n")
+	}
+	b.WriteString(`shutdown_lifetime 1 seconds
 
 # Access Control Lists
 acl SSL_ports port 443
@@ -52,12 +76,78 @@ http_access deny CONNECT !SSL_ports
 # Localhost access
 acl localhost src 127.0.0.1/32
 
-# Only allow proxy clients from the internal agent network
+# Only allow proxy clients from the internal agent networks
+`)
+	for _, subnet := range subnets {
+		fmt.Fprintf(&b, "acl agent_sources src %s\n", subnet)
+	}
+
+	deniedEntries := normalizeDenylist(denied)
+	if len(deniedEntries) > 0 {
+		b.WriteString("\n# Denylist\n")
+		for _, d := range deniedEntries {
+			fmt.Fprintf(&b, "acl denied_domains dstdomain %s\n", d)
+		}
+	}
+
+	b.WriteString("\n# Allowlist\n")
+
+	allowed := normalizeAllowlist(domains, extraURLs)
+	if len(allowed) == 0 {
+		ui.Warn("Allowlist is empty or invalid. Blocking all outbound destinations.")
+		allowed = append(allowed, ".__agentbox_block_all__.invalid")
+	}
+	for _, d := range allowed {
+		fmt.Fprintf(&b, "acl allowed_domains dstdomain %s\n", d)
+	}
+
+	grants := normalizeGrants(opts.AgentGrants)
+	for i, g := range grants {
+		fmt.Fprintf(&b, "\n# Providers for %s\n", g.Container)
+		fmt.Fprintf(&b, "acl agent%d_src src %s\n", i+1, g.Source)
+		for _, d := range g.Domains {
+			fmt.Fprintf(&b, "acl agent%d_domains dstdomain %s\n", i+1, d)
+		}
+	}
+
+	b.WriteString(`
+# Enforce Access Control
+# Only allow access from localhost and our network
+`)
+	if len(deniedEntries) > 0 {
+		b.WriteString("http_access deny denied_domains\n")
+	}
+	b.WriteString("http_access allow localhost\n")
+	for i := range grants {
+		fmt.Fprintf(&b, "http_access allow agent%d_src agent%d_domains\n", i+1, i+1)
+	}
+	b.WriteString(`http_access allow agent_sources allowed_domains
+
+# Deny everything else
+http_access deny all
 `)
-	fmt.Fprintf(&b, "acl agent_sources src %s\n\n# Allowlist\n", subnet)
 
+	if opts.SNIEnforcement {
+		writeSNIRules(&b, allowed, deniedEntries, grants)
+	}
+	if opts.UpstreamProxy != nil {
+		writeUpstreamRules(&b, opts.UpstreamProxy)
+	}
+
+	b.WriteString(`
+# Hide proxy info
+forwarded_for off
+via off
+`)
+
+	return b.String()
+}
+
+// normalizeAllowlist validates and deduplicates the allowlist and session
+// entries into dstdomain form.
+func normalizeAllowlist(domains, extraURLs []string) []string {
 	seen := make(map[string]bool)
-	count := 0
+	var allowed []string
 
 	for _, domain := range domains {
 		normalized, err := NormalizeAllowlistEntry(domain)
@@ -69,8 +159,7 @@ acl localhost src 127.0.0.1/32
 			continue
 		}
 		seen[normalized] = true
-		fmt.Fprintf(&b, "acl allowed_domains dstdomain %s\n", normalized)
-		count++
+		allowed = append(allowed, normalized)
 	}
 
 	// Extra URLs
@@ -87,28 +176,128 @@ acl localhost src 127.0.0.1/32
 			continue
 		}
 		seen[normalized] = true
-		fmt.Fprintf(&b, "acl allowed_domains dstdomain %s\n", normalized)
-		count++
+		allowed = append(allowed, normalized)
 	}
+	return allowed
+}
 
-	if count == 0 {
-		ui.Warn("Allowlist is empty or invalid. Blocking all outbound destinations.")
-		b.WriteString("acl allowed_domains dstdomain .__agentbox_block_all__.invalid\n")
+// writeSNIRules emits ssl_bump rules that read the SNI of each CONNECT
+// tunnel without decrypting it. Tunnels are spliced through only when the
+// SNI (or, without SNI, the CONNECT host) is allowed and not denied;
+// everything else, including non-TLS traffic, is terminated.
+func writeSNIRules(b *strings.Builder, allowed, denied []string, grants []AgentGrant) {
+	b.WriteString(`
+# TLS SNI enforcement (peek and splice only, no decryption)
+acl sni_step1 at_step SslBump1
+`)
+	for _, d := range allowed {
+		fmt.Fprintf(b, "acl allowed_sni ssl::server_name %s\n", d)
+	}
+	for _, d := range denied {
+		fmt.Fprintf(b, "acl denied_sni ssl::server_name %s\n", d)
+	}
+	for i, g := range grants {
+		for _, d := range g.Domains {
+			fmt.Fprintf(b, "acl agent%d_sni ssl::server_name %s\n", i+1, d)
+		}
 	}
+	b.WriteString("ssl_bump peek sni_step1\n")
+	if len(denied) > 0 {
+		b.WriteString("ssl_bump terminate denied_sni\n")
+	}
+	for i := range grants {
+		fmt.Fprintf(b, "ssl_bump splice agent%d_src agent%d_sni\n", i+1, i+1)
+	}
+	b.WriteString("ssl_bump splice allowed_sni\nssl_bump terminate all\n")
+}
 
-	b.WriteString(`
-# Enforce Access Control
-# Only allow access from localhost and our network
-http_access allow localhost
-http_access allow agent_sources allowed_domains
+// normalizeGrants validates grant sources and normalizes their domains.
+// Grants left without a valid source or domain are dropped.
+func normalizeGrants(grants []AgentGrant) []AgentGrant {
+	var result []AgentGrant
+	for _, g := range grants {
+		ip := net.ParseIP(g.Source)
+		if ip == nil {
+			ui.Warnf("Skipping provider grant for %s: invalid address %q", g.Container, g.Source)
+			continue
+		}
+		domains := normalizeAllowlist(g.Domains, nil)
+		if len(domains) == 0 {
+			continue
+		}
+		result = append(result, AgentGrant{Container: g.Container, Source: ip.String(), Domains: domains})
+	}
+	return result
+}
 
-# Deny everything else
-http_access deny all
+// writeUpstreamRules routes egress through the upstream proxy. Squid never
+// goes direct except for no_proxy destinations, so a missing or unreachable
+// parent fails closed. When the parent needs a password the cache_peer line
+// is included from the container's tmpfs instead of being written here.
+func writeUpstreamRules(b *strings.Builder, up *UpstreamProxy) {
+	b.WriteString("\n# Upstream proxy\n")
+	if up.NeedsPassword {
+		fmt.Fprintf(b, "include %s\n", UpstreamPeerFile)
+	} else {
+		b.WriteString(up.PeerLine("") + "\n")
+	}
 
-# Hide proxy info
-forwarded_for off
-via off
-`)
+	seen := make(map[string]bool)
+	for _, entry := range up.NoProxy {
+		normalized, err := NormalizeAllowlistEntry(entry)
+		if err != nil {
+			ui.Warnf("Skipping invalid upstream no_proxy entry: %s", entry)
+			continue
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		fmt.Fprintf(b, "acl upstream_bypass dstdomain %s\n", normalized)
+	}
+	if len(seen) > 0 {
+		b.WriteString("always_direct allow upstream_bypass\n")
+	}
+	b.WriteString("never_direct allow all\n")
+}
 
-	return b.String()
+// normalizeDenylist validates and deduplicates denylist entries. Exact hosts
+// already covered by a wildcard entry are dropped, since Squid warns about
+// overlapping dstdomain values in the same ACL.
+func normalizeDenylist(denied []string) []string {
+	var entries []string
+	seen := make(map[string]bool)
+	for _, entry := range denied {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		normalized, err := NormalizeDenylistEntry(entry)
+		if err != nil {
+			ui.Warnf("Skipping invalid denylist entry: %s", entry)
+			continue
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		entries = append(entries, normalized)
+	}
+
+	var result []string
+	for _, e := range entries {
+		covered := false
+		for _, other := range entries {
+			if other == e || !strings.HasPrefix(other, ".") {
+				continue
+			}
+			if strings.HasSuffix("."+strings.TrimPrefix(e, "."), other) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, e)
+		}
+	}
+	return result
 }
@@ -7,7 +7,7 @@ import (
 )
 
 func TestGenerateSquidConfig_BasicStructure(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil)
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, nil, SquidOptions{})
 
 	// Should contain core squid directives
 	required := []string{
@@ -28,17 +28,19 @@ func TestGenerateSquidConfig_BasicStructure(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_SubnetInACL(t *testing.T) {
-	subnet := "10.89.0.0/24"
-	conf := GenerateSquidConfig(subnet, []string{"example.com"}, nil)
+	subnets := []string{"10.89.0.0/24", "10.89.1.0/24"}
+	conf := GenerateSquidConfig(subnets, []string{"example.com"}, nil, nil, SquidOptions{})
 
-	if !strings.Contains(conf, "acl agent_sources src "+subnet) {
-		t.Error("config should contain agent_sources ACL with subnet")
+	for _, subnet := range subnets {
+		if !strings.Contains(conf, "acl agent_sources src "+subnet) {
+			t.Errorf("config should contain agent_sources ACL with subnet %s", subnet)
+		}
 	}
 }
 
 func TestGenerateSquidConfig_Domains(t *testing.T) {
 	domains := []string{"github.com", "npmjs.org"}
-	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil)
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, domains, nil, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "acl allowed_domains dstdomain .github.com") {
 		t.Error("config should contain .github.com domain ACL")
@@ -49,7 +51,7 @@ func TestGenerateSquidConfig_Domains(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_ExtraURLs(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"extra.io"})
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, []string{"extra.io"}, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "acl allowed_domains dstdomain .extra.io") {
 		t.Error("config should contain extra URL domain ACL")
@@ -58,7 +60,7 @@ func TestGenerateSquidConfig_ExtraURLs(t *testing.T) {
 
 func TestGenerateSquidConfig_Deduplication(t *testing.T) {
 	domains := []string{"example.com", "example.com", "example.com"}
-	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil)
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, domains, nil, nil, SquidOptions{})
 
 	count := strings.Count(conf, ".example.com")
 	if count != 1 {
@@ -67,7 +69,7 @@ func TestGenerateSquidConfig_Deduplication(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_DeduplicationAcrossLists(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"example.com"})
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, []string{"example.com"}, nil, SquidOptions{})
 
 	count := strings.Count(conf, ".example.com")
 	if count != 1 {
@@ -76,7 +78,7 @@ func TestGenerateSquidConfig_DeduplicationAcrossLists(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_EmptyAllowlist(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", nil, nil)
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, nil, nil, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "__agentbox_block_all__") {
 		t.Error("empty allowlist should produce block-all entry")
@@ -84,7 +86,7 @@ func TestGenerateSquidConfig_EmptyAllowlist(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_EmptyExtraURLsSkipped(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"", ""})
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, []string{"", ""}, nil, SquidOptions{})
 
 	// Should only have the one domain, empty strings skipped
 	count := strings.Count(conf, "acl allowed_domains dstdomain")
@@ -94,7 +96,7 @@ func TestGenerateSquidConfig_EmptyExtraURLsSkipped(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_AllowAccess(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil)
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "http_access allow agent_sources allowed_domains") {
 		t.Error("config should allow agent_sources with allowed_domains")
@@ -104,6 +106,167 @@ func TestGenerateSquidConfig_AllowAccess(t *testing.T) {
 	}
 }
 
+func TestGenerateSquidConfig_Denylist(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"github.com"}, nil, []string{"gist.github.com", "*.amazonaws.com"}, SquidOptions{})
+
+	if !strings.Contains(conf, "acl denied_domains dstdomain gist.github.com\n") {
+		t.Error("exact deny entry should not get a leading dot")
+	}
+	if !strings.Contains(conf, "acl denied_domains dstdomain .amazonaws.com\n") {
+		t.Error("wildcard deny entry should get a leading dot")
+	}
+
+	deny := strings.Index(conf, "http_access deny denied_domains")
+	allow := strings.Index(conf, "http_access allow agent_sources allowed_domains")
+	if deny < 0 {
+		t.Fatal("config should deny denied_domains")
+	}
+	if deny > allow {
+		t.Error("deny rule must come before the allow rules")
+	}
+}
+
+func TestGenerateSquidConfig_DenylistEmpty(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, []string{"", "not valid!"}, SquidOptions{})
+	if strings.Contains(conf, "denied_domains") {
+		t.Error("no denied_domains ACL should be emitted without valid entries")
+	}
+}
+
+func TestGenerateSquidConfig_DenylistOverlap(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, nil, nil, []string{"s3.amazonaws.com", "*.amazonaws.com", "amazonaws.com", "*.amazonaws.com"}, SquidOptions{})
+	if n := strings.Count(conf, "acl denied_domains dstdomain"); n != 1 {
+		t.Errorf("expected 1 denied_domains entry after collapsing overlaps, got %d", n)
+	}
+}
+
+func TestGenerateSquidConfig_SNIDisabledByDefault(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, nil, SquidOptions{})
+	if strings.Contains(conf, "ssl_bump") || strings.Contains(conf, "ssl-bump") {
+		t.Error("ssl_bump rules should only be emitted in SNI mode")
+	}
+	if !strings.Contains(conf, "http_port 3128\n") {
+		t.Error("plain http_port expected without SNI mode")
+	}
+}
+
+func TestGenerateSquidConfig_SNIEnforcement(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, []string{"extra.io"},
+		[]string{"gist.example.com"}, SquidOptions{SNIEnforcement: true})
+
+	required := []string{
+		"http_port 3128 ssl-bump cert=" + SNICertFile + " generate-host-certificates=off",
+		"acl allowed_sni ssl::server_name .example.com",
+		"acl allowed_sni ssl::server_name .extra.io",
+		"acl denied_sni ssl::server_name gist.example.com",
+		"ssl_bump peek sni_step1",
+		"ssl_bump terminate denied_sni",
+		"ssl_bump splice allowed_sni",
+		"ssl_bump terminate all",
+	}
+	for _, r := range required {
+		if !strings.Contains(conf, r) {
+			t.Errorf("SNI config missing %q", r)
+		}
+	}
+	if strings.Contains(conf, "ssl_bump bump") || strings.Contains(conf, "ssl_bump stare") {
+		t.Error("SNI mode must never decrypt traffic")
+	}
+
+	peek := strings.Index(conf, "ssl_bump peek sni_step1")
+	deny := strings.Index(conf, "ssl_bump terminate denied_sni")
+	splice := strings.Index(conf, "ssl_bump splice allowed_sni")
+	if !(peek < deny && deny < splice) {
+		t.Error("ssl_bump rules out of order: peek, terminate denied, splice allowed expected")
+	}
+}
+
+func TestGenerateSquidConfig_AgentGrants(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"github.com"}, nil, []string{"console.anthropic.com"}, SquidOptions{
+		SNIEnforcement: true,
+		AgentGrants: []AgentGrant{
+			{Container: "exitbox-claude-app", Source: "10.89.0.5", Domains: []string{"anthropic.com", "claude.ai"}},
+			{Container: "bogus", Source: "not-an-ip", Domains: []string{"openai.com"}},
+		},
+	})
+
+	required := []string{
+		"# Providers for exitbox-claude-app",
+		"acl agent1_src src 10.89.0.5",
+		"acl agent1_domains dstdomain .anthropic.com",
+		"acl agent1_domains dstdomain .claude.ai",
+		"http_access allow agent1_src agent1_domains",
+		"acl agent1_sni ssl::server_name .anthropic.com",
+		"ssl_bump splice agent1_src agent1_sni",
+	}
+	for _, r := range required {
+		if !strings.Contains(conf, r) {
+			t.Errorf("config missing %q", r)
+		}
+	}
+	if strings.Contains(conf, "openai.com") || strings.Contains(conf, "agent2") {
+		t.Error("grant with an invalid source should be dropped")
+	}
+
+	deny := strings.Index(conf, "http_access deny denied_domains")
+	grant := strings.Index(conf, "http_access allow agent1_src agent1_domains")
+	shared := strings.Index(conf, "http_access allow agent_sources allowed_domains")
+	if !(deny < grant && grant < shared) {
+		t.Error("grant rules must come after the denylist")
+	}
+	if strings.Index(conf, "ssl_bump terminate denied_sni") > strings.Index(conf, "ssl_bump splice agent1_src") {
+		t.Error("SNI grant rules must come after the SNI denylist")
+	}
+}
+
+func TestGenerateSquidConfig_SNIEmptyAllowlist(t *testing.T) {
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, nil, nil, nil, SquidOptions{SNIEnforcement: true})
+	if !strings.Contains(conf, "acl allowed_sni ssl::server_name .__agentbox_block_all__.invalid") {
+		t.Error("SNI mode should fail closed with an empty allowlist")
+	}
+	if strings.Contains(conf, "denied_sni") {
+		t.Error("no denied_sni rules expected without a denylist")
+	}
+}
+
+func TestGenerateSquidConfig_UpstreamProxy(t *testing.T) {
+	up := &UpstreamProxy{Host: "proxy.corp", Port: 8080, NoProxy: []string{"intranet.corp", "bad entry!"}}
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, nil, SquidOptions{UpstreamProxy: up})
+
+	required := []string{
+		"cache_peer proxy.corp parent 8080 0 no-query no-digest default name=upstream",
+		"acl upstream_bypass dstdomain .intranet.corp",
+		"always_direct allow upstream_bypass",
+		"never_direct allow all",
+	}
+	for _, r := range required {
+		if !strings.Contains(conf, r) {
+			t.Errorf("upstream config missing %q", r)
+		}
+	}
+	if strings.Contains(conf, "include") {
+		t.Error("no include expected without a vault password")
+	}
+}
+
+func TestGenerateSquidConfig_UpstreamProxyPasswordNotInConfig(t *testing.T) {
+	up := &UpstreamProxy{Host: "proxy.corp", Port: 8080, Username: "alice", NeedsPassword: true}
+	conf := GenerateSquidConfig([]string{"10.89.0.0/24"}, []string{"example.com"}, nil, nil, SquidOptions{UpstreamProxy: up})
+
+	if !strings.Contains(conf, "include "+UpstreamPeerFile) {
+		t.Error("peer with password should be included from the secrets tmpfs")
+	}
+	if strings.Contains(conf, "cache_peer") || strings.Contains(conf, "login=") {
+		t.Error("credentials must not be written to squid.conf")
+	}
+	if strings.Contains(conf, "always_direct") {
+		t.Error("always_direct should only be emitted with no_proxy entries")
+	}
+	if !strings.Contains(conf, "never_direct allow all") {
+		t.Error("egress must never go direct")
+	}
+}
+
 func TestGetSquidDNSServers_Default(t *testing.T) {
 	os.Unsetenv("EXITBOX_SQUID_DNS")
 	servers := getSquidDNSServers()
@@ -149,3 +312,26 @@ func TestSessionURLFileRoundtrip(t *testing.T) {
 		t.Errorf("expected 1 session file, got %d", len(entries))
 	}
 }
+
+func TestBuildProxyACL(t *testing.T) {
+	acl := BuildProxyACL([]string{"10.89.0.0/24"},
+		[]string{"github.com", "*.npmjs.org", "github.com"},
+		[]string{"pypi.org"},
+		[]string{"gist.github.com", "*.evil.example", "a.evil.example"},
+		[]AgentGrant{{Container: "c1", Source: "10.89.0.5", Domains: []string{"anthropic.com"}}})
+
+	if len(acl.Sources) != 1 || acl.Sources[0] != "10.89.0.0/24" {
+		t.Errorf("Sources = %v", acl.Sources)
+	}
+	wantAllow := []string{".github.com", ".npmjs.org", ".pypi.org"}
+	if strings.Join(acl.Allow, ",") != strings.Join(wantAllow, ",") {
+		t.Errorf("Allow = %v, want %v", acl.Allow, wantAllow)
+	}
+	wantDeny := []string{"gist.github.com", ".evil.example"}
+	if strings.Join(acl.Deny, ",") != strings.Join(wantDeny, ",") {
+		t.Errorf("Deny = %v, want %v", acl.Deny, wantDeny)
+	}
+	if len(acl.Grants) != 1 || acl.Grants[0].Source != "10.89.0.5" || acl.Grants[0].Allow[0] != ".anthropic.com" {
+		t.Errorf("Grants = %+v", acl.Grants)
+	}
+}
@@ -0,0 +1,146 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+const (
+	// squidSecretsDir is a tmpfs inside the Squid container. Upstream proxy
+	// credentials are written there over exec and never touch the host disk.
+	squidSecretsDir = "/run/exitbox-secrets"
+	// UpstreamPeerFile is included from squid.conf when the upstream proxy
+	// needs a password. It is empty until credentials have been pushed.
+	UpstreamPeerFile = squidSecretsDir + "/upstream.conf"
+)
+
+// UpstreamProxy is a parsed settings.upstream_proxy entry.
+type UpstreamProxy struct {
+	Host     string
+	Port     int
+	Username string
+	NoProxy  []string
+	// NeedsPassword is set when the password comes from the vault. The
+	// cache_peer line is then kept out of squid.conf (see UpstreamPeerFile).
+	NeedsPassword bool
+}
+
+var (
+	upstreamMu       sync.Mutex
+	upstreamPassword string
+)
+
+// SetUpstreamProxyPassword stores the upstream proxy password for this
+// process. It is only held in memory and pushed into the Squid container's
+// tmpfs whenever the proxy is (re)configured.
+func SetUpstreamProxyPassword(password string) {
+	upstreamMu.Lock()
+	defer upstreamMu.Unlock()
+	upstreamPassword = password
+}
+
+func getUpstreamProxyPassword() string {
+	upstreamMu.Lock()
+	defer upstreamMu.Unlock()
+	return upstreamPassword
+}
+
+// ParseUpstreamProxy validates the upstream proxy settings. It returns nil
+// when no upstream proxy is configured.
+func ParseUpstreamProxy(cfg config.UpstreamProxy) (*UpstreamProxy, error) {
+	raw := strings.TrimSpace(cfg.URL)
+	if raw == "" {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream proxy URL: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported upstream proxy scheme %q (only http is supported)", u.Scheme)
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return nil, fmt.Errorf("upstream proxy URL must not contain a password; use password_vault_key")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("upstream proxy URL has no host")
+	}
+	port := 3128
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid upstream proxy port %q", p)
+		}
+	}
+
+	up := &UpstreamProxy{
+		Host:          host,
+		Port:          port,
+		Username:      u.User.Username(),
+		NoProxy:       cfg.NoProxy,
+		NeedsPassword: cfg.PasswordVaultKey != "",
+	}
+	if up.NeedsPassword && up.Username == "" {
+		return nil, fmt.Errorf("upstream proxy URL needs a user name when password_vault_key is set")
+	}
+	return up, nil
+}
+
+// PeerLine returns the cache_peer directive for the upstream proxy.
+func (u *UpstreamProxy) PeerLine(password string) string {
+	line := fmt.Sprintf("cache_peer %s parent %d 0 no-query no-digest default name=upstream", u.Host, u.Port)
+	if u.Username != "" {
+		line += " login=" + squidLoginEscape(u.Username) + ":" + squidLoginEscape(password)
+	}
+	return line
+}
+
+// squidLoginEscape URL-escapes a cache_peer login component so that
+// whitespace, colons and percent signs survive squid.conf parsing.
+func squidLoginEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// pushUpstreamCredentials writes the password-bearing cache_peer line into
+// the running Squid container's tmpfs. Squid picks it up on the next
+// reconfigure.
+func pushUpstreamCredentials(rt container.Runtime, up *UpstreamProxy) error {
+	password := getUpstreamProxyPassword()
+	if password == "" {
+		return fmt.Errorf("upstream proxy password has not been unlocked from the vault")
+	}
+	script := fmt.Sprintf("umask 027 && cat > %[1]s.tmp && chgrp squid %[1]s.tmp && mv %[1]s.tmp %[1]s", UpstreamPeerFile)
+	c := exec.Command(container.Cmd(rt), "exec", "-i", SquidContainer(), "sh", "-c", script)
+	c.Stdin = strings.NewReader(up.PeerLine(password) + "\n")
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to push upstream proxy credentials: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package policy decides IPC requests from declarative rules before the
+// user is asked. Rules live in policy.yaml files on the host, outside
+// anything mounted into the container, so an agent cannot grant itself
+// access.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what a rule does with a matching request.
+type Action string
+
+// Rule actions.
+const (
+	Allow  Action = "allow"
+	Deny   Action = "deny"
+	Prompt Action = "prompt"
+)
+
+// Rule matches requests and decides them. Type is required; other empty
+// fields match anything.
+type Rule struct {
+	Name    string   `yaml:"name,omitempty"`
+	Type    string   `yaml:"type"`              // glob over the message type, e.g. vault_*
+	Match   string   `yaml:"match,omitempty"`   // glob over the domain or vault key
+	Agent   string   `yaml:"agent,omitempty"`   // glob over the agent name
+	Project string   `yaml:"project,omitempty"` // glob over the project path or a parent
+	Time    string   `yaml:"time,omitempty"`    // local time window, "09:00-18:00"
+	Days    []string `yaml:"days,omitempty"`    // mon, tue, ... sun
+	Action  Action   `yaml:"action"`
+}
+
+// File is the contents of a policy.yaml.
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Request is what a rule is matched against.
+type Request struct {
+	Type    string // IPC message type: allow_domain, vault_get, ...
+	Target  string // domain or vault key
+	Agent   string
+	Project string // host project directory
+	Time    time.Time
+}
+
+// Decision is the outcome of evaluating a request.
+type Decision struct {
+	Action Action
+	Rule   string // name of the rule that fired, empty if none did
+	Source string // scope of the file holding the rule: project, workspace or global
+}
+
+func (d Decision) String() string {
+	if d.Rule == "" {
+		return string(d.Action) + " (no matching rule)"
+	}
+	return fmt.Sprintf("%s (rule %q, %s policy)", d.Action, d.Rule, d.Source)
+}
+
+// GlobalFile returns the path to the global policy.yaml.
+func GlobalFile() string {
+	return filepath.Join(config.Home, "policy.yaml")
+}
+
+// WorkspaceFile returns the path to a workspace's policy.yaml.
+func WorkspaceFile(workspace string) string {
+	return filepath.Join(config.Home, "profiles", "global", workspace, "policy.yaml")
+}
+
+// ProjectFile returns the path to a project's policy.yaml.
+func ProjectFile(projectDir string) string {
+	return filepath.Join(project.ParentDir(projectDir), "policy.yaml")
+}
+
+// Engine evaluates requests from one session. Policy files are read on
+// every evaluation so edits apply without restarting the session.
+type Engine struct {
+	Workspace  string
+	ProjectDir string
+	Agent      string
+}
+
+type source struct {
+	scope string
+	path  string
+}
+
+func (e *Engine) sources() []source {
+	var s []source
+	if e.ProjectDir != "" {
+		s = append(s, source{"project", ProjectFile(e.ProjectDir)})
+	}
+	if e.Workspace != "" {
+		s = append(s, source{"workspace", WorkspaceFile(e.Workspace)})
+	}
+	return append(s, source{"global", GlobalFile()})
+}
+
+// Decide evaluates a request of msgType for target. Project rules are
+// checked first, then workspace, then global; the first matching rule
+// wins. Without a match the request is prompted. A policy file that
+// cannot be read makes Decide return Prompt along with the error.
+func (e *Engine) Decide(msgType, target string) (Decision, error) {
+	req := Request{
+		Type:    msgType,
+		Target:  target,
+		Agent:   e.Agent,
+		Project: e.ProjectDir,
+		Time:    time.Now(),
+	}
+	return e.Evaluate(req)
+}
+
+// Evaluate is Decide for a fully specified request.
+func (e *Engine) Evaluate(req Request) (Decision, error) {
+	for _, src := range e.sources() {
+		f, err := Load(src.path)
+		if err != nil {
+			return Decision{Action: Prompt}, err
+		}
+		if f == nil {
+			continue
+		}
+		for i, r := range f.Rules {
+			if r.Matches(req) {
+				name := r.Name
+				if name == "" {
+					name = fmt.Sprintf("#%d", i+1)
+				}
+				return Decision{Action: r.Action, Rule: name, Source: src.scope}, nil
+			}
+		}
+	}
+	return Decision{Action: Prompt}, nil
+}
+
+// Load reads and validates a policy file. A missing file returns nil.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, r := range f.Rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return &f, nil
+}
+
+func (r Rule) validate() error {
+	switch r.Action {
+	case Allow, Deny, Prompt:
+	default:
+		return fmt.Errorf("action must be allow, deny or prompt, got %q", r.Action)
+	}
+	// Without a type a rule would decide every kind of request, e.g. an
+	// allow meant for domains would also hand out vault secrets.
+	if r.Type == "" {
+		return errors.New("type is required, e.g. allow_domain or vault_* (use * for every request type)")
+	}
+	for _, g := range []string{r.Type, r.Match, r.Agent, r.Project} {
+		if _, err := path.Match(g, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", g)
+		}
+	}
+	if r.Time != "" {
+		if _, _, err := parseWindow(r.Time); err != nil {
+			return err
+		}
+	}
+	for _, d := range r.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q", d)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether every field of r matches req. A rule without a
+// type matches nothing.
+func (r Rule) Matches(req Request) bool {
+	if r.Type == "" || !glob(r.Type, req.Type) || !glob(r.Match, req.Target) || !glob(r.Agent, req.Agent) {
+		return false
+	}
+	if r.Project != "" && !matchProject(expandHome(r.Project), req.Project) {
+		return false
+	}
+	if len(r.Days) > 0 && !matchDay(r.Days, req.Time.Weekday()) {
+		return false
+	}
+	if r.Time != "" {
+		from, to, err := parseWindow(r.Time)
+		if err != nil {
+			return false
+		}
+		m := req.Time.Hour()*60 + req.Time.Minute()
+		if from <= to {
+			return m >= from && m < to
+		}
+		return m >= from || m < to // window crosses midnight
+	}
+	return true
+}
+
+func glob(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+// matchProject matches dir or any of its parents, so "~/work/*" covers
+// every project below ~/work.
+func matchProject(pattern, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	dir = filepath.Clean(dir)
+	for {
+		if ok, _ := path.Match(pattern, filepath.ToSlash(dir)); ok {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.ToSlash(home) + p[1:]
+		}
+	}
+	return p
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func matchDay(days []string, wd time.Weekday) bool {
+	for _, d := range days {
+		if weekdays[strings.ToLower(d)] == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWindow parses "HH:MM-HH:MM" into minutes since midnight.
+func parseWindow(s string) (from, to int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("time window must be HH:MM-HH:MM, got %q", s)
+	}
+	if from, err = parseClock(a); err != nil {
+		return 0, 0, err
+	}
+	if to, err = parseClock(b); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q, want HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,138 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func writePolicy(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	projectDir := "/home/me/work/api"
+	writePolicy(t, GlobalFile(), `
+rules:
+  - name: no-pastebins
+    type: allow_domain
+    match: "*pastebin.com"
+    action: deny
+  - name: office-hours-token
+    type: vault_get
+    match: GITHUB_TOKEN
+    time: "09:00-18:00"
+    days: [mon, tue, wed, thu, fri]
+    action: allow
+  - name: work-projects
+    type: allow_domain
+    match: "*.internal.example.com"
+    project: /home/me/work/*
+    action: allow
+`)
+	writePolicy(t, WorkspaceFile("work"), `
+rules:
+  - type: vault_*
+    agent: codex
+    action: deny
+`)
+	writePolicy(t, ProjectFile(projectDir), `
+rules:
+  - name: project-npm
+    type: allow_domain
+    match: registry.npmjs.org
+    action: allow
+`)
+
+	e := &Engine{Workspace: "work", ProjectDir: projectDir}
+	monday := time.Date(2026, 3, 2, 10, 30, 0, 0, time.Local)
+	saturday := time.Date(2026, 3, 7, 10, 30, 0, 0, time.Local)
+	tests := []struct {
+		req  Request
+		want Decision
+	}{
+		{Request{Type: "allow_domain", Target: "registry.npmjs.org"}, Decision{Allow, "project-npm", "project"}},
+		{Request{Type: "allow_domain", Target: "www.pastebin.com"}, Decision{Deny, "no-pastebins", "global"}},
+		{Request{Type: "allow_domain", Target: "example.org"}, Decision{Action: Prompt}},
+		{Request{Type: "vault_get", Target: "GITHUB_TOKEN", Agent: "codex", Time: monday}, Decision{Deny, "#1", "workspace"}},
+		{Request{Type: "vault_get", Target: "GITHUB_TOKEN", Agent: "claude", Time: monday}, Decision{Allow, "office-hours-token", "global"}},
+		{Request{Type: "vault_get", Target: "GITHUB_TOKEN", Agent: "claude", Time: saturday}, Decision{Action: Prompt}},
+		{Request{Type: "vault_get", Target: "GITHUB_TOKEN", Agent: "claude", Time: monday.Add(8 * time.Hour)}, Decision{Action: Prompt}},
+		{Request{Type: "allow_domain", Target: "ci.internal.example.com", Project: projectDir}, Decision{Allow, "work-projects", "global"}},
+		{Request{Type: "allow_domain", Target: "ci.internal.example.com", Project: "/home/me/oss"}, Decision{Action: Prompt}},
+	}
+	for _, tt := range tests {
+		got, err := e.Evaluate(tt.req)
+		if err != nil {
+			t.Fatalf("Evaluate(%+v): %v", tt.req, err)
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%s %s agent=%q) = %+v, want %+v", tt.req.Type, tt.req.Target, tt.req.Agent, got, tt.want)
+		}
+	}
+}
+
+func TestEngineInvalidPolicyPrompts(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	writePolicy(t, GlobalFile(), "rules:\n  - type: allow_domain\n    action: yes\n")
+	d, err := (&Engine{}).Decide("allow_domain", "example.com")
+	if err == nil || !strings.Contains(err.Error(), "action must be") {
+		t.Errorf("err = %v, want invalid action", err)
+	}
+	if d.Action != Prompt {
+		t.Errorf("action = %s, want prompt", d.Action)
+	}
+}
+
+func TestRuleRequiresType(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	writePolicy(t, GlobalFile(), "rules:\n  - match: \"*\"\n    action: allow\n")
+	d, err := (&Engine{}).Decide("vault_get", "GITHUB_TOKEN")
+	if err == nil || !strings.Contains(err.Error(), "type is required") {
+		t.Errorf("err = %v, want missing type", err)
+	}
+	if d.Action != Prompt {
+		t.Errorf("action = %s, want prompt", d.Action)
+	}
+	if (Rule{Action: Allow}).Matches(Request{Type: "vault_get"}) {
+		t.Error("a rule without a type should match nothing")
+	}
+}
+
+func TestRuleTimeWindowAcrossMidnight(t *testing.T) {
+	r := Rule{Type: "allow_domain", Time: "22:00-06:00", Action: Allow}
+	for hour, want := range map[int]bool{23: true, 3: true, 6: false, 12: false, 22: true} {
+		req := Request{Type: "allow_domain", Time: time.Date(2026, 1, 1, hour, 0, 0, 0, time.Local)}
+		if got := r.Matches(req); got != want {
+			t.Errorf("%02d:00: Matches = %v, want %v", hour, got, want)
+		}
+	}
+}
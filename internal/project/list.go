@@ -60,7 +60,7 @@ func ListAll(rt container.Runtime) {
 		// Check which agents have images (any profile variant)
 		var agentsList []string
 		for _, agent := range []string{"claude", "codex", "opencode"} {
-			prefix := fmt.Sprintf("exitbox-%s-%s-*", agent, e.Name())
+			prefix := fmt.Sprintf("%s-%s-%s-*", config.Namespace(), agent, e.Name())
 			if rt != nil {
 				if imgs, err := rt.ImageList(prefix); err == nil && len(imgs) > 0 {
 					agentsList = append(agentsList, agent)
@@ -81,19 +81,39 @@ func Init(path string) error {
 	return nil
 }
 
-// ImageName returns the Docker image name for an agent in a project.
+// ImageName returns the Docker image name for an agent in a project,
+// under the current user's namespace (see config.Namespace).
 // profileHash must encode the active profile configuration so that each
 // profile produces a distinct image (no cache sharing between profiles).
 func ImageName(agent, projectDir, profileHash string) string {
-	return fmt.Sprintf("exitbox-%s-%s-%s", agent, GenerateFolderName(projectDir), profileHash)
+	return fmt.Sprintf("%s-%s-%s-%s", config.Namespace(), agent, GenerateFolderName(projectDir), profileHash)
 }
 
-// ContainerName returns a unique container name for an agent in a project.
+// CoreImageName returns the name of an agent's core image. Core and tools
+// images are namespaced like project images: they carry the user's CA
+// certificates and tools.
+func CoreImageName(agent string) string {
+	return config.Namespace() + "-" + agent + "-core"
+}
+
+// ToolsImageName returns the name of an agent's tools image.
+func ToolsImageName(agent string) string {
+	return config.Namespace() + "-" + agent + "-tools"
+}
+
+// ContainerName returns a unique container name for an agent in a project,
+// under the current user's namespace (see config.Namespace).
 func ContainerName(agent, projectDir string) string {
 	folder := GenerateFolderName(projectDir)
 	// Random suffix from crypto/rand
 	suffix := randomHex(4)
-	return fmt.Sprintf("exitbox-%s-%s-%s", agent, folder, suffix)
+	return fmt.Sprintf("%s-%s-%s-%s", config.Namespace(), agent, folder, suffix)
+}
+
+// IsOwnContainer reports whether a container name belongs to the current
+// user's namespace.
+func IsOwnContainer(name string) bool {
+	return strings.HasPrefix(name, config.Namespace()+"-")
 }
 
 func randomHex(n int) string {
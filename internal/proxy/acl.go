@@ -0,0 +1,189 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package proxy implements exitbox-proxy, the built-in egress firewall.
+// It is a forward HTTP proxy with the same allow/deny semantics as the
+// generated Squid config. This package only depends on the standard library
+// so the static binary stays small.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ACL is the access policy pushed by the host. Domain entries use Squid
+// dstdomain syntax as produced by network.NormalizeAllowlistEntry and
+// network.NormalizeDenylistEntry: ".example.com" matches the domain and its
+// subdomains, anything else matches exactly.
+type ACL struct {
+	Sources []string `json:"sources"` // CIDRs allowed to use the proxy
+	Allow   []string `json:"allow"`
+	Deny    []string `json:"deny,omitempty"`
+	Grants  []Grant  `json:"grants,omitempty"`
+}
+
+// Grant allows a single client address to reach extra domains, such as an
+// agent container's own AI provider.
+type Grant struct {
+	Source string   `json:"source"`
+	Allow  []string `json:"allow"`
+}
+
+// compiledACL is an ACL with parsed source networks.
+type compiledACL struct {
+	sources []*net.IPNet
+	allow   []string
+	deny    []string
+	grants  []compiledGrant
+}
+
+type compiledGrant struct {
+	source net.IP
+	allow  []string
+}
+
+func compileACL(acl ACL) (*compiledACL, error) {
+	c := &compiledACL{}
+	for _, s := range acl.Sources {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid source network %q: %w", s, err)
+		}
+		c.sources = append(c.sources, n)
+	}
+	for _, d := range acl.Allow {
+		c.allow = append(c.allow, strings.ToLower(strings.TrimSpace(d)))
+	}
+	for _, d := range acl.Deny {
+		c.deny = append(c.deny, strings.ToLower(strings.TrimSpace(d)))
+	}
+	for _, g := range acl.Grants {
+		ip := net.ParseIP(strings.TrimSpace(g.Source))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid grant source %q", g.Source)
+		}
+		cg := compiledGrant{source: ip}
+		for _, d := range g.Allow {
+			cg.allow = append(cg.allow, strings.ToLower(strings.TrimSpace(d)))
+		}
+		c.grants = append(c.grants, cg)
+	}
+	return c, nil
+}
+
+// sourceAllowed reports whether a client address may use the proxy.
+// Loopback is always accepted, like Squid's localhost ACL.
+func (c *compiledACL) sourceAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, n := range c.sources {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decision values reported in access logs.
+const (
+	DecisionAllowed       = "allowed"
+	DecisionDenied        = "denied"         // on the denylist
+	DecisionNotAllowed    = "not_allowed"    // not on the allowlist
+	DecisionBadPort       = "bad_port"       // port outside Safe_ports/SSL_ports
+	DecisionBadSource     = "bad_source"     // client outside agent networks
+	DecisionUpstreamError = "upstream_error" // allowed but unreachable
+)
+
+// check evaluates a request from client in the same order as the Squid
+// config: unsafe ports, then the denylist, then the client's grants and the
+// allowlist.
+func (c *compiledACL) check(client net.IP, host string, port int, connect bool) string {
+	if connect && port != 443 {
+		return DecisionBadPort
+	}
+	if !safePort(port) {
+		return DecisionBadPort
+	}
+	host = normalizeHost(host)
+	for _, d := range c.deny {
+		if matchDomain(d, host) {
+			return DecisionDenied
+		}
+	}
+	for _, g := range c.grants {
+		if !g.source.Equal(client) {
+			continue
+		}
+		for _, d := range g.allow {
+			if matchDomain(d, host) {
+				return DecisionAllowed
+			}
+		}
+	}
+	for _, d := range c.allow {
+		if matchDomain(d, host) {
+			return DecisionAllowed
+		}
+	}
+	return DecisionNotAllowed
+}
+
+// matchDomain implements dstdomain matching for a normalized entry.
+func matchDomain(entry, host string) bool {
+	if entry == "" || host == "" {
+		return false
+	}
+	if strings.HasPrefix(entry, ".") {
+		return host == entry[1:] || strings.HasSuffix(host, entry)
+	}
+	return host == entry
+}
+
+// normalizeHost lowercases a host and strips IPv6 brackets and a trailing dot.
+func normalizeHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// safePort mirrors the Safe_ports ACL of the Squid config.
+func safePort(port int) bool {
+	switch port {
+	case 80, 21, 443, 70, 210, 280, 488, 591, 777:
+		return true
+	}
+	return port >= 1025 && port <= 65535
+}
+
+// splitHostPort splits host:port, falling back to defaultPort.
+func splitHostPort(hostport string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		// No port present.
+		return normalizeHost(hostport), defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	return normalizeHost(host), port, nil
+}
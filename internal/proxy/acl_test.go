@@ -0,0 +1,141 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatchDomain(t *testing.T) {
+	tests := []struct {
+		entry, host string
+		want        bool
+	}{
+		{".github.com", "github.com", true},
+		{".github.com", "api.github.com", true},
+		{".github.com", "notgithub.com", false},
+		{"api.github.com", "api.github.com", true},
+		{"api.github.com", "uploads.github.com", false},
+		{"", "github.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchDomain(tt.entry, tt.host); got != tt.want {
+			t.Errorf("matchDomain(%q, %q) = %v, want %v", tt.entry, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	acl, err := compileACL(ACL{
+		Sources: []string{"10.89.0.0/24"},
+		Allow:   []string{".github.com", "pypi.org"},
+		Deny:    []string{"gist.github.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host    string
+		port    int
+		connect bool
+		want    string
+	}{
+		{"api.github.com", 443, true, DecisionAllowed},
+		{"API.GitHub.com.", 443, true, DecisionAllowed},
+		{"pypi.org", 80, false, DecisionAllowed},
+		{"gist.github.com", 443, true, DecisionDenied},
+		{"example.com", 443, true, DecisionNotAllowed},
+		{"api.github.com", 22, true, DecisionBadPort},
+		{"api.github.com", 8443, true, DecisionBadPort},
+		{"pypi.org", 25, false, DecisionBadPort},
+		{"pypi.org", 8080, false, DecisionAllowed},
+	}
+	for _, tt := range tests {
+		if got := acl.check(net.ParseIP("10.89.0.5"), tt.host, tt.port, tt.connect); got != tt.want {
+			t.Errorf("check(%q, %d, %v) = %q, want %q", tt.host, tt.port, tt.connect, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGrants(t *testing.T) {
+	acl, err := compileACL(ACL{
+		Sources: []string{"10.89.0.0/24"},
+		Allow:   []string{".github.com"},
+		Deny:    []string{"console.anthropic.com"},
+		Grants:  []Grant{{Source: "10.89.0.5", Allow: []string{".anthropic.com"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claude, codex := net.ParseIP("10.89.0.5"), net.ParseIP("10.89.0.6")
+
+	if got := acl.check(claude, "api.anthropic.com", 443, true); got != DecisionAllowed {
+		t.Errorf("granted client: %q, want %q", got, DecisionAllowed)
+	}
+	if got := acl.check(codex, "api.anthropic.com", 443, true); got != DecisionNotAllowed {
+		t.Errorf("other client: %q, want %q", got, DecisionNotAllowed)
+	}
+	if got := acl.check(claude, "console.anthropic.com", 443, true); got != DecisionDenied {
+		t.Errorf("denylist must win over grants: %q", got)
+	}
+	if got := acl.check(codex, "github.com", 443, true); got != DecisionAllowed {
+		t.Errorf("shared allowlist: %q, want %q", got, DecisionAllowed)
+	}
+
+	if _, err := compileACL(ACL{Grants: []Grant{{Source: "10.89.0.0/24"}}}); err == nil {
+		t.Error("expected error for grant source that is not an address")
+	}
+}
+
+func TestSourceAllowed(t *testing.T) {
+	acl, err := compileACL(ACL{Sources: []string{"10.89.0.0/24", "10.90.0.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"10.89.0.5":  true,
+		"10.90.0.7":  true,
+		"127.0.0.1":  true,
+		"10.91.0.1":  false,
+		"192.0.2.10": false,
+	} {
+		if got := acl.sourceAllowed(net.ParseIP(ip)); got != want {
+			t.Errorf("sourceAllowed(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if acl.sourceAllowed(nil) {
+		t.Error("sourceAllowed(nil) should be false")
+	}
+}
+
+func TestCompileACLInvalidSource(t *testing.T) {
+	if _, err := compileACL(ACL{Sources: []string{"not-a-cidr"}}); err == nil {
+		t.Error("expected error for invalid source network")
+	}
+}
+
+func TestSplitHostPort(t *testing.T) {
+	host, port, err := splitHostPort("Example.COM:8080", 80)
+	if err != nil || host != "example.com" || port != 8080 {
+		t.Errorf("got %q %d %v", host, port, err)
+	}
+	host, port, err = splitHostPort("example.com", 443)
+	if err != nil || host != "example.com" || port != 443 {
+		t.Errorf("got %q %d %v", host, port, err)
+	}
+	host, _, err = splitHostPort("[::1]:443", 80)
+	if err != nil || host != "::1" {
+		t.Errorf("got %q %v", host, err)
+	}
+	if _, _, err := splitHostPort("example.com:99999", 80); err == nil {
+		t.Error("expected error for out-of-range port")
+	}
+}
@@ -0,0 +1,118 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ControlRequest is one line sent to the control socket.
+type ControlRequest struct {
+	Type string `json:"type"` // "set_acl" or "ping"
+	ACL  *ACL   `json:"acl,omitempty"`
+}
+
+// ControlResponse is the reply to a ControlRequest.
+type ControlResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+const maxControlRequest = 4 << 20
+
+// ServeControl accepts control connections until l is closed. Each
+// connection carries a single JSON-lines request and response.
+func (s *Server) ServeControl(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleControl(conn)
+	}
+}
+
+func (s *Server) handleControl(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxControlRequest)
+	if !scanner.Scan() {
+		return
+	}
+
+	var req ControlRequest
+	resp := ControlResponse{OK: true}
+	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+		resp = ControlResponse{Error: "invalid request"}
+	} else {
+		switch req.Type {
+		case "ping":
+		case "set_acl":
+			if req.ACL == nil {
+				resp = ControlResponse{Error: "missing acl"}
+			} else if err := s.SetACL(*req.ACL); err != nil {
+				resp = ControlResponse{Error: err.Error()}
+			}
+		default:
+			resp = ControlResponse{Error: fmt.Sprintf("unknown request type: %s", req.Type)}
+		}
+	}
+
+	data, _ := json.Marshal(resp)
+	_, _ = conn.Write(append(data, '\n'))
+}
+
+// SendControl sends req to the control socket at path and returns the reply.
+func SendControl(path string, req ControlRequest) error {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connect to control socket: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write control request: %w", err)
+	}
+
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		return fmt.Errorf("no response from proxy")
+	}
+	var resp ControlResponse
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		return fmt.Errorf("invalid response from proxy: %w", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,418 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MirrorStatsPath serves the mirror's cache statistics as JSON.
+const MirrorStatsPath = "/_exitbox/stats"
+
+// maxRewriteBody bounds metadata responses held in memory for rewriting.
+const maxRewriteBody = 64 << 20
+
+// Registry is a package registry the mirror serves under /<Name>/.
+type Registry struct {
+	Name   string
+	Origin string // scheme and host, e.g. https://registry.npmjs.org
+	// Immutable matches the paths, below the prefix, of artifacts that
+	// never change once published. Only these are cached.
+	Immutable *regexp.Regexp
+	// Rewrite marks metadata whose links to mirrored origins are pointed
+	// back at the mirror, so the artifacts are fetched through it too.
+	Rewrite bool
+}
+
+// Registries are the registries mirrored by default. PyPI needs both
+// entries: the index on pypi.org links to files on files.pythonhosted.org.
+var Registries = []Registry{
+	{Name: "npm", Origin: "https://registry.npmjs.org", Immutable: regexp.MustCompile(`/-/[^/]+\.tgz$`), Rewrite: true},
+	{Name: "pypi", Origin: "https://pypi.org", Rewrite: true},
+	{Name: "pypi-files", Origin: "https://files.pythonhosted.org", Immutable: regexp.MustCompile(`^/packages/`)},
+	{Name: "go", Origin: "https://proxy.golang.org", Immutable: regexp.MustCompile(`/@v/[^/]+\.(zip|mod|info)$`)},
+}
+
+// MirrorConfig configures NewMirror.
+type MirrorConfig struct {
+	CacheDir string
+	MaxBytes int64
+	// Proxy is the forward proxy used for all upstream requests, i.e. the
+	// firewall, so its allow and deny rules apply to the mirror as well.
+	Proxy *url.URL
+	// RootCAs verifies registries; nil uses the system roots.
+	RootCAs *x509.CertPool
+	// Registries defaults to Registries.
+	Registries []Registry
+}
+
+// MirrorStats is the JSON served at MirrorStatsPath.
+type MirrorStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	HitBytes  int64 `json:"hit_bytes"`
+	MissBytes int64 `json:"miss_bytes"`
+	Entries   int64 `json:"entries"`
+	SizeBytes int64 `json:"size_bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+}
+
+// Mirror is a caching reverse proxy for package registries. Clients use it
+// as a plain HTTP registry (npm registry, pip index, GOPROXY); it fetches
+// from the registries over HTTPS and keeps immutable artifacts on disk.
+type Mirror struct {
+	cfg        MirrorConfig
+	registries []Registry
+	client     *http.Client
+
+	mu      sync.Mutex // guards size and entries, and serializes eviction
+	size    int64
+	entries int64
+
+	hits, misses, hitBytes, missBytes atomic.Int64
+}
+
+// NewMirror returns a mirror caching in cfg.CacheDir.
+func NewMirror(cfg MirrorConfig) (*Mirror, error) {
+	// Partial downloads of a previous run are never completed.
+	_ = os.RemoveAll(filepath.Join(cfg.CacheDir, "tmp"))
+	if err := os.MkdirAll(filepath.Join(cfg.CacheDir, "tmp"), 0755); err != nil {
+		return nil, err
+	}
+
+	m := &Mirror{cfg: cfg, registries: cfg.Registries}
+	if m.registries == nil {
+		m.registries = Registries
+	}
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		DialContext:           d.DialContext,
+		TLSClientConfig:       &tls.Config{RootCAs: cfg.RootCAs},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}
+	if cfg.Proxy != nil {
+		transport.Proxy = http.ProxyURL(cfg.Proxy)
+	}
+	m.client = &http.Client{Transport: transport}
+
+	for _, f := range m.cachedFiles() {
+		m.size += f.size
+		m.entries++
+	}
+	return m, nil
+}
+
+// Stats returns the mirror's counters and cache size.
+func (m *Mirror) Stats() MirrorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MirrorStats{
+		Hits:      m.hits.Load(),
+		Misses:    m.misses.Load(),
+		HitBytes:  m.hitBytes.Load(),
+		MissBytes: m.missBytes.Load(),
+		Entries:   m.entries,
+		SizeBytes: m.size,
+		MaxBytes:  m.cfg.MaxBytes,
+	}
+}
+
+// ServeHTTP serves /<registry>/<path> from the cache or the registry.
+func (m *Mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == MirrorStatsPath {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.Stats())
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	var reg *Registry
+	for i := range m.registries {
+		if m.registries[i].Name == name {
+			reg = &m.registries[i]
+			break
+		}
+	}
+	if reg == nil {
+		http.Error(w, "unknown registry", http.StatusNotFound)
+		return
+	}
+	rest = "/" + rest
+	upstream := reg.Origin + rest
+	if r.URL.RawQuery != "" {
+		upstream += "?" + r.URL.RawQuery
+	}
+
+	if reg.Immutable != nil && reg.Immutable.MatchString(rest) && r.URL.RawQuery == "" {
+		m.serveArtifact(w, r, reg.Name, rest, upstream)
+		return
+	}
+	m.forward(w, r, reg, upstream)
+}
+
+// forwardHeaders are passed from clients to registries.
+var forwardHeaders = []string{"Accept", "Accept-Encoding", "If-None-Match", "If-Modified-Since", "Range", "User-Agent"}
+
+// forward proxies an uncached request, rewriting metadata links if the
+// registry asks for it.
+func (m *Mirror) forward(w http.ResponseWriter, r *http.Request, reg *Registry, upstream string) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstream, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, h := range forwardHeaders {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	if reg.Rewrite {
+		// Let the transport decompress so the body can be rewritten.
+		req.Header.Del("Accept-Encoding")
+		req.Header.Del("Range")
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("registry request failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	copyResponseHeaders(w.Header(), resp.Header)
+	if reg.Rewrite && resp.StatusCode == http.StatusOK && rewritable(resp.Header.Get("Content-Type")) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxRewriteBody+1))
+		if err != nil || len(body) > maxRewriteBody {
+			http.Error(w, "registry response too large or truncated", http.StatusBadGateway)
+			return
+		}
+		body = []byte(m.rewriter(r).Replace(string(body)))
+		w.Header().Del("Content-Encoding")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(resp.StatusCode)
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(body)
+		}
+		return
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// rewriter points links to any mirrored registry at this mirror, as the
+// client addressed it.
+func (m *Mirror) rewriter(r *http.Request) *strings.Replacer {
+	var pairs []string
+	for _, reg := range m.registries {
+		pairs = append(pairs, reg.Origin+"/", "http://"+r.Host+"/"+reg.Name+"/")
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// rewritable reports whether a content type is registry metadata.
+func rewritable(contentType string) bool {
+	return strings.Contains(contentType, "json") || strings.Contains(contentType, "html")
+}
+
+// serveArtifact serves an immutable artifact from the cache, downloading
+// it first on a miss.
+func (m *Mirror) serveArtifact(w http.ResponseWriter, r *http.Request, registry, rest, upstream string) {
+	file := m.cachePath(registry, rest)
+	if f, err := os.Open(file); err == nil {
+		defer f.Close()
+		if info, err := f.Stat(); err == nil {
+			now := time.Now()
+			_ = os.Chtimes(file, now, now) // recently used, see evict
+			m.hits.Add(1)
+			m.hitBytes.Add(info.Size())
+			http.ServeContent(w, r, path.Base(rest), info.ModTime(), f)
+			return
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, upstream, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ua := r.Header.Get("User-Agent"); ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("registry request failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	m.misses.Add(1)
+	copyResponseHeaders(w.Header(), resp.Header)
+	if resp.Uncompressed {
+		w.Header().Del("Content-Length")
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if resp.StatusCode != http.StatusOK || resp.ContentLength > m.cfg.MaxBytes/2 {
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(m.cfg.CacheDir, "tmp"), "download-*")
+	if err != nil {
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	// Keep downloading into the cache if the client goes away.
+	n, err := io.Copy(tmp, io.TeeReader(resp.Body, &ignoreErrors{w: w}))
+	m.missBytes.Add(n)
+	if closeErr := tmp.Close(); err != nil || closeErr != nil {
+		return
+	}
+	if resp.ContentLength >= 0 && !resp.Uncompressed && n != resp.ContentLength {
+		return
+	}
+	m.store(tmp.Name(), file, n)
+}
+
+// store moves a completed download into the cache and evicts old entries
+// if the cache has grown past its limit.
+func (m *Mirror) store(tmp, file string, size int64) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	replaced, statErr := os.Stat(file)
+	if err := os.Rename(tmp, file); err != nil {
+		return
+	}
+	if statErr == nil {
+		m.size -= replaced.Size()
+		m.entries--
+	}
+	m.size += size
+	m.entries++
+	if m.size > m.cfg.MaxBytes {
+		m.evict()
+	}
+}
+
+// evict removes the least recently used artifacts until the cache is at
+// 90% of its limit. m.mu must be held.
+func (m *Mirror) evict() {
+	files := m.cachedFiles()
+	sort.Slice(files, func(i, j int) bool { return files[i].used.Before(files[j].used) })
+	for _, f := range files {
+		if m.size <= m.cfg.MaxBytes/10*9 {
+			return
+		}
+		if os.Remove(f.path) == nil {
+			m.size -= f.size
+			m.entries--
+		}
+	}
+}
+
+type cachedFile struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// cachedFiles lists the artifacts in the cache.
+func (m *Mirror) cachedFiles() []cachedFile {
+	var files []cachedFile
+	tmp := filepath.Join(m.cfg.CacheDir, "tmp")
+	_ = filepath.WalkDir(m.cfg.CacheDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p == tmp {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files = append(files, cachedFile{path: p, size: info.Size(), used: info.ModTime()})
+		}
+		return nil
+	})
+	return files
+}
+
+// cachePath returns where an artifact is stored.
+func (m *Mirror) cachePath(registry, rest string) string {
+	sum := sha256.Sum256([]byte(rest))
+	h := hex.EncodeToString(sum[:])
+	return filepath.Join(m.cfg.CacheDir, registry, h[:2], h)
+}
+
+// copyResponseHeaders copies registry response headers except hop-by-hop
+// ones.
+func copyResponseHeaders(dst, src http.Header) {
+	for k, vs := range src {
+		dst[k] = append([]string(nil), vs...)
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
+
+// ignoreErrors writes to w until the first error and then discards.
+type ignoreErrors struct {
+	w      io.Writer
+	failed bool
+}
+
+func (e *ignoreErrors) Write(p []byte) (int, error) {
+	if !e.failed {
+		if _, err := e.w.Write(p); err != nil {
+			e.failed = true
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,127 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestMirror(t *testing.T, maxBytes int64) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	var origin *httptest.Server
+	origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		switch {
+		case r.URL.Path == "/left-pad":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"dist":{"tarball":"`+origin.URL+`/left-pad/-/left-pad-1.3.0.tgz"}}`)
+		case strings.HasSuffix(r.URL.Path, ".tgz") && !strings.Contains(r.URL.Path, "missing"):
+			_, _ = io.WriteString(w, strings.Repeat("x", 400))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(origin.Close)
+
+	m, err := NewMirror(MirrorConfig{
+		CacheDir: t.TempDir(),
+		MaxBytes: maxBytes,
+		Registries: []Registry{
+			{Name: "npm", Origin: origin.URL, Immutable: regexp.MustCompile(`/-/[^/]+\.tgz$`), Rewrite: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(m)
+	t.Cleanup(ts.Close)
+	return ts, &fetches
+}
+
+func mirrorGet(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestMirrorCachesArtifacts(t *testing.T) {
+	ts, fetches := newTestMirror(t, 1<<20)
+
+	// Metadata is always fetched and its links point back at the mirror.
+	status, body := mirrorGet(t, ts.URL+"/npm/left-pad")
+	want := ts.URL + "/npm/left-pad/-/left-pad-1.3.0.tgz"
+	if status != http.StatusOK || !strings.Contains(body, want) {
+		t.Fatalf("metadata = %d %s, want link %s", status, body, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		status, body = mirrorGet(t, want)
+		if status != http.StatusOK || len(body) != 400 {
+			t.Fatalf("tarball %d = %d, %d bytes", i, status, len(body))
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("origin fetches = %d, want 2 (metadata and one tarball)", n)
+	}
+
+	if status, _ := mirrorGet(t, ts.URL+"/npm/missing/-/missing-1.0.0.tgz"); status != http.StatusNotFound {
+		t.Errorf("missing tarball status = %d", status)
+	}
+	if status, _ := mirrorGet(t, ts.URL+"/cargo/serde"); status != http.StatusNotFound {
+		t.Errorf("unknown registry status = %d", status)
+	}
+
+	_, body = mirrorGet(t, ts.URL+MirrorStatsPath)
+	var stats MirrorStats
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 || stats.SizeBytes != 400 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestMirrorEvictsOldest(t *testing.T) {
+	ts, _ := newTestMirror(t, 1000)
+
+	for _, v := range []string{"1", "2", "3"} {
+		if status, _ := mirrorGet(t, ts.URL+"/npm/p/-/p-"+v+".tgz"); status != http.StatusOK {
+			t.Fatalf("tarball %s status = %d", v, status)
+		}
+	}
+	_, body := mirrorGet(t, ts.URL+MirrorStatsPath)
+	var stats MirrorStats
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.SizeBytes > 900 || stats.Entries != 2 {
+		t.Errorf("stats after eviction = %+v", stats)
+	}
+}
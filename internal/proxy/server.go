@@ -0,0 +1,314 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hopHeaders are removed before forwarding plain HTTP requests.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// LogEntry is one JSON access log line.
+type LogEntry struct {
+	Time       string `json:"time"`
+	Client     string `json:"client"`
+	Container  string `json:"container,omitempty"`
+	Method     string `json:"method"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Decision   string `json:"decision"`
+	Status     int    `json:"status"`
+	BytesIn    int64  `json:"bytes_in"`
+	BytesOut   int64  `json:"bytes_out"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Server is the forward proxy. It is safe for concurrent use; the ACL can
+// be replaced at any time with SetACL.
+type Server struct {
+	mu  sync.RWMutex
+	acl *compiledACL
+
+	logMu sync.Mutex
+	log   *json.Encoder
+
+	// Attribute maps a client IP to a container name. It defaults to a
+	// cached reverse DNS lookup on the agent network.
+	Attribute func(ip string) string
+
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+// NewServer returns a proxy enforcing acl and writing access logs to logw.
+func NewServer(acl ACL, logw io.Writer) (*Server, error) {
+	c, err := compileACL(acl)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	s := &Server{
+		acl:    c,
+		log:    json.NewEncoder(logw),
+		dialer: d,
+		transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           d.DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+		},
+	}
+	s.Attribute = newReverseDNSCache(5 * time.Minute).lookup
+	return s, nil
+}
+
+// SetACL atomically replaces the access policy.
+func (s *Server) SetACL(acl ACL) error {
+	c, err := compileACL(acl)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.acl = c
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) currentACL() *compiledACL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.acl
+}
+
+// ServeHTTP handles CONNECT tunnels and absolute-URI HTTP requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	entry := LogEntry{
+		Client: clientIP,
+		Method: r.Method,
+	}
+	defer func() {
+		entry.DurationMS = time.Since(start).Milliseconds()
+		s.writeLog(entry)
+	}()
+
+	acl := s.currentACL()
+	ip := net.ParseIP(clientIP)
+	if !acl.sourceAllowed(ip) {
+		entry.Decision = DecisionBadSource
+		entry.Status = http.StatusForbidden
+		http.Error(w, "exitbox: client not allowed", http.StatusForbidden)
+		return
+	}
+	if s.Attribute != nil {
+		entry.Container = s.Attribute(clientIP)
+	}
+
+	connect := r.Method == http.MethodConnect
+	var hostport string
+	defaultPort := 80
+	if connect {
+		hostport = r.Host
+		defaultPort = 443
+	} else {
+		if r.URL == nil || !r.URL.IsAbs() || r.URL.Scheme != "http" {
+			entry.Decision = DecisionNotAllowed
+			entry.Status = http.StatusBadRequest
+			http.Error(w, "exitbox: only absolute http:// URLs and CONNECT are supported", http.StatusBadRequest)
+			return
+		}
+		hostport = r.URL.Host
+	}
+
+	host, port, err := splitHostPort(hostport, defaultPort)
+	if err != nil {
+		entry.Decision = DecisionBadPort
+		entry.Status = http.StatusBadRequest
+		http.Error(w, "exitbox: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry.Host, entry.Port = host, port
+
+	entry.Decision = acl.check(ip, host, port, connect)
+	if entry.Decision != DecisionAllowed {
+		entry.Status = http.StatusForbidden
+		http.Error(w, fmt.Sprintf("exitbox: access to %s blocked by firewall (%s)", host, entry.Decision), http.StatusForbidden)
+		return
+	}
+
+	if connect {
+		s.tunnel(w, r, net.JoinHostPort(host, fmt.Sprint(port)), &entry)
+		return
+	}
+	s.forward(w, r, &entry)
+}
+
+// tunnel serves a CONNECT request by splicing the client and the target.
+func (s *Server) tunnel(w http.ResponseWriter, r *http.Request, target string, entry *LogEntry) {
+	upstream, err := s.dialer.DialContext(r.Context(), "tcp", target)
+	if err != nil {
+		entry.Decision = DecisionUpstreamError
+		entry.Status = http.StatusBadGateway
+		http.Error(w, "exitbox: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		entry.Status = http.StatusInternalServerError
+		http.Error(w, "exitbox: hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		entry.Status = http.StatusInternalServerError
+		return
+	}
+	defer client.Close()
+
+	entry.Status = http.StatusOK
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Bytes already buffered by the HTTP server belong to the tunnel.
+		n, _ := io.Copy(upstream, io.MultiReader(buf.Reader, client))
+		entry.BytesOut = n
+		closeWrite(upstream)
+	}()
+	n, _ := io.Copy(client, upstream)
+	entry.BytesIn = n
+	closeWrite(client)
+	wg.Wait()
+}
+
+// forward proxies a plain HTTP request.
+func (s *Server) forward(w http.ResponseWriter, r *http.Request, entry *LogEntry) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	if c := r.Header.Get("Connection"); c != "" {
+		for _, f := range strings.Split(c, ",") {
+			out.Header.Del(strings.TrimSpace(f))
+		}
+	}
+
+	resp, err := s.transport.RoundTrip(out)
+	if err != nil {
+		entry.Decision = DecisionUpstreamError
+		entry.Status = http.StatusBadGateway
+		http.Error(w, "exitbox: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	entry.Status = resp.StatusCode
+	w.WriteHeader(resp.StatusCode)
+	entry.BytesIn, _ = io.Copy(w, resp.Body)
+	entry.BytesOut = r.ContentLength
+}
+
+func (s *Server) writeLog(e LogEntry) {
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	_ = s.log.Encode(e)
+}
+
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	}
+}
+
+// reverseDNSCache attributes client IPs to container names using PTR
+// lookups, which both Docker's and Podman's network DNS answer for
+// containers on user-defined networks.
+type reverseDNSCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	name    string
+	expires time.Time
+}
+
+func newReverseDNSCache(ttl time.Duration) *reverseDNSCache {
+	return &reverseDNSCache{ttl: ttl, entries: make(map[string]dnsEntry)}
+}
+
+func (c *reverseDNSCache) lookup(ip string) string {
+	c.mu.Lock()
+	if e, ok := c.entries[ip]; ok && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.name
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	var name string
+	if names, err := net.DefaultResolver.LookupAddr(ctx, ip); err == nil && len(names) > 0 {
+		name = containerNameFromPTR(names[0])
+	}
+
+	c.mu.Lock()
+	c.entries[ip] = dnsEntry{name: name, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return name
+}
+
+// containerNameFromPTR strips the network domain from a PTR answer, e.g.
+// "exitbox-claude-app-1a2b3c4d.dns.podman." -> "exitbox-claude-app-1a2b3c4d".
+func containerNameFromPTR(name string) string {
+	name = strings.TrimSuffix(name, ".")
+	if i := strings.Index(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,193 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestProxy(t *testing.T, acl ACL) (*Server, *httptest.Server, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	s, err := NewServer(acl, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Attribute = func(string) string { return "exitbox-test" }
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts, &logs
+}
+
+func proxyClient(t *testing.T, proxyURL string) *http.Client {
+	t.Helper()
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}
+}
+
+func lastLog(t *testing.T, logs *bytes.Buffer) LogEntry {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	var e LogEntry
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &e); err != nil {
+		t.Fatalf("invalid log line %q: %v", lines[len(lines)-1], err)
+	}
+	return e
+}
+
+func TestForwardAllowed(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "" {
+			t.Error("hop-by-hop header forwarded to origin")
+		}
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer backend.Close()
+
+	_, ts, logs := newTestProxy(t, ACL{Allow: []string{"127.0.0.1"}})
+	req, _ := http.NewRequest(http.MethodGet, backend.URL, nil)
+	req.Header.Set("Proxy-Authorization", "Basic secret")
+	resp, err := proxyClient(t, ts.URL).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+
+	e := lastLog(t, logs)
+	if e.Decision != DecisionAllowed || e.Host != "127.0.0.1" || e.Container != "exitbox-test" || e.BytesIn != 5 {
+		t.Errorf("unexpected log entry: %+v", e)
+	}
+}
+
+func TestForwardBlocked(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("blocked request reached origin")
+	}))
+	defer backend.Close()
+
+	s, ts, logs := newTestProxy(t, ACL{Allow: []string{"127.0.0.1"}, Deny: []string{"127.0.0.1"}})
+	resp, err := proxyClient(t, ts.URL).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+	if e := lastLog(t, logs); e.Decision != DecisionDenied {
+		t.Errorf("decision = %q, want %q", e.Decision, DecisionDenied)
+	}
+
+	// Replacing the ACL takes effect on the next request.
+	if err := s.SetACL(ACL{}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = proxyClient(t, ts.URL).Get(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if e := lastLog(t, logs); e.Decision != DecisionNotAllowed {
+		t.Errorf("decision = %q, want %q", e.Decision, DecisionNotAllowed)
+	}
+}
+
+func TestConnectBlocked(t *testing.T) {
+	_, ts, logs := newTestProxy(t, ACL{Allow: []string{".github.com"}})
+
+	tests := []struct {
+		target, decision string
+	}{
+		{"example.com:443", DecisionNotAllowed},
+		{"github.com:22", DecisionBadPort},
+	}
+	for _, tt := range tests {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(conn, "CONNECT "+tt.target+" HTTP/1.1\r\nHost: "+tt.target+"\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("CONNECT %s: status = %d, want 403", tt.target, resp.StatusCode)
+		}
+		if e := lastLog(t, logs); e.Decision != tt.decision {
+			t.Errorf("CONNECT %s: decision = %q, want %q", tt.target, e.Decision, tt.decision)
+		}
+	}
+}
+
+func TestBadSource(t *testing.T) {
+	var logs bytes.Buffer
+	s, err := NewServer(ACL{Sources: []string{"10.89.0.0/24"}, Allow: []string{".github.com"}}, &logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://github.com/", nil)
+	req.RemoteAddr = "192.0.2.10:40000"
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if e := lastLog(t, &logs); e.Decision != DecisionBadSource {
+		t.Errorf("decision = %q, want %q", e.Decision, DecisionBadSource)
+	}
+}
+
+func TestControlSetACL(t *testing.T) {
+	s, err := NewServer(ACL{}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = s.ServeControl(l) }()
+
+	if err := SendControl(path, ControlRequest{Type: "ping"}); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if err := SendControl(path, ControlRequest{Type: "set_acl", ACL: &ACL{Allow: []string{".github.com"}}}); err != nil {
+		t.Fatalf("set_acl: %v", err)
+	}
+	if got := s.currentACL().check(net.ParseIP("127.0.0.1"), "api.github.com", 443, true); got != DecisionAllowed {
+		t.Errorf("after set_acl: decision = %q, want %q", got, DecisionAllowed)
+	}
+	if err := SendControl(path, ControlRequest{Type: "set_acl", ACL: &ACL{Sources: []string{"bogus"}}}); err == nil {
+		t.Error("expected error for invalid ACL")
+	}
+	if err := SendControl(path, ControlRequest{Type: "set_acl"}); err == nil {
+		t.Error("expected error for missing ACL")
+	}
+}
@@ -0,0 +1,124 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// callbackRelayTimeout is how long a callback port stays open on the host:
+// long enough to finish a browser login.
+const callbackRelayTimeout = 10 * time.Minute
+
+// CallbackRelay lets OAuth redirects to localhost:<port> in the host browser
+// reach a CLI listening on the same port inside the container. Each port is
+// opened on demand by an approved exitbox-open request.
+type CallbackRelay struct {
+	rt            container.Runtime
+	containerName string
+	ctx           context.Context
+	cancel        context.CancelFunc
+
+	mu        sync.Mutex
+	listeners map[int]net.Listener
+}
+
+// NewCallbackRelay returns a relay into containerName with no open ports.
+func NewCallbackRelay(rt container.Runtime, containerName string) *CallbackRelay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CallbackRelay{
+		rt:            rt,
+		containerName: containerName,
+		ctx:           ctx,
+		cancel:        cancel,
+		listeners:     make(map[int]net.Listener),
+	}
+}
+
+// Listen opens port on the host loopback for callbackRelayTimeout and
+// relays its connections to the same port inside the container. A port
+// that is already open is left as is.
+func (r *CallbackRelay) Listen(port int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.listeners[port] != nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	r.listeners[port] = listener
+	go r.serve(port, listener)
+	time.AfterFunc(callbackRelayTimeout, func() { r.close(port, listener) })
+	return nil
+}
+
+// serve accepts connections until the listener is closed.
+func (r *CallbackRelay) serve(port int, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			r.close(port, listener)
+			return
+		}
+		go r.relay(port, conn)
+	}
+}
+
+// relay pipes conn through socat in the container to its loopback port.
+// Exec works alike for every runtime and network mode, where reaching the
+// container's address from the host does not.
+func (r *CallbackRelay) relay(port int, conn net.Conn) {
+	defer conn.Close()
+	c := exec.CommandContext(r.ctx, container.Cmd(r.rt), "exec", "-i", r.containerName,
+		"socat", "STDIO", "TCP:127.0.0.1:"+strconv.Itoa(port))
+	c.Stdin = conn
+	c.Stdout = conn
+	_ = c.Run()
+}
+
+// close stops listening on port, if listener is still the one serving it.
+func (r *CallbackRelay) close(port int, listener net.Listener) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.listeners[port] == listener {
+		delete(r.listeners, port)
+	}
+	listener.Close()
+}
+
+// StopCallbackRelay closes every open port. It is nil-safe.
+func StopCallbackRelay(r *CallbackRelay) {
+	if r == nil {
+		return
+	}
+	r.cancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for port, listener := range r.listeners {
+		listener.Close()
+		delete(r.listeners, port)
+	}
+}
@@ -19,23 +19,46 @@ package run
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
-// IDERelay bridges a Unix domain socket to the host IDE's TCP WebSocket port.
+// ideSyncInterval is how often the host IDE lock files are rescanned, so
+// that an IDE restarting on a new port is picked up mid-session.
+const ideSyncInterval = 2 * time.Second
+
+// IDERelay bridges Unix domain sockets to the WebSocket ports of every host
+// IDE open on the project. Each IDE gets ide-<port>.sock in the IPC socket
+// directory and its lock file, rewritten for the container, in LockDir.
 type IDERelay struct {
-	Port       string
-	SocketPath string
-	LockDir    string // temp dir with rewritten lock file
-	listener   net.Listener
+	SSEPort string // port from CLAUDE_CODE_SSE_PORT, "" if not set
+	LockDir string // temp dir with rewritten lock files
+
+	socketDir  string
+	projectDir string
 	cancel     context.CancelFunc
+	done       chan struct{}
+
+	mu     sync.Mutex
+	relays map[string]*ideSocket // port -> relay
+	locks  map[string][]byte     // port -> rewritten lock file
+}
+
+// ideSocket is the relay for a single IDE port.
+type ideSocket struct {
+	listener net.Listener
+	path     string
+	cancel   context.CancelFunc
 }
 
 // ideLockFile represents the JSON lock file written by the IDE extension.
@@ -47,73 +70,290 @@ type ideLockFile struct {
 	AuthToken        string   `json:"authToken"`
 }
 
-// DetectIDE checks whether IDE integration should be enabled.
-// It returns the port and true only when the agent is "claude" and
-// the CLAUDE_CODE_SSE_PORT env var contains a valid port number.
+// processAlive reports whether the IDE owning a lock file still runs.
+// Lock files of crashed IDEs are left behind.
+var processAlive = func(pid int) bool {
+	if pid <= 0 {
+		return true
+	}
+	return pidAlive(pid)
+}
+
+// DetectIDE returns the port in CLAUDE_CODE_SSE_PORT, which the IDE sets
+// when it launches the agent's terminal. It returns true only when the
+// agent is "claude" and the port is valid.
 func DetectIDE(agent string) (string, bool) {
 	if agent != "claude" {
 		return "", false
 	}
 	port := os.Getenv("CLAUDE_CODE_SSE_PORT")
-	if port == "" {
+	if !validPort(port) {
 		return "", false
 	}
+	return port, true
+}
+
+func validPort(port string) bool {
 	n, err := strconv.Atoi(port)
-	if err != nil || n < 1 || n > 65535 {
-		return "", false
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// ideLockDir is where IDE extensions write their lock files on the host.
+func ideLockDir() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
 	}
-	return port, true
+	return filepath.Join(home, ".claude", "ide")
 }
 
-// StartIDERelay creates ide.sock in the IPC socket directory and starts a
-// goroutine that accepts Unix connections and relays them to the IDE's TCP
-// port on the host (127.0.0.1:<port>).
-func StartIDERelay(ipcSocketDir, port string) *IDERelay {
-	socketPath := filepath.Join(ipcSocketDir, "ide.sock")
-	listener, err := net.Listen("unix", socketPath)
+// findIDELocks returns the lock files of the running IDEs that have
+// projectDir open, plus the lock file of ssePort whatever it has open,
+// keyed by port.
+func findIDELocks(projectDir, ssePort string) map[string]ideLockFile {
+	dir := ideLockDir()
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		ui.Warnf("Failed to create IDE relay socket: %v", err)
 		return nil
 	}
-	// Allow non-root container user to connect (matches host.sock pattern).
-	if err := os.Chmod(socketPath, 0666); err != nil {
-		ui.Warnf("Failed to chmod IDE relay socket: %v", err)
-		listener.Close()
+	locks := make(map[string]ideLockFile)
+	for _, e := range entries {
+		port := strings.TrimSuffix(e.Name(), ".lock")
+		if e.IsDir() || port == e.Name() || !validPort(port) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var lock ideLockFile
+		if err := json.Unmarshal(data, &lock); err != nil || !processAlive(lock.PID) {
+			continue
+		}
+		if port == ssePort || len(containerFolders(lock.WorkspaceFolders, projectDir)) > 0 {
+			locks[port] = lock
+		}
+	}
+	return locks
+}
+
+// containerFolders maps IDE workspace folders to their paths inside the
+// container. Folders at or below projectDir map into /workspace and a
+// folder containing projectDir maps to /workspace itself; others are not
+// visible in the container and are dropped.
+func containerFolders(folders []string, projectDir string) []string {
+	projectDir = filepath.Clean(projectDir)
+	var result []string
+	for _, f := range folders {
+		f = filepath.Clean(f)
+		rel, err := filepath.Rel(projectDir, f)
+		switch {
+		case err == nil && rel == ".":
+			result = append(result, "/workspace")
+		case err == nil && !strings.HasPrefix(rel, ".."):
+			result = append(result, "/workspace/"+filepath.ToSlash(rel))
+		case strings.HasPrefix(projectDir, f+string(filepath.Separator)):
+			result = append(result, "/workspace")
+		}
+	}
+	return result
+}
+
+// rewriteIDELock adapts a host lock file for the container: the PID becomes
+// 1 (the container init process, always valid due to --init) and the
+// workspace folders become container paths.
+func rewriteIDELock(lock ideLockFile, projectDir string) ([]byte, error) {
+	lock.PID = 1
+	lock.WorkspaceFolders = containerFolders(lock.WorkspaceFolders, projectDir)
+	if len(lock.WorkspaceFolders) == 0 {
+		lock.WorkspaceFolders = []string{"/workspace"}
+	}
+	return json.Marshal(lock)
+}
+
+// StartIDERelays starts a relay for every host IDE the agent can use: the
+// one in CLAUDE_CODE_SSE_PORT and all running IDEs with projectDir open.
+// IDE lock files are rescanned in the background, so IDEs that start or
+// restart during the session are relayed too, even when none was open at
+// start. It returns nil when the agent does not support IDE integration.
+func StartIDERelays(ipcSocketDir, agent, projectDir string) *IDERelay {
+	if agent != "claude" {
+		return nil
+	}
+	ssePort, _ := DetectIDE(agent)
+
+	lockDir, err := os.MkdirTemp("", "exitbox-ide-lock-*")
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(lockDir, "ide"), 0755)
+	}
+	if err != nil {
+		ui.Warnf("Failed to prepare IDE lock files: %v", err)
 		return nil
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	relay := &IDERelay{
-		Port:       port,
-		SocketPath: socketPath,
-		listener:   listener,
+	r := &IDERelay{
+		SSEPort:    ssePort,
+		LockDir:    lockDir,
+		socketDir:  ipcSocketDir,
+		projectDir: projectDir,
+		relays:     make(map[string]*ideSocket),
+		locks:      make(map[string][]byte),
 		cancel:     cancel,
+		done:       make(chan struct{}),
 	}
+	r.sync()
 
 	go func() {
+		defer close(r.done)
+		t := time.NewTicker(ideSyncInterval)
+		defer t.Stop()
 		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					continue
-				}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				r.sync()
 			}
-			go relayConn(ctx, conn, port)
 		}
 	}()
+	return r
+}
+
+// sync starts relays for new IDE ports, stops those of IDEs that went away
+// and rewrites lock files whose content changed (e.g. a new auth token
+// after an IDE restart).
+func (r *IDERelay) sync() {
+	locks := findIDELocks(r.projectDir, r.SSEPort)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for port := range locks {
+		wanted[port] = true
+	}
+	if r.SSEPort != "" {
+		wanted[r.SSEPort] = true
+	}
+
+	for port := range wanted {
+		if r.relays[port] == nil {
+			if s := startIDESocket(r.socketDir, port); s != nil {
+				r.relays[port] = s
+			}
+		}
+		lock, ok := locks[port]
+		if !ok {
+			continue
+		}
+		data, err := rewriteIDELock(lock, r.projectDir)
+		if err != nil || string(data) == string(r.locks[port]) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(r.LockDir, "ide", port+".lock"), data, 0644); err == nil {
+			r.locks[port] = data
+		}
+	}
+
+	for port, s := range r.relays {
+		if !wanted[port] {
+			s.stop()
+			delete(r.relays, port)
+		}
+	}
+	for port := range r.locks {
+		if _, ok := locks[port]; !ok {
+			_ = os.Remove(filepath.Join(r.LockDir, "ide", port+".lock"))
+			delete(r.locks, port)
+		}
+	}
+}
+
+// startIDESocket creates ide-<port>.sock and relays its connections to the
+// IDE's TCP port on the host (127.0.0.1:<port>).
+func startIDESocket(socketDir, port string) *ideSocket {
+	socketPath := filepath.Join(socketDir, "ide-"+port+".sock")
+	// The socket directory is writable from the container, which could
+	// swap the path for a symlink between bind and chmod. Bind and chmod
+	// in a private directory instead and rename the socket into place;
+	// rename replaces a planted link rather than following it.
+	private, err := os.MkdirTemp(filepath.Dir(socketDir), "exitbox-ide-*")
+	if err != nil {
+		ui.Warnf("Failed to create IDE relay socket: %v", err)
+		return nil
+	}
+	defer os.RemoveAll(private)
+	bindPath := filepath.Join(private, "ide.sock")
+	listener, err := net.Listen("unix", bindPath)
+	if err != nil {
+		ui.Warnf("Failed to create IDE relay socket: %v", err)
+		return nil
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	// Allow non-root container user to connect (matches host.sock pattern).
+	if err := os.Chmod(bindPath, 0666); err != nil {
+		ui.Warnf("Failed to chmod IDE relay socket: %v", err)
+		listener.Close()
+		return nil
+	}
+	if err := os.Rename(bindPath, socketPath); err != nil {
+		ui.Warnf("Failed to create IDE relay socket: %v", err)
+		listener.Close()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go serveRelay(ctx, listener, "127.0.0.1:"+port)
+	return &ideSocket{listener: listener, path: socketPath, cancel: cancel}
+}
+
+func (s *ideSocket) stop() {
+	s.cancel()
+	s.listener.Close()
+	_ = os.Remove(s.path)
+}
 
-	return relay
+// Ports returns the relayed IDE ports, sorted.
+func (r *IDERelay) Ports() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ports []string
+	for port := range r.relays {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// serveRelay accepts Unix connections until ctx is cancelled and relays
+// each one to the TCP address addr.
+func serveRelay(ctx context.Context, listener net.Listener, addr string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+		}
+		go relayConn(ctx, conn, addr)
+	}
 }
 
-// relayConn handles a single Unix connection by dialing the IDE's TCP port
-// and performing bidirectional copy.
-func relayConn(ctx context.Context, unixConn net.Conn, port string) {
+// relayConn handles a single Unix connection by dialing addr and performing
+// bidirectional copy. Every connection dials anew, so an IDE restarting on
+// the same port is reached again without restarting the relay.
+func relayConn(ctx context.Context, unixConn net.Conn, addr string) {
 	defer unixConn.Close()
 
-	tcpConn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	tcpConn, err := net.Dial("tcp", addr)
 	if err != nil {
 		return
 	}
@@ -150,53 +390,6 @@ func relayConn(ctx context.Context, unixConn net.Conn, port string) {
 	wg.Wait()
 }
 
-// PrepareIDELockFile reads the IDE lock file from the host, rewrites the PID
-// to 1 (the container init process, always valid due to --init), and writes
-// the modified file to a temp directory. Returns the temp directory path,
-// or "" on failure.
-func PrepareIDELockFile(port string) string {
-	home := os.Getenv("HOME")
-	if home == "" {
-		return ""
-	}
-	srcPath := filepath.Join(home, ".claude", "ide", port+".lock")
-	data, err := os.ReadFile(srcPath)
-	if err != nil {
-		return ""
-	}
-
-	var lock ideLockFile
-	if err := json.Unmarshal(data, &lock); err != nil {
-		return ""
-	}
-
-	lock.PID = 1
-
-	rewritten, err := json.Marshal(lock)
-	if err != nil {
-		return ""
-	}
-
-	tmpDir, err := os.MkdirTemp("", "exitbox-ide-lock-*")
-	if err != nil {
-		return ""
-	}
-
-	ideDir := filepath.Join(tmpDir, "ide")
-	if err := os.MkdirAll(ideDir, 0755); err != nil {
-		os.RemoveAll(tmpDir)
-		return ""
-	}
-
-	dstPath := filepath.Join(ideDir, port+".lock")
-	if err := os.WriteFile(dstPath, rewritten, 0644); err != nil {
-		os.RemoveAll(tmpDir)
-		return ""
-	}
-
-	return tmpDir
-}
-
 // ContainerArgs returns the container engine flags needed to enable IDE
 // integration inside the container.
 func (r *IDERelay) ContainerArgs() []string {
@@ -204,9 +397,11 @@ func (r *IDERelay) ContainerArgs() []string {
 		return nil
 	}
 	args := []string{
-		"-e", "CLAUDE_CODE_SSE_PORT=" + r.Port,
 		"-e", "ENABLE_IDE_INTEGRATION=true",
-		"-e", "EXITBOX_IDE_PORT=" + r.Port,
+		"-e", "EXITBOX_IDE_PORTS=" + strings.Join(r.Ports(), ","),
+	}
+	if r.SSEPort != "" {
+		args = append(args, "-e", "CLAUDE_CODE_SSE_PORT="+r.SSEPort)
 	}
 	if r.LockDir != "" {
 		args = append(args, "-v", filepath.Join(r.LockDir, "ide")+":/home/user/.claude/ide:ro")
@@ -214,7 +409,7 @@ func (r *IDERelay) ContainerArgs() []string {
 	return args
 }
 
-// StopIDERelay safely shuts down the relay. It is nil-safe.
+// StopIDERelay safely shuts down all relays. It is nil-safe.
 func StopIDERelay(r *IDERelay) {
 	if r == nil {
 		return
@@ -222,8 +417,14 @@ func StopIDERelay(r *IDERelay) {
 	if r.cancel != nil {
 		r.cancel()
 	}
-	if r.listener != nil {
-		r.listener.Close()
+	if r.done != nil {
+		<-r.done
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for port, s := range r.relays {
+		s.stop()
+		delete(r.relays, port)
 	}
 	if r.LockDir != "" {
 		os.RemoveAll(r.LockDir)
@@ -2,9 +2,11 @@ package run
 
 import (
 	"encoding/json"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -60,93 +62,118 @@ func TestDetectIDE_PortZero(t *testing.T) {
 	}
 }
 
-func TestPrepareIDELockFile_RewritesPID(t *testing.T) {
-	// Create a fake ~/.claude/ide/<port>.lock
-	homeDir := t.TempDir()
-	t.Setenv("HOME", homeDir)
-
-	ideDir := filepath.Join(homeDir, ".claude", "ide")
-	if err := os.MkdirAll(ideDir, 0755); err != nil {
+// writeIDELock writes a host IDE lock file under $HOME/.claude/ide.
+func writeIDELock(t *testing.T, port string, lock ideLockFile) {
+	t.Helper()
+	dir := filepath.Join(os.Getenv("HOME"), ".claude", "ide")
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		t.Fatal(err)
 	}
-
-	lock := ideLockFile{
-		WorkspaceFolders: []string{"/home/user/project"},
-		PID:              42,
-		IDEName:          "VS Code",
-		Transport:        "ws",
-		AuthToken:        "test-auth-token-uuid",
-	}
 	data, err := json.Marshal(lock)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile(filepath.Join(ideDir, "12345.lock"), data, 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, port+".lock"), data, 0644); err != nil {
 		t.Fatal(err)
 	}
+}
+
+// stubProcessAlive treats every PID except dead as a running IDE.
+func stubProcessAlive(t *testing.T, dead int) {
+	t.Helper()
+	orig := processAlive
+	processAlive = func(pid int) bool { return pid != dead }
+	t.Cleanup(func() { processAlive = orig })
+}
+
+func TestFindIDELocks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubProcessAlive(t, 99)
+
+	writeIDELock(t, "1001", ideLockFile{WorkspaceFolders: []string{"/home/u/app"}, PID: 10, IDEName: "VS Code"})
+	writeIDELock(t, "1002", ideLockFile{WorkspaceFolders: []string{"/home/u/app"}, PID: 11, IDEName: "IntelliJ IDEA"})
+	writeIDELock(t, "1003", ideLockFile{WorkspaceFolders: []string{"/home/u/other"}, PID: 12})
+	writeIDELock(t, "1004", ideLockFile{WorkspaceFolders: []string{"/home/u/app"}, PID: 99})
+	writeIDELock(t, "notaport", ideLockFile{WorkspaceFolders: []string{"/home/u/app"}, PID: 13})
+
+	locks := findIDELocks("/home/u/app", "")
+	if len(locks) != 2 || locks["1001"].IDEName != "VS Code" || locks["1002"].IDEName != "IntelliJ IDEA" {
+		t.Errorf("findIDELocks = %+v, want the two live IDEs on the project", locks)
+	}
+
+	// The IDE the agent was launched from is included whatever it has open.
+	locks = findIDELocks("/home/u/app", "1003")
+	if _, ok := locks["1003"]; !ok || len(locks) != 3 {
+		t.Errorf("findIDELocks with SSE port = %+v, want 1001, 1002 and 1003", locks)
+	}
+}
+
+func TestFindIDELocks_NoLockDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if locks := findIDELocks("/home/u/app", ""); len(locks) != 0 {
+		t.Errorf("findIDELocks = %+v, want none", locks)
+	}
+}
 
-	tmpDir := PrepareIDELockFile("12345")
-	if tmpDir == "" {
-		t.Fatal("expected non-empty tmpDir")
+func TestContainerFolders(t *testing.T) {
+	got := containerFolders([]string{
+		"/home/u/app",
+		"/home/u/app/services/api",
+		"/home/u",
+		"/home/u/application",
+		"/srv/other",
+	}, "/home/u/app/")
+	want := "/workspace,/workspace/services/api,/workspace"
+	if strings.Join(got, ",") != want {
+		t.Errorf("containerFolders = %v, want %s", got, want)
 	}
-	defer os.RemoveAll(tmpDir)
+}
 
-	// Read the rewritten lock file
-	rewrittenPath := filepath.Join(tmpDir, "ide", "12345.lock")
-	rewrittenData, err := os.ReadFile(rewrittenPath)
+func TestRewriteIDELock(t *testing.T) {
+	data, err := rewriteIDELock(ideLockFile{
+		WorkspaceFolders: []string{"/home/u/app"},
+		PID:              42,
+		IDEName:          "VS Code",
+		Transport:        "ws",
+		AuthToken:        "test-auth-token-uuid",
+	}, "/home/u/app")
 	if err != nil {
 		t.Fatal(err)
 	}
-
 	var rewritten ideLockFile
-	if err := json.Unmarshal(rewrittenData, &rewritten); err != nil {
+	if err := json.Unmarshal(data, &rewritten); err != nil {
 		t.Fatal(err)
 	}
-
 	if rewritten.PID != 1 {
 		t.Errorf("PID = %d, want 1", rewritten.PID)
 	}
-	if rewritten.AuthToken != "test-auth-token-uuid" {
-		t.Errorf("AuthToken = %q, want %q", rewritten.AuthToken, "test-auth-token-uuid")
+	if rewritten.AuthToken != "test-auth-token-uuid" || rewritten.IDEName != "VS Code" {
+		t.Errorf("rewritten lock lost fields: %+v", rewritten)
 	}
-	if rewritten.IDEName != "VS Code" {
-		t.Errorf("IDEName = %q, want %q", rewritten.IDEName, "VS Code")
+	if len(rewritten.WorkspaceFolders) != 1 || rewritten.WorkspaceFolders[0] != "/workspace" {
+		t.Errorf("WorkspaceFolders = %v, want [/workspace]", rewritten.WorkspaceFolders)
 	}
-	if len(rewritten.WorkspaceFolders) != 1 || rewritten.WorkspaceFolders[0] != "/home/user/project" {
-		t.Errorf("WorkspaceFolders = %v, want [/home/user/project]", rewritten.WorkspaceFolders)
-	}
-}
 
-func TestPrepareIDELockFile_MissingFile(t *testing.T) {
-	homeDir := t.TempDir()
-	t.Setenv("HOME", homeDir)
-
-	result := PrepareIDELockFile("99999")
-	if result != "" {
-		t.Errorf("expected empty string for missing lock file, got %q", result)
-		os.RemoveAll(result)
+	// A lock with no folder visible in the container still points there.
+	data, _ = rewriteIDELock(ideLockFile{WorkspaceFolders: []string{"/srv/other"}}, "/home/u/app")
+	if !strings.Contains(string(data), `"workspaceFolders":["/workspace"]`) {
+		t.Errorf("rewritten lock = %s", data)
 	}
 }
 
 func TestContainerArgs_ContainsExpectedVars(t *testing.T) {
 	lockDir := t.TempDir()
-	ideDir := filepath.Join(lockDir, "ide")
-	if err := os.MkdirAll(ideDir, 0755); err != nil {
-		t.Fatal(err)
-	}
-
 	relay := &IDERelay{
-		Port:    "12345",
+		SSEPort: "12345",
 		LockDir: lockDir,
+		relays:  map[string]*ideSocket{"12345": nil, "23456": nil},
 	}
 
-	args := relay.ContainerArgs()
-	argStr := strings.Join(args, " ")
-
+	argStr := strings.Join(relay.ContainerArgs(), " ")
 	expected := []string{
 		"CLAUDE_CODE_SSE_PORT=12345",
 		"ENABLE_IDE_INTEGRATION=true",
-		"EXITBOX_IDE_PORT=12345",
+		"EXITBOX_IDE_PORTS=12345,23456",
 		filepath.Join(lockDir, "ide") + ":/home/user/.claude/ide:ro",
 	}
 	for _, want := range expected {
@@ -164,17 +191,17 @@ func TestContainerArgs_NilRelay(t *testing.T) {
 	}
 }
 
-func TestContainerArgs_NoLockDir(t *testing.T) {
-	relay := &IDERelay{
-		Port: "12345",
+func TestContainerArgs_NoSSEPort(t *testing.T) {
+	relay := &IDERelay{relays: map[string]*ideSocket{"23456": nil}}
+	argStr := strings.Join(relay.ContainerArgs(), " ")
+	if strings.Contains(argStr, "CLAUDE_CODE_SSE_PORT") {
+		t.Error("ContainerArgs should not set CLAUDE_CODE_SSE_PORT without a launching IDE")
 	}
-	args := relay.ContainerArgs()
-	argStr := strings.Join(args, " ")
 	if strings.Contains(argStr, "/home/user/.claude/ide") {
 		t.Error("ContainerArgs should not contain lock mount when LockDir is empty")
 	}
-	if !strings.Contains(argStr, "EXITBOX_IDE_PORT=12345") {
-		t.Error("ContainerArgs should still contain EXITBOX_IDE_PORT")
+	if !strings.Contains(argStr, "EXITBOX_IDE_PORTS=23456") {
+		t.Error("ContainerArgs should still contain EXITBOX_IDE_PORTS")
 	}
 }
 
@@ -183,59 +210,138 @@ func TestStopIDERelay_NilSafe(t *testing.T) {
 	StopIDERelay(nil)
 }
 
-func TestStopIDERelay_CleansUp(t *testing.T) {
-	tmpDir := t.TempDir()
-	lockDir, err := os.MkdirTemp(tmpDir, "lock-*")
+func TestStartIDERelays_NoIDE(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CLAUDE_CODE_SSE_PORT", "")
+	r := StartIDERelays(t.TempDir(), "claude", "/home/u/app")
+	if r == nil {
+		t.Fatal("expected a relay watching for IDEs opened later")
+	}
+	if ports := r.Ports(); len(ports) != 0 {
+		t.Errorf("Ports() = %v, want none", ports)
+	}
+	StopIDERelay(r)
+
+	t.Setenv("CLAUDE_CODE_SSE_PORT", "12345")
+	if r := StartIDERelays(t.TempDir(), "codex", "/home/u/app"); r != nil {
+		StopIDERelay(r)
+		t.Error("expected nil relay for non-claude agent")
+	}
+}
+
+// startIDEServer starts a TCP echo server standing in for an IDE.
+func startIDEServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatal(err)
 	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
 
-	// Create a real listener to test close
-	socketPath := filepath.Join(tmpDir, "test.sock")
-	listener, err := net.Listen("unix", socketPath)
+func TestStartIDERelays_FollowsLockFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_CODE_SSE_PORT", "")
+	stubProcessAlive(t, -1)
+
+	projectDir := "/home/u/app"
+	vscode := startIDEServer(t)
+	writeIDELock(t, vscode, ideLockFile{WorkspaceFolders: []string{projectDir}, PID: 10, IDEName: "VS Code", AuthToken: "a"})
+
+	socketDir := t.TempDir()
+	relay := StartIDERelays(socketDir, "claude", projectDir)
+	if relay == nil {
+		t.Fatal("expected non-nil relay")
+	}
+	defer StopIDERelay(relay)
+
+	// The relay reaches the IDE.
+	conn, err := net.Dial("unix", filepath.Join(socketDir, "ide-"+vscode+".sock"))
 	if err != nil {
+		t.Fatalf("dial relay socket: %v", err)
+	}
+	_, _ = conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("relay echo = %q, %v", buf, err)
+	}
+	conn.Close()
+	if _, err := os.Stat(filepath.Join(relay.LockDir, "ide", vscode+".lock")); err != nil {
+		t.Errorf("lock file not written: %v", err)
+	}
+
+	// A second IDE opens and the first restarts on a new port.
+	jetbrains := startIDEServer(t)
+	restarted := startIDEServer(t)
+	writeIDELock(t, jetbrains, ideLockFile{WorkspaceFolders: []string{projectDir}, PID: 11, IDEName: "GoLand"})
+	writeIDELock(t, restarted, ideLockFile{WorkspaceFolders: []string{projectDir}, PID: 12, IDEName: "VS Code", AuthToken: "b"})
+	if err := os.Remove(filepath.Join(home, ".claude", "ide", vscode+".lock")); err != nil {
 		t.Fatal(err)
 	}
+	relay.sync()
 
-	relay := &IDERelay{
-		Port:       "12345",
-		SocketPath: socketPath,
-		LockDir:    lockDir,
-		listener:   listener,
-		cancel:     func() {},
+	want := []string{jetbrains, restarted}
+	sort.Strings(want)
+	if got := relay.Ports(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Ports() = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(socketDir, "ide-"+vscode+".sock")); !os.IsNotExist(err) {
+		t.Error("socket of the closed IDE should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(relay.LockDir, "ide", vscode+".lock")); !os.IsNotExist(err) {
+		t.Error("lock file of the closed IDE should be removed")
+	}
+	data, err := os.ReadFile(filepath.Join(relay.LockDir, "ide", restarted+".lock"))
+	if err != nil || !strings.Contains(string(data), `"authToken":"b"`) {
+		t.Errorf("restarted IDE lock = %s, %v", data, err)
 	}
 
+	lockDir := relay.LockDir
 	StopIDERelay(relay)
-
 	if _, err := os.Stat(lockDir); !os.IsNotExist(err) {
 		t.Error("expected LockDir to be removed")
 	}
 }
 
-func TestStartIDERelay_CreatesSocket(t *testing.T) {
-	// Start a TCP server to act as the IDE
-	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
+func TestStartIDESocket_ReplacesPlantedSymlink(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "rc")
+	if err := os.WriteFile(target, []byte("x"), 0600); err != nil {
 		t.Fatal(err)
 	}
-	defer tcpListener.Close()
-
-	_, portStr, _ := net.SplitHostPort(tcpListener.Addr().String())
-
 	socketDir := t.TempDir()
-	relay := StartIDERelay(socketDir, portStr)
-	if relay == nil {
-		t.Fatal("expected non-nil relay")
+	socketPath := filepath.Join(socketDir, "ide-41234.sock")
+	if err := os.Symlink(target, socketPath); err != nil {
+		t.Fatal(err)
 	}
-	defer StopIDERelay(relay)
 
-	// Verify the socket file was created
-	socketPath := filepath.Join(socketDir, "ide.sock")
-	info, err := os.Stat(socketPath)
-	if err != nil {
-		t.Fatalf("socket file not created: %v", err)
+	s := startIDESocket(socketDir, "41234")
+	if s == nil {
+		t.Fatal("expected relay socket")
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("symlink target mode changed: %v, %v", info.Mode(), err)
+	}
+	info, err := os.Lstat(socketPath)
+	if err != nil || info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0666 {
+		t.Errorf("relay socket = %v, %v, want 0666 socket", info.Mode(), err)
 	}
-	if info.Mode()&os.ModeSocket == 0 {
-		t.Error("expected file to be a socket")
+	s.stop()
+	if _, err := os.Lstat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("socket not removed on stop: %v", err)
 	}
 }
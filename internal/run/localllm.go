@@ -0,0 +1,222 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// localLLMDefaultPorts are the ports each server type listens on by default.
+var localLLMDefaultPorts = map[string]int{
+	config.LocalLLMOllama:   11434,
+	config.LocalLLMLMStudio: 1234,
+	config.LocalLLMVLLM:     8000,
+	config.LocalLLMLlamaCpp: 8080,
+}
+
+// localLLMDefaultAPIs are the APIs each server type speaks when api is not
+// set. Ollama serves both the Anthropic Messages API and the OpenAI API;
+// the others are only assumed to be OpenAI-compatible.
+var localLLMDefaultAPIs = map[string][]string{
+	config.LocalLLMOllama:   {config.LocalLLMAPIAnthropic, config.LocalLLMAPIOpenAI},
+	config.LocalLLMLMStudio: {config.LocalLLMAPIOpenAI},
+	config.LocalLLMVLLM:     {config.LocalLLMAPIOpenAI},
+	config.LocalLLMLlamaCpp: {config.LocalLLMAPIOpenAI},
+}
+
+// agentLLMAPIs is the API each agent needs from a local model server.
+var agentLLMAPIs = map[string]string{
+	"claude":   config.LocalLLMAPIAnthropic,
+	"codex":    config.LocalLLMAPIOpenAI,
+	"opencode": config.LocalLLMAPIOpenAI,
+}
+
+// ResolveLocalLLM looks up the endpoint called name in settings.local_llms
+// and fills in defaults. A server type name ("ollama", "lmstudio", ...) that
+// is not configured resolves to that server on its default local port.
+func ResolveLocalLLM(name string, endpoints []config.LocalLLM) (config.LocalLLM, error) {
+	ep := config.LocalLLM{Name: name, Type: name}
+	found := false
+	for _, e := range endpoints {
+		if e.Name == name {
+			ep, found = e, true
+			break
+		}
+	}
+	if !found {
+		if _, ok := localLLMDefaultPorts[name]; !ok {
+			return config.LocalLLM{}, fmt.Errorf("unknown local LLM %q (configure it under settings.local_llms or use one of: %s)", name, strings.Join(localLLMTypes(), ", "))
+		}
+	}
+
+	ep.Type = strings.ToLower(strings.TrimSpace(ep.Type))
+	defaultPort, ok := localLLMDefaultPorts[ep.Type]
+	if !ok {
+		return config.LocalLLM{}, fmt.Errorf("local LLM %q: unknown type %q (expected one of: %s)", name, ep.Type, strings.Join(localLLMTypes(), ", "))
+	}
+	if ep.Host == "" {
+		ep.Host = "127.0.0.1"
+	}
+	if ep.Port == 0 {
+		ep.Port = defaultPort
+	}
+	if ep.Port < 1 || ep.Port > 65535 {
+		return config.LocalLLM{}, fmt.Errorf("local LLM %q: invalid port %d", name, ep.Port)
+	}
+	ep.API = strings.ToLower(strings.TrimSpace(ep.API))
+	switch ep.API {
+	case "", config.LocalLLMAPIAnthropic, config.LocalLLMAPIOpenAI:
+	default:
+		return config.LocalLLM{}, fmt.Errorf("local LLM %q: unknown api %q (expected %s or %s)", name, ep.API, config.LocalLLMAPIAnthropic, config.LocalLLMAPIOpenAI)
+	}
+	return ep, nil
+}
+
+// localLLMAPIs returns the APIs the endpoint speaks.
+func localLLMAPIs(ep config.LocalLLM) []string {
+	if ep.API != "" {
+		return []string{ep.API}
+	}
+	return localLLMDefaultAPIs[ep.Type]
+}
+
+// CheckLocalLLMAgent refuses agent/endpoint pairs that cannot talk to each
+// other, e.g. Claude Code against an OpenAI-only server.
+func CheckLocalLLMAgent(agent string, ep config.LocalLLM) error {
+	want, ok := agentLLMAPIs[agent]
+	if !ok {
+		return fmt.Errorf("%s does not support --local-llm", agent)
+	}
+	for _, api := range localLLMAPIs(ep) {
+		if api == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("local LLM %q (%s) does not speak the %s API that %s needs; set api: %s on it in settings.local_llms if the server supports it",
+		ep.Name, ep.Type, want, agent, want)
+}
+
+func localLLMTypes() []string {
+	types := make([]string, 0, len(localLLMDefaultPorts))
+	for t := range localLLMDefaultPorts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// localLLMURL returns the endpoint's base URL as seen from the host.
+func localLLMURL(ep config.LocalLLM) string {
+	return "http://" + net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port))
+}
+
+// localLLMEnvVars returns the container env flags that point an agent at a
+// local model server reachable at baseURL.
+func localLLMEnvVars(agent string, ep config.LocalLLM, baseURL string) []string {
+	switch agent {
+	case "claude":
+		return []string{
+			"-e", "ANTHROPIC_BASE_URL=" + baseURL,
+			"-e", "ANTHROPIC_AUTH_TOKEN=" + ep.Type,
+			"-e", "ANTHROPIC_API_KEY=",
+		}
+	case "codex":
+		return []string{
+			"-e", "OPENAI_BASE_URL=" + baseURL + "/v1",
+		}
+	case "opencode":
+		if ep.Type == config.LocalLLMOllama {
+			return []string{
+				"-e", "OLLAMA_HOST=" + baseURL,
+			}
+		}
+		return []string{
+			"-e", "OPENAI_BASE_URL=" + baseURL + "/v1",
+		}
+	}
+	return nil
+}
+
+// LocalLLMRelay bridges llm.sock in the IPC directory to a single local
+// model server. The container only reaches that host and port, instead of
+// the whole host gateway.
+type LocalLLMRelay struct {
+	Endpoint   config.LocalLLM
+	SocketPath string
+	listener   net.Listener
+	cancel     context.CancelFunc
+}
+
+// StartLocalLLMRelay creates llm.sock in the IPC socket directory and relays
+// each connection to the endpoint's host and port.
+func StartLocalLLMRelay(ipcSocketDir string, ep config.LocalLLM) *LocalLLMRelay {
+	socketPath := filepath.Join(ipcSocketDir, "llm.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		ui.Warnf("Failed to create local LLM relay socket: %v", err)
+		return nil
+	}
+	if err := os.Chmod(socketPath, 0666); err != nil {
+		ui.Warnf("Failed to chmod local LLM relay socket: %v", err)
+		listener.Close()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := &LocalLLMRelay{
+		Endpoint:   ep,
+		SocketPath: socketPath,
+		listener:   listener,
+		cancel:     cancel,
+	}
+	go serveRelay(ctx, listener, net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port)))
+	return relay
+}
+
+// ContainerArgs returns the container flags that start the in-container end
+// of the relay and point the agent at it.
+func (r *LocalLLMRelay) ContainerArgs(agent string) []string {
+	if r == nil {
+		return nil
+	}
+	port := strconv.Itoa(r.Endpoint.Port)
+	args := []string{"-e", "EXITBOX_LLM_PORT=" + port}
+	return append(args, localLLMEnvVars(agent, r.Endpoint, "http://127.0.0.1:"+port)...)
+}
+
+// StopLocalLLMRelay shuts down the relay. It is nil-safe.
+func StopLocalLLMRelay(r *LocalLLMRelay) {
+	if r == nil {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if r.listener != nil {
+		r.listener.Close()
+	}
+}
@@ -0,0 +1,165 @@
+package run
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestResolveLocalLLM_BuiltinType(t *testing.T) {
+	ep, err := ResolveLocalLLM("ollama", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Type != config.LocalLLMOllama || ep.Host != "127.0.0.1" || ep.Port != 11434 {
+		t.Errorf("got %+v", ep)
+	}
+}
+
+func TestResolveLocalLLM_Configured(t *testing.T) {
+	endpoints := []config.LocalLLM{
+		{Name: "gpu-box", Type: "vllm", Host: "10.0.0.5"},
+		{Name: "ollama", Type: "ollama", Port: 11500},
+	}
+
+	ep, err := ResolveLocalLLM("gpu-box", endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Host != "10.0.0.5" || ep.Port != 8000 {
+		t.Errorf("got %+v, want 10.0.0.5:8000", ep)
+	}
+
+	// A configured entry overrides the built-in defaults of the same name.
+	ep, err = ResolveLocalLLM("ollama", endpoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Port != 11500 {
+		t.Errorf("port = %d, want 11500", ep.Port)
+	}
+}
+
+func TestResolveLocalLLM_Errors(t *testing.T) {
+	if _, err := ResolveLocalLLM("nope", nil); err == nil {
+		t.Error("expected error for unknown endpoint")
+	}
+	if _, err := ResolveLocalLLM("x", []config.LocalLLM{{Name: "x", Type: "tgi"}}); err == nil {
+		t.Error("expected error for unknown type")
+	}
+	if _, err := ResolveLocalLLM("x", []config.LocalLLM{{Name: "x", Type: "vllm", Port: 70000}}); err == nil {
+		t.Error("expected error for invalid port")
+	}
+	if _, err := ResolveLocalLLM("x", []config.LocalLLM{{Name: "x", Type: "vllm", API: "grpc"}}); err == nil {
+		t.Error("expected error for unknown api")
+	}
+}
+
+func TestLocalLLMEnvVars(t *testing.T) {
+	ollama := config.LocalLLM{Type: config.LocalLLMOllama}
+	lmstudio := config.LocalLLM{Type: config.LocalLLMLMStudio, API: config.LocalLLMAPIAnthropic}
+	base := "http://127.0.0.1:1234"
+
+	tests := []struct {
+		agent string
+		ep    config.LocalLLM
+		want  string
+	}{
+		{"claude", lmstudio, "ANTHROPIC_BASE_URL=" + base},
+		{"claude", lmstudio, "ANTHROPIC_AUTH_TOKEN=lmstudio"},
+		{"codex", ollama, "OPENAI_BASE_URL=" + base + "/v1"},
+		{"opencode", ollama, "OLLAMA_HOST=" + base},
+		{"opencode", lmstudio, "OPENAI_BASE_URL=" + base + "/v1"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(localLLMEnvVars(tt.agent, tt.ep, base), " ")
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("localLLMEnvVars(%s, %s) = %q, want it to contain %q", tt.agent, tt.ep.Type, got, tt.want)
+		}
+	}
+	if got := localLLMEnvVars("unknown", ollama, base); got != nil {
+		t.Errorf("expected nil for unknown agent, got %v", got)
+	}
+}
+
+func TestCheckLocalLLMAgent(t *testing.T) {
+	tests := []struct {
+		agent string
+		ep    config.LocalLLM
+		ok    bool
+	}{
+		{"claude", config.LocalLLM{Type: config.LocalLLMOllama}, true},
+		{"codex", config.LocalLLM{Type: config.LocalLLMOllama}, true},
+		{"claude", config.LocalLLM{Type: config.LocalLLMVLLM}, false},
+		{"claude", config.LocalLLM{Type: config.LocalLLMVLLM, API: config.LocalLLMAPIAnthropic}, true},
+		{"codex", config.LocalLLM{Type: config.LocalLLMVLLM}, true},
+		{"opencode", config.LocalLLM{Type: config.LocalLLMLMStudio}, true},
+		{"codex", config.LocalLLM{Type: config.LocalLLMOllama, API: config.LocalLLMAPIAnthropic}, false},
+		{"claude", config.LocalLLM{Type: config.LocalLLMOllama, API: config.LocalLLMAPIOpenAI}, false},
+		{"unknown", config.LocalLLM{Type: config.LocalLLMOllama}, false},
+	}
+	for _, tt := range tests {
+		err := CheckLocalLLMAgent(tt.agent, tt.ep)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckLocalLLMAgent(%s, %s/%q) = %v, want ok=%v", tt.agent, tt.ep.Type, tt.ep.API, err, tt.ok)
+		}
+	}
+}
+
+func TestLocalLLMRelay_Roundtrip(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "pong")
+	}()
+
+	_, portStr, _ := net.SplitHostPort(server.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	ep := config.LocalLLM{Name: "test", Type: config.LocalLLMLlamaCpp, Host: "127.0.0.1", Port: port}
+
+	socketDir := t.TempDir()
+	relay := StartLocalLLMRelay(socketDir, ep)
+	if relay == nil {
+		t.Fatal("expected non-nil relay")
+	}
+	defer StopLocalLLMRelay(relay)
+
+	conn, err := net.Dial("unix", filepath.Join(socketDir, "llm.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "pong" {
+		t.Errorf("got %q, want %q", data, "pong")
+	}
+
+	args := strings.Join(relay.ContainerArgs("codex"), " ")
+	if !strings.Contains(args, "EXITBOX_LLM_PORT="+portStr) || !strings.Contains(args, "OPENAI_BASE_URL=http://127.0.0.1:"+portStr+"/v1") {
+		t.Errorf("unexpected container args: %s", args)
+	}
+}
+
+func TestStopLocalLLMRelay_Nil(t *testing.T) {
+	StopLocalLLMRelay(nil)
+	var r *LocalLLMRelay
+	if args := r.ContainerArgs("claude"); args != nil {
+		t.Errorf("expected nil args, got %v", args)
+	}
+}
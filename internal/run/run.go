@@ -23,14 +23,18 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 	"sync"
 
+	"github.com/cloud-exit/exitbox/internal/audit"
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/generate"
 	"github.com/cloud-exit/exitbox/internal/ipc"
 	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/policy"
 	"github.com/cloud-exit/exitbox/internal/profile"
 	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/redactor"
@@ -63,7 +67,7 @@ type Options struct {
 	Verbose           bool
 	StatusBar         bool
 	Version           string
-	Ollama            bool
+	LocalLLM          string // name of a settings.local_llms endpoint
 	Memory            string
 	CPUs              string
 	Keybindings       string
@@ -97,70 +101,107 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		return 1, fmt.Errorf("failed to resolve active workspace: %w", err)
 	}
 
-	// Detect hosts in agent config and offer to permanently allow them through the firewall.
+	auditLog := &audit.Log{Path: audit.File(), Session: containerName, Agent: opts.Agent, Project: opts.ProjectDir}
+	if activeWorkspace != nil {
+		auditLog.Workspace = activeWorkspace.Workspace.Name
+	}
+	record := auditRecorder(auditLog)
+
+	// Servers configured in the agent's config (e.g. via exitbox generate).
+	var configHosts []string
 	if activeWorkspace != nil && !opts.NoFirewall {
 		agentDir := profile.WorkspaceAgentDir(activeWorkspace.Workspace.Name, opts.Agent)
-		configHosts := generate.ExtractConfigHosts(agentDir, opts.Agent)
-		if len(configHosts) > 0 {
-			// Filter out hosts already in the permanent allowlist.
-			allowlist := config.LoadAllowlistOrDefault()
-			allowed := make(map[string]struct{})
-			for _, d := range allowlist.AllDomains() {
-				allowed[d] = struct{}{}
-			}
-			var newHosts []string
-			for _, h := range configHosts {
-				if _, ok := allowed[h]; !ok {
-					newHosts = append(newHosts, h)
-				}
-			}
-			if len(newHosts) > 0 {
-				shouldAllow := true
-				if term.IsTerminal(int(os.Stdin.Fd())) {
-					fmt.Println("Detected server in agent config:")
-					for _, h := range newHosts {
-						fmt.Printf("  - %s\n", h)
-					}
-					fmt.Print("Allow through firewall? [Y/n]: ")
-					reader := bufio.NewReader(os.Stdin)
-					answer, _ := reader.ReadString('\n')
-					answer = strings.TrimSpace(strings.ToLower(answer))
-					shouldAllow = answer == "" || answer == "y" || answer == "yes"
-				}
-				if shouldAllow {
-					allowlist.Custom = append(allowlist.Custom, newHosts...)
-					if err := config.SaveAllowlist(allowlist); err != nil {
-						ui.Warnf("Failed to save allowlist: %v", err)
-						// Fall back to session-level allow so this run still works.
-						opts.AllowURLs = append(opts.AllowURLs, newHosts...)
-					}
-				}
+		configHosts = generate.ExtractConfigHosts(agentDir, opts.Agent)
+	}
+
+	// AI providers are granted per container unless shared_ai_providers is
+	// set.
+	providerGrant := !opts.NoFirewall && !cfg.Settings.SharedAIProviders
+
+	// The agent config dir is writable from the container, so a server
+	// that appears there is only allowed once the user confirms it. With
+	// per-agent providers it joins this agent's providers, otherwise the
+	// custom allowlist.
+	if len(configHosts) > 0 {
+		allowlist := config.LoadAllowlistOrDefault()
+		if added := grantConfigHosts(allowlist, opts.Agent, providerGrant, configHosts, confirmConfigHosts); len(added) > 0 {
+			if err := config.SaveAllowlist(allowlist); err != nil {
+				ui.Warnf("Failed to save allowlist: %v", err)
+				// Fall back to session-level allow so this run still works.
+				opts.AllowURLs = append(opts.AllowURLs, added...)
 			}
 		}
 	}
 
-	// Ollama mode: route traffic through the firewall to host Ollama.
-	if opts.Ollama {
-		opts.AllowURLs = append(opts.AllowURLs, "host.docker.internal")
-		ui.Infof("Ollama mode: routing traffic through firewall to host")
+	// Local LLM mode: resolve the endpoint now so a typo fails before any
+	// containers or relays are started.
+	var localLLM config.LocalLLM
+	if opts.LocalLLM != "" {
+		localLLM, err = ResolveLocalLLM(opts.LocalLLM, cfg.Settings.LocalLLMs)
+		if err != nil {
+			return 1, err
+		}
+		if err := CheckLocalLLMAgent(opts.Agent, localLLM); err != nil {
+			return 1, err
+		}
+	}
+
+	// Denylist: global entries from allowlist.yaml plus the active
+	// workspace's own deny section.
+	var denylist, workspaceDeny []string
+	if !opts.NoFirewall {
+		denylist = append(denylist, config.LoadAllowlistOrDefault().Deny...)
+		if activeWorkspace != nil {
+			workspaceDeny = activeWorkspace.Workspace.Deny
+			denylist = append(denylist, workspaceDeny...)
+		}
 	}
 
-	// Network setup
+	// Network setup: each session (or workspace) gets its own internal
+	// network so agents cannot reach one another.
+	var agentNetwork string
 	if opts.NoFirewall {
 		// Host networking gives unrestricted internet access and exposes
 		// all container ports directly (e.g. Codex OAuth on 1455).
 		args = append(args, "--network", "host")
 	} else {
-		network.EnsureNetworks(rt)
-		args = append(args, "--network", network.InternalNetwork)
-		if err := network.StartSquidProxy(rt, containerName, opts.AllowURLs); err != nil {
+		workspaceName := ""
+		if activeWorkspace != nil {
+			workspaceName = activeWorkspace.Workspace.Name
+		}
+		agentNetwork = network.AgentNetwork(containerName, workspaceName)
+		args = append(args, "--network", agentNetwork)
+		if len(workspaceDeny) > 0 {
+			if err := network.RegisterSessionDeny(containerName, workspaceDeny); err != nil {
+				ui.Warnf("Failed to register workspace denylist: %v", err)
+			}
+		}
+		if providerGrant {
+			providers := config.LoadAllowlistOrDefault().ProvidersFor(opts.Agent)
+			if err := network.RegisterSessionProviders(containerName, providers); err != nil {
+				return 1, fmt.Errorf("failed to register AI provider access: %w", err)
+			}
+		}
+		if err := network.StartProxy(rt, containerName, agentNetwork, opts.AllowURLs); err != nil {
 			return 1, fmt.Errorf("failed to start firewall (Squid proxy): %w", err)
 		}
-		proxyArgs := network.GetProxyEnvVars(rt)
+		// The address is fixed up front so the provider grant is in the
+		// firewall before the agent makes its first request.
+		ip, err := network.SessionIP(containerName)
+		if err != nil {
+			return 1, err
+		}
+		args = append(args, "--ip", ip)
+		proxyArgs := network.GetProxyEnvVars(rt, agentNetwork)
 		args = append(args, proxyArgs...)
 	}
 
 	// IPC server for runtime domain allow requests.
+	approver := sessionApprover(rt, containerName, activeWorkspace)
+	rules := &policy.Engine{ProjectDir: opts.ProjectDir, Agent: opts.Agent}
+	if activeWorkspace != nil {
+		rules.Workspace = activeWorkspace.Workspace.Name
+	}
 	var ipcServer *ipc.Server
 	if !opts.NoFirewall {
 		var ipcErr error
@@ -168,39 +209,74 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		if ipcErr != nil {
 			ui.Warnf("Failed to start IPC server: %v", ipcErr)
 		} else {
-			ipcServer.Handle("allow_domain", ipc.NewAllowDomainHandler(ipc.AllowDomainHandlerConfig{
+			ipcServer.HandleInteractive("allow_domain", ipc.NewAllowDomainHandler(ipc.AllowDomainHandlerConfig{
 				Runtime:       rt,
 				ContainerName: containerName,
+				Denylist:      denylist,
+				Approver:      approver,
+				Policy:        rules,
 			}))
-			ipcServer.Start()
+			ipcServer.OnQueueChange(promptQueueStatus(rt, containerName, ipcServer.Done()))
+			ipcServer.SetAuditLog(auditLog)
+			if logFile, err := openIPCLog(); err != nil {
+				ui.Warnf("Failed to open IPC log: %v", err)
+			} else {
+				ipcServer.SetLogOutput(logFile)
+				defer logFile.Close()
+			}
 			defer ipcServer.Stop()
+			// Each helper gets its own token, so a helper can only send the
+			// requests it was built for.
+			ipcServer.Scope("exitbox-allow", "allow_domain")
+			ipcServer.Scope("exitbox-kv", "kv_get", "kv_set", "kv_delete", "kv_list")
+			ipcServer.Scope("exitbox-vault", "vault_get", "vault_list", "vault_set")
+			ipcServer.Scope("exitbox-notify", "notify")
+			ipcServer.Scope("exitbox-clip", "clip_copy", "clip_paste")
+			ipcServer.Scope("exitbox-open", "open_url")
+			ipcServer.Scope("exitbox-fetch", "request_file")
+			ipcServer.Scope("exitbox-tool", "tool_request")
+			ipcServer.Scope("exitbox-host", "host_action", "host_action_output", "host_action_list")
 		}
 	}
 
-	// IDE relay: bridge the host IDE's WebSocket to a Unix socket in the
-	// IPC directory so the containerised agent can reach it without host
-	// network access.
+	// IDE relay: bridge the WebSocket of every host IDE open on the project
+	// to a Unix socket in the IPC directory so the containerised agent can
+	// reach them without host network access.
 	var ideRelay *IDERelay
-	if idePort, ok := DetectIDE(opts.Agent); ok {
+	if !opts.NoFirewall && ipcServer != nil {
+		ideRelay = StartIDERelays(ipcServer.SocketDir(), opts.Agent, opts.ProjectDir)
+		args = append(args, ideRelay.ContainerArgs()...)
+	} else if idePort, ok := DetectIDE(opts.Agent); ok && opts.NoFirewall {
+		// Host network: 127.0.0.1 is the host loopback, no relay needed.
+		args = append(args,
+			"-e", "CLAUDE_CODE_SSE_PORT="+idePort,
+			"-e", "ENABLE_IDE_INTEGRATION=true",
+		)
+		ideLockDir := filepath.Join(os.Getenv("HOME"), ".claude", "ide")
+		if info, statErr := os.Stat(ideLockDir); statErr == nil && info.IsDir() {
+			args = append(args, "-v", ideLockDir+":/home/user/.claude/ide:ro")
+		}
+	}
+	defer StopIDERelay(ideRelay)
+
+	// Local LLM relay: expose exactly one host port to the container via a
+	// Unix socket, rather than opening the host gateway in the firewall.
+	var llmRelay *LocalLLMRelay
+	if opts.LocalLLM != "" {
 		if opts.NoFirewall {
-			// Host network: 127.0.0.1 is the host loopback, no relay needed.
-			args = append(args,
-				"-e", "CLAUDE_CODE_SSE_PORT="+idePort,
-				"-e", "ENABLE_IDE_INTEGRATION=true",
-			)
-			ideLockDir := filepath.Join(os.Getenv("HOME"), ".claude", "ide")
-			if info, statErr := os.Stat(ideLockDir); statErr == nil && info.IsDir() {
-				args = append(args, "-v", ideLockDir+":/home/user/.claude/ide:ro")
-			}
+			// Host network: the endpoint is reachable directly.
+			args = append(args, localLLMEnvVars(opts.Agent, localLLM, localLLMURL(localLLM))...)
 		} else if ipcServer != nil {
-			ideRelay = StartIDERelay(ipcServer.SocketDir(), idePort)
-			if ideRelay != nil {
-				ideRelay.LockDir = PrepareIDELockFile(idePort)
-				args = append(args, ideRelay.ContainerArgs()...)
-			}
+			llmRelay = StartLocalLLMRelay(ipcServer.SocketDir(), localLLM)
+			args = append(args, llmRelay.ContainerArgs(opts.Agent)...)
+		}
+		if opts.NoFirewall || llmRelay != nil {
+			ui.Infof("Local LLM: %s (%s on %s:%d)", localLLM.Name, localLLM.Type, localLLM.Host, localLLM.Port)
+		} else {
+			ui.Warnf("Local LLM relay unavailable; %s will not be reachable", localLLM.Name)
 		}
 	}
-	defer StopIDERelay(ideRelay)
+	defer StopLocalLLMRelay(llmRelay)
 
 	// Full git support: mount SSH_AUTH_SOCK socket and .gitconfig so that
 	// git/ssh inside the container can request signatures (without exposing
@@ -223,7 +299,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 
 	// Ensure squid cleanup runs on ALL return paths (including early errors).
 	defer func() {
-		if len(opts.AllowURLs) > 0 {
+		if !opts.NoFirewall {
 			network.RemoveSessionURLs(rt, containerName)
 		}
 		network.CleanupSquidIfUnused(rt)
@@ -246,6 +322,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		mountMode = ":ro"
 	}
 	args = append(args, "-w", "/workspace", "-v", opts.ProjectDir+":/workspace"+mountMode)
+	record(audit.Event{Type: audit.Mount, Target: opts.ProjectDir, Outcome: audit.OK, Detail: "/workspace" + mountMode})
 
 	// Non-root
 	args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
@@ -260,16 +337,25 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		dir = strings.TrimSuffix(dir, "/")
 		base := filepath.Base(dir)
 		args = append(args, "-v", dir+":/workspace/"+base)
+		record(audit.Event{Type: audit.Mount, Target: dir, Outcome: audit.OK, Detail: "/workspace/" + base})
 	}
 
-	// Mount config.yaml individually (read-write for in-container workspace switching).
-	configFile := filepath.Join(config.Home, "config.yaml")
+	configFile := config.ConfigFile()
 	if _, statErr := os.Stat(configFile); statErr != nil {
 		if saveErr := config.SaveConfig(cfg); saveErr != nil {
-			ui.Warnf("Failed to create config file for mount: %v", saveErr)
+			ui.Warnf("Failed to create config file: %v", saveErr)
 		}
 	}
-	args = append(args, "-v", configFile+":/home/user/.exitbox-config/config.yaml")
+
+	// Mount a copy of config.yaml with only the workspace selection
+	// (read-write for in-container workspace switching); the real file
+	// holds security settings the agent must not edit.
+	configView := filepath.Join(config.Cache, "sessions", containerName, "config.yaml")
+	if err := writeContainerConfig(cfg, configView); err != nil {
+		return 1, fmt.Errorf("failed to write container config: %w", err)
+	}
+	defer syncContainerConfig(configView)
+	args = append(args, "-v", configView+":/home/user/.exitbox-config/config.yaml")
 
 	if activeWorkspace != nil {
 		if err := profile.EnsureAgentConfig(activeWorkspace.Workspace.Name, opts.Agent); err != nil {
@@ -297,6 +383,11 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		ipcServer.Handle("kv_list", ipc.NewKVListHandler(kvCfg))
 	}
 
+	// Notifications from exitbox-notify.
+	if ipcServer != nil {
+		ipcServer.Handle("notify", ipc.NewNotifyHandler(notifyConfig(rt, containerName, opts)))
+	}
+
 	// Register vault IPC handlers when vault is enabled for the workspace.
 	var vaultState *ipc.VaultState
 	if activeWorkspace != nil && activeWorkspace.Workspace.Vault.Enabled && ipcServer != nil {
@@ -305,11 +396,13 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 			Runtime:       rt,
 			ContainerName: containerName,
 			WorkspaceName: activeWorkspace.Workspace.Name,
+			Approver:      approver,
+			Policy:        rules,
 		}
-		ipcServer.Handle("vault_get", ipc.NewVaultGetHandler(vCfg, vaultState))
-		ipcServer.Handle("vault_list", ipc.NewVaultListHandler(vCfg, vaultState))
+		ipcServer.HandleInteractive("vault_get", ipc.NewVaultGetHandler(vCfg, vaultState))
+		ipcServer.HandleInteractive("vault_list", ipc.NewVaultListHandler(vCfg, vaultState))
 		if !activeWorkspace.Workspace.Vault.ReadOnly {
-			ipcServer.Handle("vault_set", ipc.NewVaultSetHandler(vCfg, vaultState))
+			ipcServer.HandleInteractive("vault_set", ipc.NewVaultSetHandler(vCfg, vaultState))
 		}
 	}
 	defer func() {
@@ -318,6 +411,104 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		}
 	}()
 
+	// Clipboard bridge for exitbox-clip. Vault secrets are redacted from
+	// clipboard text in both directions.
+	if ipcServer != nil {
+		clipCfg := ipc.ClipHandlerConfig{
+			Runtime:       rt,
+			ContainerName: containerName,
+			Approver:      approver,
+			Policy:        rules,
+		}
+		if vaultState != nil {
+			clipCfg.Secrets = vaultState.KnownSecrets
+		}
+		ipcServer.HandleInteractive("clip_copy", ipc.NewClipCopyHandler(clipCfg))
+		ipcServer.HandleInteractive("clip_paste", ipc.NewClipPasteHandler(clipCfg))
+	}
+
+	// Host actions for exitbox-host. They are defined only in host.yaml
+	// files, which the container cannot see or change.
+	hostActionState := &ipc.HostActionState{}
+	defer hostActionState.Stop()
+	if ipcServer != nil {
+		workspaceName := ""
+		if activeWorkspace != nil {
+			workspaceName = activeWorkspace.Workspace.Name
+		}
+		actionCfg := ipc.HostActionHandlerConfig{
+			Runtime:       rt,
+			ContainerName: containerName,
+			Actions:       hostActions(workspaceName, opts.ProjectDir),
+			Approver:      approver,
+			Policy:        rules,
+		}
+		if vaultState != nil {
+			actionCfg.Secrets = vaultState.KnownSecrets
+		}
+		ipcServer.HandleInteractive("host_action", ipc.NewHostActionHandler(actionCfg, hostActionState))
+		ipcServer.Handle("host_action_output", ipc.NewHostActionOutputHandler(hostActionState))
+		ipcServer.Handle("host_action_list", ipc.NewHostActionListHandler(actionCfg))
+	}
+
+	// Host browser for exitbox-open, which the image installs as xdg-open
+	// and $BROWSER. OAuth redirects to localhost are relayed back in.
+	var callbackRelay *CallbackRelay
+	if ipcServer != nil {
+		urlCfg := ipc.OpenURLHandlerConfig{
+			Runtime:       rt,
+			ContainerName: containerName,
+			Denylist:      denylist,
+			Allowed:       func() []string { return network.SessionAllowed(containerName) },
+			Approver:      approver,
+			Policy:        rules,
+		}
+		if hc, err := config.LoadHostConfig(); err != nil {
+			ui.Warnf("Failed to load host config, OAuth callback relay disabled: %v", err)
+		} else if !hc.OpenURL.NoCallbackRelay {
+			callbackRelay = NewCallbackRelay(rt, containerName)
+			urlCfg.RelayFunc = callbackRelay.Listen
+		}
+		ipcServer.HandleInteractive("open_url", ipc.NewOpenURLHandler(urlCfg))
+	}
+	defer StopCallbackRelay(callbackRelay)
+
+	// Host files for exitbox-fetch land in an inbox inside the IPC mount,
+	// which is removed with the socket directory when the session ends.
+	// The handler creates and opens the inbox now, before the container
+	// starts and could plant a link in its place.
+	if ipcServer != nil {
+		ipcServer.HandleInteractive("request_file", ipc.NewFetchHandler(ipc.FetchHandlerConfig{
+			Runtime:           rt,
+			ContainerName:     containerName,
+			InboxDir:          filepath.Join(ipcServer.SocketDir(), "inbox"),
+			ContainerInboxDir: "/run/exitbox/inbox",
+			Approver:          approver,
+			Policy:            rules,
+		}))
+	}
+
+	// Packages requested with exitbox-tool are added to the workspace and
+	// installed when the image is rebuilt on the next start.
+	if ipcServer != nil && activeWorkspace != nil {
+		ipcServer.HandleInteractive("tool_request", ipc.NewToolHandler(ipc.ToolHandlerConfig{
+			Runtime:       rt,
+			ContainerName: containerName,
+			WorkspaceName: activeWorkspace.Workspace.Name,
+			Approver:      approver,
+			Policy:        rules,
+			OnQueued:      rebuildPendingStatus(rt, containerName),
+		}))
+	}
+
+	// Every handler is registered; start serving the helpers.
+	if ipcServer != nil {
+		if err := ipcServer.WriteTokens(); err != nil {
+			return 1, fmt.Errorf("failed to write IPC tokens: %w", err)
+		}
+		ipcServer.Start()
+	}
+
 	// Vault env var and .env masking
 	if activeWorkspace != nil && activeWorkspace.Workspace.Vault.Enabled {
 		args = append(args, "-e", "EXITBOX_VAULT_ENABLED=true")
@@ -333,7 +524,9 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 				continue
 			}
 			base := filepath.Base(f)
-			if isEnvSampleFile(base) {
+			// .env.vault maps variables to vault keys for
+			// "exitbox-vault exec --from" and holds no values.
+			if isEnvSampleFile(base) || base == ".env.vault" {
 				continue
 			}
 			rel, relErr := filepath.Rel(opts.ProjectDir, f)
@@ -386,9 +579,6 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 	if opts.RTK {
 		args = append(args, "-e", "EXITBOX_RTK=true")
 	}
-	if opts.Ollama {
-		args = append(args, ollamaEnvVars(opts.Agent)...)
-	}
 
 	// Security options
 	args = append(args,
@@ -400,6 +590,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 	if ipcServer != nil {
 		args = append(args, "-v", ipcServer.SocketDir()+":/run/exitbox")
 		args = append(args, "-e", "EXITBOX_IPC_SOCKET=/run/exitbox/host.sock")
+		args = append(args, "-e", "BROWSER=exitbox-open")
 	}
 
 	// Image
@@ -425,6 +616,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		c.Stderr = os.Stderr
 	}
 
+	record(audit.Event{Type: audit.SessionStart, Target: imageName, Outcome: audit.OK, Detail: sessionFlags(opts)})
 	err = c.Run()
 
 	exitCode := 0
@@ -435,10 +627,39 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 			exitCode = 1
 		}
 	}
+	record(audit.Event{Type: audit.SessionEnd, Outcome: audit.OK, Detail: fmt.Sprintf("exit %d", exitCode)})
 
 	return exitCode, nil
 }
 
+// auditRecorder returns a func that appends events to l. A log that cannot
+// be written is reported once rather than on every event.
+func auditRecorder(l *audit.Log) func(audit.Event) {
+	warned := false
+	return func(ev audit.Event) {
+		if err := l.Record(ev); err != nil && !warned {
+			warned = true
+			ui.Warnf("Failed to write audit log: %v", err)
+		}
+	}
+}
+
+// sessionFlags summarises the security-relevant run options for the audit
+// log.
+func sessionFlags(opts Options) string {
+	var flags []string
+	if opts.NoFirewall {
+		flags = append(flags, "no-firewall")
+	}
+	if opts.ReadOnly {
+		flags = append(flags, "read-only")
+	}
+	if opts.NoEnv {
+		flags = append(flags, "no-env")
+	}
+	return strings.Join(flags, ",")
+}
+
 func expandPath(dir, projectDir string) string {
 	if strings.HasPrefix(dir, "~/") {
 		dir = filepath.Join(os.Getenv("HOME"), dir[2:])
@@ -456,28 +677,6 @@ func getEnvOr(key, def string) string {
 	return def
 }
 
-// ollamaEnvVars returns the container env flags to point an agent at host Ollama.
-func ollamaEnvVars(agent string) []string {
-	ollamaURL := "http://host.docker.internal:11434"
-	switch agent {
-	case "claude":
-		return []string{
-			"-e", "ANTHROPIC_BASE_URL=" + ollamaURL,
-			"-e", "ANTHROPIC_AUTH_TOKEN=ollama",
-			"-e", "ANTHROPIC_API_KEY=",
-		}
-	case "codex":
-		return []string{
-			"-e", "OPENAI_BASE_URL=" + ollamaURL + "/v1",
-		}
-	case "opencode":
-		return []string{
-			"-e", "OLLAMA_HOST=" + ollamaURL,
-		}
-	}
-	return nil
-}
-
 // redactorWriter wraps an io.Writer and filters output through a redactor.
 type redactorWriter struct {
 	w  io.Writer
@@ -510,7 +709,8 @@ func isReservedEnvVar(key string) bool {
 		"EXITBOX_VAULT_ENABLED":   true,
 		"EXITBOX_VAULT_READONLY":  true,
 		"EXITBOX_RTK":             true,
-		"EXITBOX_IDE_PORT":        true,
+		"EXITBOX_IDE_PORTS":       true,
+		"EXITBOX_LLM_PORT":        true,
 		"CLAUDE_CODE_SSE_PORT":    true,
 		"ENABLE_IDE_INTEGRATION":  true,
 		"TERM":                    true,
@@ -529,3 +729,194 @@ func isReservedEnvVar(key string) bool {
 	}
 	return reserved[key]
 }
+
+// grantConfigHosts adds the hosts of the agent config that the session
+// would not reach yet to allowlist, if confirm approves them, and returns
+// the hosts added. With perAgent they become the agent's own providers,
+// otherwise custom domains shared by every agent.
+func grantConfigHosts(allowlist *config.Allowlist, agent string, perAgent bool, hosts []string, confirm func([]string) bool) []string {
+	granted := allowlist.AllDomains()
+	if perAgent {
+		granted = append(allowlist.SharedDomains(), allowlist.ProvidersFor(agent)...)
+	}
+	known := make(map[string]struct{}, len(granted))
+	for _, d := range granted {
+		known[d] = struct{}{}
+	}
+	var newHosts []string
+	for _, h := range hosts {
+		if _, ok := known[h]; !ok {
+			newHosts = append(newHosts, h)
+		}
+	}
+	if len(newHosts) == 0 || !confirm(newHosts) {
+		return nil
+	}
+	if perAgent {
+		if allowlist.AgentProviders == nil {
+			allowlist.AgentProviders = make(map[string][]string)
+		}
+		providers := append([]string(nil), allowlist.ProvidersFor(agent)...)
+		allowlist.AgentProviders[agent] = append(providers, newHosts...)
+	} else {
+		allowlist.Custom = append(allowlist.Custom, newHosts...)
+	}
+	return newHosts
+}
+
+// confirmConfigHosts asks on the terminal whether to allow hosts found in
+// the agent config. Without a terminal nobody can confirm, so they are not
+// allowed.
+func confirmConfigHosts(hosts []string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		ui.Warnf("Not allowing servers from the agent config without confirmation: %s", strings.Join(hosts, ", "))
+		return false
+	}
+	fmt.Println("Detected server in agent config:")
+	for _, h := range hosts {
+		fmt.Printf("  - %s\n", h)
+	}
+	fmt.Print("Allow through firewall? [Y/n]: ")
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// promptQueueStatus returns an IPC queue callback that publishes the number
+// of pending prompts to the container's tmux status bar. Updates are sent
+// from a single goroutine and coalesced, so the callback never blocks. The
+// goroutine exits when done is closed.
+func promptQueueStatus(rt container.Runtime, containerName string, done <-chan struct{}) func(int) {
+	latest := make(chan int, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case n := <-latest:
+				_ = exec.Command(container.Cmd(rt), "exec", containerName,
+					"tmux", "set", "-g", "@exitbox_pending", strconv.Itoa(n)).Run()
+			}
+		}
+	}()
+	return func(n int) {
+		select {
+		case <-latest:
+		default:
+		}
+		latest <- n
+	}
+}
+
+// writeContainerConfig writes the container's view of cfg to path.
+func writeContainerConfig(cfg *config.Config, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return config.SaveConfigTo(config.ContainerConfig(cfg), path)
+}
+
+// syncContainerConfig carries a workspace selection made in the container
+// back into config.yaml and removes the container's copy.
+func syncContainerConfig(path string) {
+	defer os.RemoveAll(filepath.Dir(path))
+	view, err := config.LoadContainerConfig(path)
+	if err != nil {
+		return
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return
+	}
+	if config.ApplyContainerSelection(cfg, view) {
+		if err := config.SaveConfig(cfg); err != nil {
+			ui.Warnf("Failed to save workspace selection: %v", err)
+		}
+	}
+}
+
+// hostActions returns a loader for the session's host actions: those in
+// host.yaml, then in the workspace's and the project's host.yaml, where a
+// later file overrides an action of the same name. Dirs are resolved
+// against the project directory.
+func hostActions(workspace, projectDir string) func() (map[string]config.HostAction, error) {
+	files := []string{config.HostFile()}
+	if workspace != "" {
+		files = append(files, filepath.Join(config.Home, "profiles", "global", workspace, "host.yaml"))
+	}
+	files = append(files, filepath.Join(project.ParentDir(projectDir), "host.yaml"))
+
+	return func() (map[string]config.HostAction, error) {
+		actions := make(map[string]config.HostAction)
+		for _, f := range files {
+			hc, err := config.LoadHostConfigFrom(f)
+			if err != nil {
+				return nil, err
+			}
+			for name, a := range hc.HostActions {
+				a.Dir = expandPath(a.Dir, projectDir)
+				actions[name] = a
+			}
+		}
+		return actions, nil
+	}
+}
+
+// rebuildPendingStatus returns a callback that marks the image as out of
+// date in the tmux status bar.
+func rebuildPendingStatus(rt container.Runtime, containerName string) func() {
+	return func() {
+		_ = exec.Command(container.Cmd(rt), "exec", containerName,
+			"tmux", "set", "-g", "@exitbox_rebuild", "1").Run()
+	}
+}
+
+// notifyConfig builds the notify handler's settings from host.yaml.
+func notifyConfig(rt container.Runtime, containerName string, opts Options) ipc.NotifyHandlerConfig {
+	nc := ipc.NotifyHandlerConfig{
+		Runtime:       rt,
+		ContainerName: containerName,
+		Agent:         opts.Agent,
+		ProjectDir:    opts.ProjectDir,
+	}
+	hc, err := config.LoadHostConfig()
+	if err != nil {
+		ui.Warnf("Failed to load host config, using default notifications: %v", err)
+		return nc
+	}
+	nc.Methods = hc.Notify.Methods
+	nc.Hook = hc.Notify.Hook
+	if hc.Notify.Interval != "" {
+		d, err := time.ParseDuration(hc.Notify.Interval)
+		if err != nil {
+			ui.Warnf("Invalid notify interval %q, using %s", hc.Notify.Interval, ipc.DefaultNotifyInterval)
+		} else {
+			nc.Interval = d
+		}
+	}
+	return nc
+}
+
+// openIPCLog opens the log of rejected IPC requests for appending.
+func openIPCLog() (*os.File, error) {
+	if err := os.MkdirAll(config.Cache, 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filepath.Join(config.Cache, "ipc.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
+
+// sessionApprover returns the approval backend chosen by the workspace,
+// falling back to tmux popups if the setting is invalid.
+func sessionApprover(rt container.Runtime, containerName string, ws *profile.ResolvedWorkspace) ipc.Approver {
+	kind := ""
+	if ws != nil {
+		kind = ws.Workspace.Approver
+	}
+	approver, err := ipc.NewApprover(kind, rt, containerName)
+	if err != nil {
+		ui.Warnf("Workspace %s: %v; using tmux popups", ws.Workspace.Name, err)
+		approver, _ = ipc.NewApprover(ipc.ApproverTmux, rt, containerName)
+	}
+	return approver
+}
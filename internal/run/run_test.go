@@ -4,7 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
 )
 
 func TestExpandPath_Absolute(t *testing.T) {
@@ -90,7 +93,7 @@ func TestIsReservedEnvVar(t *testing.T) {
 		"EXITBOX_KEYBINDINGS",
 		"EXITBOX_VAULT_ENABLED",
 		"EXITBOX_VAULT_READONLY",
-		"EXITBOX_IDE_PORT",
+		"EXITBOX_IDE_PORTS",
 		"CLAUDE_CODE_SSE_PORT",
 		"ENABLE_IDE_INTEGRATION",
 		"TERM",
@@ -124,3 +127,42 @@ func TestIsReservedEnvVar_NotReserved(t *testing.T) {
 		}
 	}
 }
+
+func TestGrantConfigHosts(t *testing.T) {
+	for _, perAgent := range []bool{true, false} {
+		allowlist := config.DefaultAllowlist()
+		granted := func() []string {
+			if perAgent {
+				return allowlist.ProvidersFor("claude")
+			}
+			return allowlist.AllDomains()
+		}
+		known := allowlist.ProvidersFor("claude")[0]
+
+		var asked []string
+		deny := func(hosts []string) bool { asked = hosts; return false }
+		if added := grantConfigHosts(allowlist, "claude", perAgent, []string{known, "llm.attacker.example"}, deny); added != nil {
+			t.Errorf("perAgent=%v: declined hosts added: %v", perAgent, added)
+		}
+		if len(asked) != 1 || asked[0] != "llm.attacker.example" {
+			t.Errorf("perAgent=%v: asked about %v, want only the new host", perAgent, asked)
+		}
+		if slices.Contains(granted(), "llm.attacker.example") {
+			t.Errorf("perAgent=%v: new config host granted without confirmation", perAgent)
+		}
+
+		allow := func([]string) bool { return true }
+		if added := grantConfigHosts(allowlist, "claude", perAgent, []string{"llm.internal"}, allow); len(added) != 1 {
+			t.Errorf("perAgent=%v: confirmed host not added: %v", perAgent, added)
+		}
+		if !slices.Contains(granted(), "llm.internal") || !slices.Contains(granted(), known) {
+			t.Errorf("perAgent=%v: granted = %v, want llm.internal and %s", perAgent, granted(), known)
+		}
+		if perAgent && slices.Contains(allowlist.ProvidersFor("codex"), "llm.internal") {
+			t.Error("confirmed host granted to another agent")
+		}
+		if added := grantConfigHosts(allowlist, "claude", perAgent, []string{"llm.internal"}, deny); added != nil {
+			t.Errorf("perAgent=%v: granted host asked again", perAgent)
+		}
+	}
+}
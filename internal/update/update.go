@@ -258,10 +258,12 @@ func PromptViaTmux(containerCmd, containerName, currentVersion, latestVersion st
 }
 
 // RunUpdatePopup starts a background goroutine that checks for updates and,
-// if one is available, waits for the container's tmux to be ready then shows
-// a popup. If the user approves, wantUpdate is set to 1 atomically.
-// latestVersion is written when an update is found, regardless of approval.
-func RunUpdatePopup(containerCmd, containerName, currentVersion string, wantUpdate *atomic.Int32, latestVersion *atomic.Value) {
+// if one is available, asks the user whether to update after the session.
+// ask shows the question; when nil it is a tmux popup in the container,
+// shown once the container's tmux is ready. If the user approves,
+// wantUpdate is set to 1 atomically. latestVersion is written when an
+// update is found, regardless of approval.
+func RunUpdatePopup(containerCmd, containerName, currentVersion string, ask func(current, latest string) (bool, error), wantUpdate *atomic.Int32, latestVersion *atomic.Value) {
 	go func() {
 		// Check for update with a 5s timeout.
 		result := <-AsyncCheck(currentVersion, 5*time.Second)
@@ -270,6 +272,13 @@ func RunUpdatePopup(containerCmd, containerName, currentVersion string, wantUpda
 		}
 		latestVersion.Store(result.Latest)
 
+		if ask != nil {
+			if approved, err := ask(currentVersion, result.Latest); err == nil && approved {
+				wantUpdate.Store(1)
+			}
+			return
+		}
+
 		// Wait for tmux to be ready inside the container (poll for up to 30s).
 		ready := false
 		for i := 0; i < 15; i++ {
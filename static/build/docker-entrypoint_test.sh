@@ -57,7 +57,7 @@ trap 'rm -rf "$TEST_TMPDIR"' EXIT
 unset EXITBOX_PROJECT_KEY EXITBOX_WORKSPACE_NAME EXITBOX_WORKSPACE_SCOPE
 unset EXITBOX_AGENT EXITBOX_AUTO_RESUME EXITBOX_IPC_SOCKET EXITBOX_KEYBINDINGS
 unset EXITBOX_SESSION_NAME EXITBOX_RESUME_TOKEN EXITBOX_VAULT_ENABLED
-unset EXITBOX_VAULT_READONLY EXITBOX_RTK
+unset EXITBOX_VAULT_READONLY EXITBOX_RTK EXITBOX_LLM_PORT
 
 # Extract functions from the entrypoint using awk (handles nested braces)
 extract_func() {
@@ -876,7 +876,7 @@ test_ide_relay_skips_non_claude() {
     result="$(
         AGENT="codex"
         ENABLE_IDE_INTEGRATION="true"
-        EXITBOX_IDE_PORT="12345"
+        EXITBOX_IDE_PORTS="12345"
         IDE_RELAY_PID=""
         eval "$IDE_RELAY_FUNC"
         start_ide_relay
@@ -890,7 +890,7 @@ test_ide_relay_skips_disabled() {
     result="$(
         AGENT="claude"
         unset ENABLE_IDE_INTEGRATION
-        EXITBOX_IDE_PORT="12345"
+        EXITBOX_IDE_PORTS="12345"
         IDE_RELAY_PID=""
         eval "$IDE_RELAY_FUNC"
         start_ide_relay
@@ -899,32 +899,26 @@ test_ide_relay_skips_disabled() {
     assert_eq "ide_relay skips when disabled" "PID=" "$result"
 }
 
-test_ide_relay_skips_no_port() {
+test_ide_relay_watches_without_ide() {
+    # No IDE is open yet: the watcher still starts so a later one is relayed.
     local result
     result="$(
         AGENT="claude"
         ENABLE_IDE_INTEGRATION="true"
-        unset EXITBOX_IDE_PORT
+        EXITBOX_IDE_PORTS=""
         IDE_RELAY_PID=""
+        socat() { :; }
+        ide_relay_watch() { sleep 5; }
         eval "$IDE_RELAY_FUNC"
         start_ide_relay
-        echo "PID=$IDE_RELAY_PID"
+        if [[ -n "$IDE_RELAY_PID" ]]; then
+            kill "$IDE_RELAY_PID" 2>/dev/null
+            echo "started"
+        else
+            echo "skipped"
+        fi
     )" 2>/dev/null
-    assert_eq "ide_relay skips when no port" "PID=" "$result"
-}
-
-test_ide_relay_skips_missing_socket() {
-    local result
-    result="$(
-        AGENT="claude"
-        ENABLE_IDE_INTEGRATION="true"
-        EXITBOX_IDE_PORT="12345"
-        IDE_RELAY_PID=""
-        eval "$IDE_RELAY_FUNC"
-        start_ide_relay
-        echo "PID=$IDE_RELAY_PID"
-    )" 2>/dev/null
-    assert_eq "ide_relay skips when socket missing" "PID=" "$result"
+    assert_eq "ide_relay watches without IDE" "started" "$result"
 }
 
 test_cleanup_ide_relay_noop() {
@@ -941,10 +935,57 @@ test_cleanup_ide_relay_noop() {
 
 test_ide_relay_skips_non_claude
 test_ide_relay_skips_disabled
-test_ide_relay_skips_no_port
-test_ide_relay_skips_missing_socket
+test_ide_relay_watches_without_ide
 test_cleanup_ide_relay_noop
 
+# ============================================================================
+# Local LLM relay guard-condition tests
+# ============================================================================
+echo ""
+echo "Testing local LLM relay guard conditions..."
+
+LLM_RELAY_FUNC="$(extract_func start_llm_relay)"
+CLEANUP_LLM_FUNC="$(extract_func cleanup_llm_relay)"
+
+test_llm_relay_skips_no_port() {
+    local result
+    result="$(
+        unset EXITBOX_LLM_PORT
+        LLM_RELAY_PID=""
+        eval "$LLM_RELAY_FUNC"
+        start_llm_relay
+        echo "PID=$LLM_RELAY_PID"
+    )" 2>/dev/null
+    assert_eq "llm_relay skips when no port" "PID=" "$result"
+}
+
+test_llm_relay_skips_missing_socket() {
+    local result
+    result="$(
+        EXITBOX_LLM_PORT="11434"
+        LLM_RELAY_PID=""
+        eval "$LLM_RELAY_FUNC"
+        start_llm_relay
+        echo "PID=$LLM_RELAY_PID"
+    )" 2>/dev/null
+    assert_eq "llm_relay skips when socket missing" "PID=" "$result"
+}
+
+test_cleanup_llm_relay_noop() {
+    local result
+    result="$(
+        LLM_RELAY_PID=""
+        eval "$CLEANUP_LLM_FUNC"
+        cleanup_llm_relay
+        echo "ok"
+    )" 2>/dev/null
+    assert_eq "cleanup_llm_relay noop" "ok" "$result"
+}
+
+test_llm_relay_skips_no_port
+test_llm_relay_skips_missing_socket
+test_cleanup_llm_relay_noop
+
 # ============================================================================
 # Git credential helper tests
 # ============================================================================
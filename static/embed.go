@@ -17,7 +17,7 @@
 // Package static embeds build assets and default configuration files.
 package static
 
-import _ "embed"
+import "embed"
 
 //go:embed build/Dockerfile.base
 var DockerfileBase []byte
@@ -28,29 +28,48 @@ var DockerfileLocal []byte
 //go:embed build/Dockerfile.squid
 var DockerfileSquid []byte
 
+//go:embed build/Dockerfile.proxy
+var DockerfileProxy []byte
+
 //go:embed build/docker-entrypoint
 var DockerEntrypoint []byte
 
 //go:embed build/dockerignore
 var Dockerignore []byte
 
-//go:embed build/exitbox-allow-amd64
-var ExitboxAllowAmd64 []byte
-
-//go:embed build/exitbox-allow-arm64
-var ExitboxAllowArm64 []byte
+// Helper is a binary built from cmd/<Name> that is installed into every
+// agent image and talks to the host over IPC.
+type Helper struct {
+	Name    string
+	Comment string   // Dockerfile comment above its COPY line
+	Aliases []string // extra names it is installed under
+}
 
-//go:embed build/exitbox-vault-amd64
-var ExitboxVaultAmd64 []byte
+// Helpers lists the in-container helpers. Adding one takes its cmd/
+// directory and a line here: `make helpers` (also run by the release
+// workflow) builds every cmd/exitbox-* for both architectures.
+var Helpers = []Helper{
+	{Name: "exitbox-allow", Comment: "IPC client"},
+	{Name: "exitbox-vault", Comment: "Vault IPC client"},
+	{Name: "exitbox-kv", Comment: "KV IPC client"},
+	{Name: "exitbox-notify", Comment: "Notification IPC client"},
+	{Name: "exitbox-clip", Comment: "Clipboard IPC client"},
+	{Name: "exitbox-open", Comment: "Open-URL IPC client", Aliases: []string{"xdg-open"}},
+	{Name: "exitbox-fetch", Comment: "Host file IPC client"},
+	{Name: "exitbox-tool", Comment: "Tool request IPC client"},
+	{Name: "exitbox-host", Comment: "Host action IPC client"},
+}
 
-//go:embed build/exitbox-vault-arm64
-var ExitboxVaultArm64 []byte
-
-//go:embed build/exitbox-kv-amd64
-var ExitboxKVAmd64 []byte
+// binaries holds the helpers and exitbox-proxy, prebuilt for linux.
+//
+//go:embed build/exitbox-*-amd64 build/exitbox-*-arm64
+var binaries embed.FS
 
-//go:embed build/exitbox-kv-arm64
-var ExitboxKVArm64 []byte
+// Binary returns the embedded linux binary name built for arch, which is
+// amd64 or arm64.
+func Binary(name, arch string) ([]byte, error) {
+	return binaries.ReadFile("build/" + name + "-" + arch)
+}
 
 //go:embed config/allowlist.txt
 var DefaultAllowlistTxt []byte